@@ -0,0 +1,315 @@
+// Package audit provides borges.Library and borges.Location wrappers that
+// record every repository open, init, commit and close into a Sink.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+)
+
+// Options contains configuration options for the audit wrappers.
+type Options struct {
+	// Logger receives the errors of the Sink for the operations already
+	// done, like a commit or a close, by default borges.NoopLogger.
+	Logger borges.Logger
+}
+
+// Validate validates the fields and sets the default values.
+func (o *Options) Validate() error {
+	if o.Logger == nil {
+		o.Logger = borges.NoopLogger{}
+	}
+
+	return nil
+}
+
+func validate(opts *Options) *Options {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	opts.Validate()
+	return opts
+}
+
+// Op is an audited operation.
+type Op string
+
+const (
+	// OpInit is recorded when a repository is initialized.
+	OpInit Op = "init"
+	// OpOpen is recorded when an existing repository is opened.
+	OpOpen Op = "open"
+	// OpCommit is recorded when the changes of a repository are committed.
+	OpCommit Op = "commit"
+	// OpClose is recorded when a repository is closed.
+	OpClose Op = "close"
+)
+
+// RefChange is a reference changed by a commit, Old is empty for created
+// references and New for deleted ones.
+type RefChange struct {
+	Name string `json:"name"`
+	Old  string `json:"old,omitempty"`
+	New  string `json:"new,omitempty"`
+}
+
+// Record is an audit record, it's written as a JSON line by the file sinks.
+type Record struct {
+	// Time when the operation started.
+	Time time.Time `json:"time"`
+	// Principal is the name of the principal carried by the context.
+	Principal string `json:"principal,omitempty"`
+	Op        Op     `json:"op"`
+	// Library is the LibraryID of the audited Library, if any.
+	Library  borges.LibraryID    `json:"library,omitempty"`
+	Location borges.LocationID   `json:"location,omitempty"`
+	ID       borges.RepositoryID `json:"id"`
+	Mode     string              `json:"mode"`
+	// Refs are the references changed by a commit.
+	Refs []RefChange `json:"refs,omitempty"`
+	// Duration of the operation in nanoseconds.
+	Duration time.Duration `json:"duration"`
+	// Error is the error returned by the operation, if any.
+	Error string `json:"error,omitempty"`
+}
+
+// auditor creates and writes the records of a Library or Location.
+type auditor struct {
+	sink      Sink
+	logger    borges.Logger
+	principal string
+	lib       borges.LibraryID
+	now       func() time.Time
+}
+
+func newAuditor(sink Sink, opts *Options, lib borges.LibraryID) *auditor {
+	return &auditor{sink: sink, logger: opts.Logger, lib: lib, now: time.Now}
+}
+
+// withContext returns a copy of the auditor recording the name of the
+// Principal carried by ctx.
+func (a *auditor) withContext(ctx context.Context) *auditor {
+	p, _ := borges.PrincipalFromContext(ctx)
+	c := *a
+	c.principal = p.Name
+	return &c
+}
+
+// withLibrary returns a copy of the auditor recording the given LibraryID.
+func (a *auditor) withLibrary(lib borges.LibraryID) *auditor {
+	c := *a
+	c.lib = lib
+	return &c
+}
+
+// write completes and writes the record of an operation started at the given
+// time, err is the result of the operation. The error of the operation takes
+// precedence over the error of the Sink.
+func (a *auditor) write(rec *Record, start time.Time, err error) error {
+	if serr := a.writeRecord(rec, start, err); err == nil {
+		err = serr
+	}
+
+	return err
+}
+
+// done is like write for the operations that can't be undone, like a commit
+// or a close, the error of the Sink is logged instead of returned.
+func (a *auditor) done(rec *Record, start time.Time, err error) error {
+	if serr := a.writeRecord(rec, start, err); serr != nil {
+		a.logger.Warn("audit: record not written", serr, borges.Fields{
+			"op":       rec.Op,
+			"library":  rec.Library,
+			"location": rec.Location,
+			"id":       rec.ID,
+		})
+	}
+
+	return err
+}
+
+func (a *auditor) writeRecord(rec *Record, start time.Time, err error) error {
+	rec.Time = start
+	rec.Duration = a.now().Sub(start)
+	rec.Principal = a.principal
+	rec.Library = a.lib
+	if err != nil {
+		rec.Error = err.Error()
+	}
+
+	return a.sink.Write(rec)
+}
+
+// open records the opening of a repository, the returned Repository records
+// its commit and close. If the record can't be written the repository is
+// closed.
+func (a *auditor) open(
+	op Op,
+	id borges.RepositoryID,
+	mode borges.Mode,
+	loc borges.LocationID,
+	fn func() (borges.Repository, error),
+) (borges.Repository, error) {
+	start := a.now()
+	r, err := fn()
+
+	var ar *Repository
+	if err == nil {
+		loc = r.LocationID()
+		ar, err = newRepository(a, r)
+	}
+
+	err = a.write(&Record{Op: op, ID: id, Mode: modeName(mode), Location: loc}, start, err)
+	if err != nil {
+		if r != nil {
+			r.Close()
+		}
+
+		return nil, err
+	}
+
+	return ar, nil
+}
+
+func (a *auditor) repositories(iter borges.RepositoryIterator) borges.RepositoryIterator {
+	return util.NewMapRepositoryIterator(iter, func(r borges.Repository) (borges.Repository, error) {
+		return a.open(OpOpen, r.ID(), r.Mode(), r.LocationID(), func() (borges.Repository, error) {
+			return r, nil
+		})
+	})
+}
+
+func modeName(m borges.Mode) string {
+	if m == borges.RWMode {
+		return "rw"
+	}
+
+	return "read-only"
+}
+
+// Library wraps a borges.Library recording every repository open, init,
+// commit and close. Every location, library and repository returned by it
+// is wrapped too.
+type Library struct {
+	lib borges.Library
+	a   *auditor
+}
+
+var _ borges.Library = (*Library)(nil)
+
+// NewLibrary returns a new Library wrapping the given one, the records are
+// written to the Sink without Principal until WithContext is used.
+func NewLibrary(lib borges.Library, sink Sink, opts *Options) *Library {
+	return &Library{lib: lib, a: newAuditor(sink, validate(opts), lib.ID())}
+}
+
+// WithContext returns a copy of the Library recording the name of the
+// Principal carried by ctx. The Library can be shared, WithContext should be
+// called on every request.
+func (l *Library) WithContext(ctx context.Context) *Library {
+	return &Library{lib: l.lib, a: l.a.withContext(ctx)}
+}
+
+// ID returns the borges.LibraryID of the wrapped Library.
+func (l *Library) ID() borges.LibraryID {
+	return l.lib.ID()
+}
+
+// Init initializes a new Repository in the wrapped Library and records it.
+func (l *Library) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return l.a.open(OpInit, id, borges.RWMode, "", func() (borges.Repository, error) {
+		return l.lib.Init(id)
+	})
+}
+
+// GetOrInit opens or initializes the repository in the wrapped Library and
+// records it as an open.
+func (l *Library) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	return l.a.open(OpOpen, id, borges.RWMode, "", func() (borges.Repository, error) {
+		return l.lib.GetOrInit(id)
+	})
+}
+
+// Get opens the repository with the given RepositoryID and records it.
+func (l *Library) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	return l.a.open(OpOpen, id, mode, "", func() (borges.Repository, error) {
+		return l.lib.Get(id, mode)
+	})
+}
+
+// Has returns true, the LibraryID and the LocationID if the given
+// RepositoryID matches any repository of the wrapped Library.
+func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	return l.lib.Has(id)
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Library recording each open.
+func (l *Library) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	iter, err := l.lib.Repositories(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.a.repositories(iter), nil
+}
+
+// Location returns the Location with the given LocationID wrapped as an
+// audited Location.
+func (l *Library) Location(id borges.LocationID) (borges.Location, error) {
+	loc, err := l.lib.Location(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.location(loc), nil
+}
+
+// Locations returns a LocationIterator that iterates through all the
+// locations of the wrapped Library wrapped as audited locations.
+func (l *Library) Locations() (borges.LocationIterator, error) {
+	iter, err := l.lib.Locations()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLocationIterator(iter, func(loc borges.Location) (borges.Location, error) {
+		return l.location(loc), nil
+	}), nil
+}
+
+// Library returns the Library with the given LibraryID wrapped as an
+// audited Library.
+func (l *Library) Library(id borges.LibraryID) (borges.Library, error) {
+	lib, err := l.lib.Library(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.library(lib), nil
+}
+
+// Libraries returns a LibraryIterator that iterates through all the
+// libraries of the wrapped Library wrapped as audited libraries.
+func (l *Library) Libraries() (borges.LibraryIterator, error) {
+	iter, err := l.lib.Libraries()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLibraryIterator(iter, func(lib borges.Library) (borges.Library, error) {
+		return l.library(lib), nil
+	}), nil
+}
+
+func (l *Library) location(loc borges.Location) *Location {
+	return &Location{loc: loc, a: l.a}
+}
+
+func (l *Library) library(lib borges.Library) *Library {
+	return &Library{lib: lib, a: l.a.withLibrary(lib.ID())}
+}
@@ -0,0 +1,188 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// recorder is a Sink keeping the records in memory.
+type recorder struct {
+	recs []*Record
+	err  error
+}
+
+func (r *recorder) Write(rec *Record) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	r.recs = append(r.recs, rec)
+	return nil
+}
+
+func (r *recorder) ops() []Op {
+	var ops []Op
+	for _, rec := range r.recs {
+		ops = append(ops, rec.Op)
+	}
+
+	return ops
+}
+
+func withPrincipal(name string) context.Context {
+	return borges.WithPrincipal(context.Background(), borges.Principal{Name: name})
+}
+
+func newLibrary(require *require.Assertions, sink Sink, principal string) *Library {
+	lib := borgestest.RequirePlainLibrary(
+		require,
+		&plain.LocationOptions{Transactional: true},
+		"github.com/foo/bar",
+	)
+
+	return NewLibrary(lib, sink, nil).WithContext(withPrincipal(principal))
+}
+
+func TestLibrary_Get(t *testing.T) {
+	require := require.New(t)
+
+	rec := &recorder{}
+	lib := newLibrary(require, rec, "alice")
+
+	r, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	_, err = lib.Get("github.com/foo/qux", borges.RWMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+
+	require.Equal([]Op{OpOpen, OpClose, OpOpen}, rec.ops())
+
+	open := rec.recs[0]
+	require.Equal("alice", open.Principal)
+	require.Equal(borges.LibraryID("foo"), open.Library)
+	require.Equal(borges.LocationID("foo"), open.Location)
+	require.Equal(borges.RepositoryID("github.com/foo/bar"), open.ID)
+	require.Equal("read-only", open.Mode)
+	require.False(open.Time.IsZero())
+	require.Empty(open.Error)
+
+	failed := rec.recs[2]
+	require.Equal("rw", failed.Mode)
+	require.Equal(borges.RepositoryID("github.com/foo/qux"), failed.ID)
+	require.NotEmpty(failed.Error)
+}
+
+func TestLibrary_SinkError(t *testing.T) {
+	require := require.New(t)
+
+	rec := &recorder{err: errors.New("disk full")}
+	lib := newLibrary(require, rec, "alice")
+
+	_, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.EqualError(err, "disk full")
+}
+
+func TestLibrary_WithContext(t *testing.T) {
+	require := require.New(t)
+
+	rec := &recorder{}
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), rec, nil)
+
+	for _, name := range []string{"", "alice", "bob"} {
+		l := lib
+		if name != "" {
+			l = lib.WithContext(withPrincipal(name))
+		}
+
+		r, err := l.Get("github.com/foo/bar", borges.ReadOnlyMode)
+		require.NoError(err)
+		require.NoError(r.Close())
+	}
+
+	var principals []string
+	for _, r := range rec.recs {
+		principals = append(principals, r.Principal)
+	}
+
+	require.Equal([]string{"", "", "alice", "alice", "bob", "bob"}, principals)
+}
+
+func TestLibrary_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	rec := &recorder{}
+	lib := newLibrary(require, rec, "alice")
+
+	iter, err := lib.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	err = iter.ForEach(func(r borges.Repository) error {
+		require.IsType(&Repository{}, r)
+		return r.Close()
+	})
+	require.NoError(err)
+	require.Equal([]Op{OpOpen, OpClose}, rec.ops())
+}
+
+func TestLibrary_Libraries(t *testing.T) {
+	require := require.New(t)
+
+	rec := &recorder{}
+	lib := newLibrary(require, rec, "alice")
+
+	sub, err := lib.Library("sub")
+	require.NoError(err)
+
+	r, err := sub.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+	require.Equal(borges.LibraryID("sub"), rec.recs[0].Library)
+
+	loc, err := lib.Location("foo")
+	require.NoError(err)
+	require.IsType(&Location{}, loc)
+
+	libs, err := lib.Libraries()
+	require.NoError(err)
+	err = libs.ForEach(func(lib borges.Library) error {
+		require.IsType(&Library{}, lib)
+		return nil
+	})
+	require.NoError(err)
+
+	locs, err := lib.Locations()
+	require.NoError(err)
+	err = locs.ForEach(func(loc borges.Location) error {
+		require.IsType(&Location{}, loc)
+		return nil
+	})
+	require.NoError(err)
+}
+
+func TestLibrarySuite(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LibrarySuite{
+			NewLibrary: func() (borges.Library, error) {
+				lib, err := borgestest.NewPlainLibrary(&plain.LocationOptions{
+					Transactional: transactional,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return NewLibrary(lib, &recorder{}, nil).WithContext(withPrincipal("alice")), nil
+			},
+			Transactional: transactional,
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/src-d/go-borges"
+)
+
+// Location wraps a borges.Location recording every repository open, init,
+// commit and close.
+type Location struct {
+	loc borges.Location
+	a   *auditor
+}
+
+var _ borges.Location = (*Location)(nil)
+
+// NewLocation returns a new Location wrapping the given one, the records are
+// written to the Sink without Principal until WithContext is used.
+func NewLocation(loc borges.Location, sink Sink, opts *Options) *Location {
+	return &Location{loc: loc, a: newAuditor(sink, validate(opts), "")}
+}
+
+// WithContext returns a copy of the Location recording the name of the
+// Principal carried by ctx. The Location can be shared, WithContext should
+// be called on every request.
+func (l *Location) WithContext(ctx context.Context) *Location {
+	return &Location{loc: l.loc, a: l.a.withContext(ctx)}
+}
+
+// ID returns the ID of the wrapped Location.
+func (l *Location) ID() borges.LocationID {
+	return l.loc.ID()
+}
+
+// Init initializes a new Repository in the wrapped Location and records it.
+func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return l.a.open(OpInit, id, borges.RWMode, l.loc.ID(), func() (borges.Repository, error) {
+		return l.loc.Init(id)
+	})
+}
+
+// GetOrInit opens or initializes the repository in the wrapped Location and
+// records it as an open.
+func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	return l.a.open(OpOpen, id, borges.RWMode, l.loc.ID(), func() (borges.Repository, error) {
+		return l.loc.GetOrInit(id)
+	})
+}
+
+// Has returns true if the given RepositoryID matches any repository of the
+// wrapped Location.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	return l.loc.Has(id)
+}
+
+// Get opens the repository with the given RepositoryID and records it.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	return l.a.open(OpOpen, id, mode, l.loc.ID(), func() (borges.Repository, error) {
+		return l.loc.Get(id, mode)
+	})
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Location recording each open.
+func (l *Location) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	iter, err := l.loc.Repositories(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.a.repositories(iter), nil
+}
@@ -0,0 +1,66 @@
+package audit
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocation_Init(t *testing.T) {
+	require := require.New(t)
+
+	mem, err := memory.NewLocation("mem", nil)
+	require.NoError(err)
+
+	rec := &recorder{}
+	loc := NewLocation(mem, rec, nil).WithContext(withPrincipal("bob"))
+
+	r, err := loc.Init("foo")
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	_, err = loc.Init("foo")
+	require.True(borges.ErrRepositoryExists.Is(err))
+
+	r, err = loc.GetOrInit("foo")
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	require.Equal([]Op{OpInit, OpClose, OpInit, OpOpen, OpClose}, rec.ops())
+	for _, r := range rec.recs {
+		require.Equal("bob", r.Principal)
+		require.Equal(borges.LocationID("mem"), r.Location)
+		require.Empty(r.Library)
+	}
+
+	require.NotEmpty(rec.recs[2].Error)
+}
+
+func TestLocation_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	mem, err := memory.NewLocation("mem", nil)
+	require.NoError(err)
+
+	for _, id := range []borges.RepositoryID{"foo", "bar"} {
+		_, err = mem.Init(id)
+		require.NoError(err)
+	}
+
+	rec := &recorder{}
+	loc := NewLocation(mem, rec, nil).WithContext(withPrincipal("bob"))
+
+	iter, err := loc.Repositories(borges.RWMode)
+	require.NoError(err)
+
+	err = iter.ForEach(func(r borges.Repository) error {
+		return r.Close()
+	})
+	require.NoError(err)
+
+	require.Equal([]Op{OpOpen, OpClose, OpOpen, OpClose}, rec.ops())
+	require.Equal("rw", rec.recs[0].Mode)
+}
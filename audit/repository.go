@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// Repository wraps a borges.Repository recording its commit and close. The
+// references of repositories opened in RWMode are saved when opened to
+// compute the changed references at commit time.
+type Repository struct {
+	borges.Repository
+	a    *auditor
+	refs map[plumbing.ReferenceName]plumbing.Hash
+}
+
+func newRepository(a *auditor, r borges.Repository) (*Repository, error) {
+	ar := &Repository{Repository: r, a: a}
+	if r.Mode() != borges.RWMode {
+		return ar, nil
+	}
+
+	refs, err := util.References(r.R().Storer)
+	if err != nil {
+		return nil, err
+	}
+
+	ar.refs = refs
+	return ar, nil
+}
+
+// Commit persists the changes of the wrapped Repository and records them
+// with the references changed since it was opened or last committed. The
+// errors writing the record are logged, the changes are already persisted.
+func (r *Repository) Commit() error {
+	start := r.a.now()
+	rec := r.record(OpCommit)
+
+	var refs map[plumbing.ReferenceName]plumbing.Hash
+	if r.refs != nil {
+		var err error
+		refs, err = util.References(r.R().Storer)
+		if err != nil {
+			return r.a.write(rec, start, err)
+		}
+
+		rec.Refs = refChanges(util.DiffReferences(r.refs, refs))
+	}
+
+	err := r.Repository.Commit()
+	if err == nil && refs != nil {
+		r.refs = refs
+	}
+
+	return r.a.done(rec, start, err)
+}
+
+// Close closes the wrapped Repository and records it.
+func (r *Repository) Close() error {
+	start := r.a.now()
+	err := r.Repository.Close()
+	return r.a.done(r.record(OpClose), start, err)
+}
+
+func (r *Repository) record(op Op) *Record {
+	return &Record{
+		Op:       op,
+		Location: r.LocationID(),
+		ID:       r.ID(),
+		Mode:     modeName(r.Mode()),
+	}
+}
+
+func refChanges(changes []util.RefChange) []RefChange {
+	var refs []RefChange
+	for _, c := range changes {
+		rc := RefChange{Name: c.Name.String()}
+		if !c.Old.IsZero() {
+			rc.Old = c.Old.String()
+		}
+
+		if !c.New.IsZero() {
+			rc.New = c.New.String()
+		}
+
+		refs = append(refs, rc)
+	}
+
+	return refs
+}
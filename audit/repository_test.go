@@ -0,0 +1,117 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/memory"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestRepository_Commit(t *testing.T) {
+	require := require.New(t)
+
+	h1 := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	h2 := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+
+	mem, err := memory.NewLocation("mem", &memory.LocationOptions{Transactional: true})
+	require.NoError(err)
+
+	r, err := mem.Init("foo")
+	require.NoError(err)
+	require.NoError(r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/master", h1)))
+	require.NoError(r.Commit())
+
+	rec := &recorder{}
+	loc := NewLocation(mem, rec, nil).WithContext(withPrincipal("alice"))
+
+	r, err = loc.Get("foo", borges.RWMode)
+	require.NoError(err)
+
+	s := r.R().Storer
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", h2)))
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/new", h1)))
+	require.NoError(r.Commit())
+
+	require.Equal([]Op{OpOpen, OpCommit}, rec.ops())
+
+	commit := rec.recs[1]
+	require.Equal(borges.RepositoryID("foo"), commit.ID)
+	require.Equal(borges.LocationID("mem"), commit.Location)
+	require.Equal("alice", commit.Principal)
+	require.Equal([]RefChange{
+		{Name: "refs/heads/master", Old: h1.String(), New: h2.String()},
+		{Name: "refs/heads/new", New: h1.String()},
+	}, commit.Refs)
+}
+
+func TestRepository_Commit_ReadOnly(t *testing.T) {
+	require := require.New(t)
+
+	mem, err := memory.NewLocation("mem", &memory.LocationOptions{Transactional: true})
+	require.NoError(err)
+
+	r, err := mem.Init("foo")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	rec := &recorder{}
+	loc := NewLocation(mem, rec, nil).WithContext(withPrincipal("alice"))
+
+	r, err = loc.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	err = r.Commit()
+	require.Error(err)
+	require.Len(rec.recs, 2)
+	require.Equal(OpCommit, rec.recs[1].Op)
+	require.Equal(err.Error(), rec.recs[1].Error)
+	require.Empty(rec.recs[1].Refs)
+}
+
+// warnings is a borges.Logger keeping the errors of the warnings.
+type warnings struct {
+	borges.NoopLogger
+	errs []error
+}
+
+func (w *warnings) Warn(msg string, err error, fields borges.Fields) {
+	w.errs = append(w.errs, err)
+}
+
+func TestRepository_Commit_SinkError(t *testing.T) {
+	require := require.New(t)
+
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	mem, err := memory.NewLocation("mem", &memory.LocationOptions{Transactional: true})
+	require.NoError(err)
+
+	r, err := mem.Init("foo")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	rec := &recorder{}
+	logger := &warnings{}
+	loc := NewLocation(mem, rec, &Options{Logger: logger})
+
+	r, err = loc.Get("foo", borges.RWMode)
+	require.NoError(err)
+	require.NoError(r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/master", h)))
+
+	// the changes are persisted even if they can't be recorded.
+	rec.err = errors.New("disk full")
+	require.NoError(r.Commit())
+	require.NoError(r.Close())
+	require.Len(logger.errs, 2)
+	require.EqualError(logger.errs[0], "disk full")
+
+	r, err = mem.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	ref, err := r.R().Reference("refs/heads/master", false)
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+}
@@ -0,0 +1,174 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Sink receives the audit records.
+type Sink interface {
+	// Write persists the record, when it fails opening a repository fails
+	// too. The failures recording a commit or a close, already done, are
+	// logged to Options.Logger.
+	Write(*Record) error
+}
+
+// SinkFunc is an adapter to allow the use of ordinary functions as Sink.
+type SinkFunc func(*Record) error
+
+// Write calls f(r).
+func (f SinkFunc) Write(r *Record) error {
+	return f(r)
+}
+
+// FileSink appends the records to a file as JSON lines.
+type FileSink struct {
+	m sync.Mutex
+	f *os.File
+}
+
+// NewFileSink opens or creates the file at the given path to append records.
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := openAppend(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileSink{f: f}, nil
+}
+
+// Write appends the record as a JSON line.
+func (s *FileSink) Write(r *Record) error {
+	line, err := encode(r)
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	_, err = s.f.Write(line)
+	return err
+}
+
+// Close closes the file.
+func (s *FileSink) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.f.Close()
+}
+
+// RotatingFileSink appends the records to a file as JSON lines, when the
+// file reaches MaxSize it's renamed to path.1, the previous path.1 to
+// path.2 and so on, keeping at most MaxBackups files.
+type RotatingFileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	m    sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// NewRotatingFileSink opens or creates the file at the given path to append
+// records, rotating it when its size would exceed maxSize bytes.
+func NewRotatingFileSink(path string, maxSize int64, maxBackups int) (*RotatingFileSink, error) {
+	f, err := openAppend(path)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return &RotatingFileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+		f:          f,
+		size:       fi.Size(),
+	}, nil
+}
+
+// Write appends the record as a JSON line, rotating the file if needed.
+func (s *RotatingFileSink) Write(r *Record) error {
+	line, err := encode(r)
+	if err != nil {
+		return err
+	}
+
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	if s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.f.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *RotatingFileSink) rotate() error {
+	if err := s.f.Close(); err != nil {
+		return err
+	}
+
+	if s.maxBackups > 0 {
+		for i := s.maxBackups - 1; i > 0; i-- {
+			err := os.Rename(s.backup(i), s.backup(i+1))
+			if err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+
+		if err := os.Rename(s.path, s.backup(1)); err != nil {
+			return err
+		}
+	} else if err := os.Remove(s.path); err != nil {
+		return err
+	}
+
+	f, err := openAppend(s.path)
+	if err != nil {
+		return err
+	}
+
+	s.f = f
+	s.size = 0
+	return nil
+}
+
+func (s *RotatingFileSink) backup(i int) string {
+	return fmt.Sprintf("%s.%d", s.path, i)
+}
+
+// Close closes the current file.
+func (s *RotatingFileSink) Close() error {
+	s.m.Lock()
+	defer s.m.Unlock()
+
+	return s.f.Close()
+}
+
+func openAppend(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0640)
+}
+
+func encode(r *Record) ([]byte, error) {
+	line, err := json.Marshal(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(line, '\n'), nil
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func readRecords(require *require.Assertions, path string) []*Record {
+	f, err := os.Open(path)
+	require.NoError(err)
+	defer f.Close()
+
+	var recs []*Record
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		rec := &Record{}
+		require.NoError(json.Unmarshal(s.Bytes(), rec))
+		recs = append(recs, rec)
+	}
+
+	require.NoError(s.Err())
+	return recs
+}
+
+func TestFileSink(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "audit.log")
+	sink, err := NewFileSink(path)
+	require.NoError(err)
+	require.NoError(sink.Write(&Record{Op: OpOpen, ID: "foo"}))
+	require.NoError(sink.Close())
+
+	sink, err = NewFileSink(path)
+	require.NoError(err)
+	require.NoError(sink.Write(&Record{Op: OpClose, ID: "foo"}))
+	require.NoError(sink.Close())
+
+	recs := readRecords(require, path)
+	require.Len(recs, 2)
+	require.Equal(OpOpen, recs[0].Op)
+	require.Equal(OpClose, recs[1].Op)
+}
+
+func TestRotatingFileSink(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "audit")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	line, err := encode(&Record{Op: OpOpen, ID: "foo"})
+	require.NoError(err)
+
+	path := filepath.Join(dir, "audit.log")
+	sink, err := NewRotatingFileSink(path, int64(2*len(line)), 2)
+	require.NoError(err)
+
+	for i := 0; i < 7; i++ {
+		require.NoError(sink.Write(&Record{Op: OpOpen, ID: "foo"}))
+	}
+	require.NoError(sink.Close())
+
+	require.Len(readRecords(require, path), 1)
+	require.Len(readRecords(require, path+".1"), 2)
+	require.Len(readRecords(require, path+".2"), 2)
+
+	_, err = os.Stat(path + ".3")
+	require.True(os.IsNotExist(err))
+}
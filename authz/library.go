@@ -0,0 +1,206 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+)
+
+// Library wraps a borges.Library checking the permissions of a Principal
+// before every operation, the Principal is taken from the context given to
+// WithContext. The repositories the Principal can't read are hidden from Has
+// and the iterators, and the operations over them fail with
+// ErrPermissionDenied whether they exist or not.
+type Library struct {
+	lib    borges.Library
+	policy *Policy
+	p      borges.Principal
+}
+
+var _ borges.Library = (*Library)(nil)
+
+// NewLibrary returns a new Library wrapping the given one, the permissions
+// are checked against the given Policy for an anonymous Principal until
+// WithContext is used.
+func NewLibrary(lib borges.Library, policy *Policy) *Library {
+	return &Library{lib: lib, policy: policy}
+}
+
+// WithContext returns a copy of the Library checking the permissions of the
+// Principal carried by ctx. The Library can be shared, WithContext should be
+// called on every request.
+func (l *Library) WithContext(ctx context.Context) *Library {
+	p, _ := borges.PrincipalFromContext(ctx)
+	return &Library{lib: l.lib, policy: l.policy, p: p}
+}
+
+// ID returns the borges.LibraryID of the wrapped Library.
+func (l *Library) ID() borges.LibraryID {
+	return l.lib.ID()
+}
+
+// Init initializes a new Repository if the Principal has the init and read
+// permissions, otherwise ErrPermissionDenied is returned.
+func (l *Library) Init(id borges.RepositoryID) (borges.Repository, error) {
+	if err := l.policy.check(l.p, id, initPermissions...); err != nil {
+		return nil, err
+	}
+
+	return l.lib.Init(id)
+}
+
+// GetOrInit opens the repository in RWMode if it exists and the Principal
+// has the read and write permissions, or initializes it if the Principal has
+// the init and read permissions. Otherwise ErrPermissionDenied is returned.
+func (l *Library) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	if err := l.policy.check(l.p, id, Read); err != nil {
+		return nil, err
+	}
+
+	has, _, _, err := l.lib.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return l.Get(id, borges.RWMode)
+	}
+
+	return l.Init(id)
+}
+
+// Get opens the repository with the given RepositoryID if the Principal has
+// the read permission, and the write permission in RWMode. Otherwise
+// ErrPermissionDenied is returned.
+func (l *Library) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	if err := l.policy.check(l.p, id, modePermissions(mode)...); err != nil {
+		return nil, err
+	}
+
+	return l.lib.Get(id, mode)
+}
+
+// Has returns true, the LibraryID and the LocationID if the given
+// RepositoryID matches any repository of the wrapped Library and the
+// Principal has the read permission.
+func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	if !l.policy.Allowed(l.p, id, Read) {
+		return false, "", "", nil
+	}
+
+	return l.lib.Has(id)
+}
+
+// Repositories returns a RepositoryIterator that iterates through the
+// repositories of the wrapped Library the Principal is allowed to open in
+// the given mode. In RWMode only the allowed repositories are opened in
+// RWMode.
+func (l *Library) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	return repositories(l.lib.Repositories, l.reopen, l.policy, l.p, mode)
+}
+
+// reopen opens again in RWMode a repository listed in ReadOnlyMode, from its
+// Location if it's one of the wrapped Library.
+func (l *Library) reopen(r borges.Repository) (borges.Repository, error) {
+	id, locID := r.ID(), r.LocationID()
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+
+	loc, err := l.lib.Location(locID)
+	if borges.ErrLocationNotExists.Is(err) {
+		return l.lib.Get(id, borges.RWMode)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return loc.Get(id, borges.RWMode)
+}
+
+// Location returns the Location with the given LocationID wrapped to check
+// the permissions of the same Principal.
+func (l *Library) Location(id borges.LocationID) (borges.Location, error) {
+	loc, err := l.lib.Location(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.location(loc), nil
+}
+
+// Locations returns a LocationIterator that iterates through all the
+// locations of the wrapped Library wrapped to check the permissions of the
+// same Principal.
+func (l *Library) Locations() (borges.LocationIterator, error) {
+	iter, err := l.lib.Locations()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLocationIterator(iter, func(loc borges.Location) (borges.Location, error) {
+		return l.location(loc), nil
+	}), nil
+}
+
+// Library returns the Library with the given LibraryID wrapped to check the
+// permissions of the same Principal.
+func (l *Library) Library(id borges.LibraryID) (borges.Library, error) {
+	lib, err := l.lib.Library(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.library(lib), nil
+}
+
+// Libraries returns a LibraryIterator that iterates through all the
+// libraries of the wrapped Library wrapped to check the permissions of the
+// same Principal.
+func (l *Library) Libraries() (borges.LibraryIterator, error) {
+	iter, err := l.lib.Libraries()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLibraryIterator(iter, func(lib borges.Library) (borges.Library, error) {
+		return l.library(lib), nil
+	}), nil
+}
+
+func (l *Library) location(loc borges.Location) *Location {
+	return &Location{loc: loc, policy: l.policy, p: l.p}
+}
+
+func (l *Library) library(lib borges.Library) *Library {
+	return &Library{lib: lib, policy: l.policy, p: l.p}
+}
+
+// repositories returns an iterator of the repositories the Principal can
+// open in the given mode. They are listed in ReadOnlyMode and only the
+// allowed ones are opened again in RWMode with reopen.
+func repositories(
+	list func(borges.Mode) (borges.RepositoryIterator, error),
+	reopen func(borges.Repository) (borges.Repository, error),
+	policy *Policy,
+	p borges.Principal,
+	mode borges.Mode,
+) (borges.RepositoryIterator, error) {
+	iter, err := list(borges.ReadOnlyMode)
+	if err != nil {
+		return nil, err
+	}
+
+	perms := modePermissions(mode)
+	iter = util.NewFilterRepositoryIterator(iter, func(r borges.Repository) (bool, error) {
+		return policy.check(p, r.ID(), perms...) == nil, nil
+	})
+
+	if mode == borges.ReadOnlyMode {
+		return iter, nil
+	}
+
+	return util.NewMapRepositoryIterator(iter, reopen), nil
+}
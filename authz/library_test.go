@@ -0,0 +1,189 @@
+package authz
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// ids are the repositories of the library returned by newLibrary.
+var ids = []borges.RepositoryID{
+	"github.com/src-d/go-borges",
+	"github.com/src-d/go-git",
+	"gitlab.com/foo/bar",
+}
+
+func newLibrary(require *require.Assertions, p *Policy, principal string) *Library {
+	lib := borgestest.RequirePlainLibrary(require, nil, ids...)
+	return NewLibrary(lib, p).WithContext(withPrincipal(principal))
+}
+
+func withPrincipal(name string) context.Context {
+	return borges.WithPrincipal(context.Background(), borges.Principal{Name: name})
+}
+
+func TestLibrary_Init(t *testing.T) {
+	require := require.New(t)
+
+	p := newPolicy(require)
+
+	lib := newLibrary(require, p, "bob")
+	_, err := lib.Init("github.com/src-d/qux")
+	require.True(ErrPermissionDenied.Is(err))
+
+	_, err = lib.GetOrInit("github.com/src-d/go-borges")
+	require.True(ErrPermissionDenied.Is(err))
+
+	lib = newLibrary(require, p, "alice")
+	_, err = lib.Init("github.com/src-d/qux")
+	require.True(borges.ErrNotImplemented.Is(err))
+
+	r, err := lib.GetOrInit("github.com/src-d/go-borges")
+	require.NoError(err)
+	require.Equal(borges.RWMode, r.Mode())
+}
+
+func TestLibrary_WithContext(t *testing.T) {
+	require := require.New(t)
+
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, ids...), newPolicy(require))
+
+	_, err := lib.Get("github.com/src-d/go-borges", borges.ReadOnlyMode)
+	require.True(ErrPermissionDenied.Is(err))
+
+	for _, name := range []string{"bob", "alice"} {
+		r, err := lib.WithContext(withPrincipal(name)).Get("github.com/src-d/go-borges", borges.ReadOnlyMode)
+		require.NoError(err, name)
+		require.NoError(r.Close())
+	}
+
+	_, err = lib.WithContext(withPrincipal("bob")).Get("github.com/src-d/go-borges", borges.RWMode)
+	require.True(ErrPermissionDenied.Is(err))
+
+	r, err := lib.WithContext(withPrincipal("alice")).Get("github.com/src-d/go-borges", borges.RWMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+}
+
+func TestLibrary_Get(t *testing.T) {
+	require := require.New(t)
+
+	lib := newLibrary(require, newPolicy(require), "bob")
+
+	_, err := lib.Get("github.com/src-d/go-borges", borges.RWMode)
+	require.True(ErrPermissionDenied.Is(err))
+
+	r, err := lib.Get("github.com/src-d/go-borges", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	_, err = lib.Get("gitlab.com/foo/bar", borges.ReadOnlyMode)
+	require.True(ErrPermissionDenied.Is(err))
+
+	ok, libID, locID, err := lib.Has("github.com/src-d/go-borges")
+	require.NoError(err)
+	require.True(ok)
+	require.Equal(borges.LibraryID("foo"), libID)
+	require.Equal(borges.LocationID("foo"), locID)
+
+	ok, _, _, err = lib.Has("gitlab.com/foo/bar")
+	require.NoError(err)
+	require.False(ok)
+}
+
+func TestLibrary_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	lib := newLibrary(require, newPolicy(require), "bob")
+
+	iter, err := lib.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return r.Close()
+	})
+	require.NoError(err)
+	require.ElementsMatch([]borges.RepositoryID{
+		"github.com/src-d/go-borges",
+		"github.com/src-d/go-git",
+	}, ids)
+
+	iter, err = lib.Repositories(borges.RWMode)
+	require.NoError(err)
+
+	err = iter.ForEach(func(r borges.Repository) error {
+		require.Fail("unexpected repository", r.ID())
+		return nil
+	})
+	require.NoError(err)
+}
+
+func TestLibrary_Locations(t *testing.T) {
+	require := require.New(t)
+
+	lib := newLibrary(require, newPolicy(require), "bob")
+
+	sub, err := lib.Library("sub")
+	require.NoError(err)
+	require.IsType(&Library{}, sub)
+
+	loc, err := sub.Location("foo")
+	require.NoError(err)
+	require.IsType(&Location{}, loc)
+
+	_, err = loc.Get("gitlab.com/foo/bar", borges.ReadOnlyMode)
+	require.True(ErrPermissionDenied.Is(err))
+
+	locs, err := lib.Locations()
+	require.NoError(err)
+	err = locs.ForEach(func(loc borges.Location) error {
+		require.IsType(&Location{}, loc)
+		return nil
+	})
+	require.NoError(err)
+
+	libs, err := lib.Libraries()
+	require.NoError(err)
+	err = libs.ForEach(func(lib borges.Library) error {
+		require.IsType(&Library{}, lib)
+		return nil
+	})
+	require.NoError(err)
+}
+
+func TestLibrarySuite(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LibrarySuite{
+			NewLibrary: func() (borges.Library, error) {
+				lib, err := borgestest.NewPlainLibrary(&plain.LocationOptions{
+					Transactional: transactional,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				policy, err := ParsePolicy(strings.NewReader(`{"rules": [{
+					"principals": ["*"],
+					"repositories": ["**"],
+					"permissions": ["read", "write", "init", "delete"]
+				}]}`))
+				if err != nil {
+					return nil, err
+				}
+
+				return NewLibrary(lib, policy).WithContext(withPrincipal("alice")), nil
+			},
+			Transactional: transactional,
+		})
+	}
+}
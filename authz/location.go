@@ -0,0 +1,132 @@
+package authz
+
+import (
+	"context"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// ErrDeleteNotSupported is returned by Location.Delete when the wrapped
+// Location can't remove repositories.
+var ErrDeleteNotSupported = errors.NewKind("location %s doesn't support delete")
+
+// Location wraps a borges.Location checking the permissions of a Principal
+// before every operation, the Principal is taken from the context given to
+// WithContext. The repositories the Principal can't read are hidden from Has
+// and the iterators, and the operations over them fail with
+// ErrPermissionDenied whether they exist or not.
+type Location struct {
+	loc    borges.Location
+	policy *Policy
+	p      borges.Principal
+}
+
+var _ borges.Location = (*Location)(nil)
+
+// NewLocation returns a new Location wrapping the given one, the permissions
+// are checked against the given Policy for an anonymous Principal until
+// WithContext is used.
+func NewLocation(loc borges.Location, policy *Policy) *Location {
+	return &Location{loc: loc, policy: policy}
+}
+
+// WithContext returns a copy of the Location checking the permissions of the
+// Principal carried by ctx. The Location can be shared, WithContext should
+// be called on every request.
+func (l *Location) WithContext(ctx context.Context) *Location {
+	p, _ := borges.PrincipalFromContext(ctx)
+	return &Location{loc: l.loc, policy: l.policy, p: p}
+}
+
+// ID returns the ID of the wrapped Location.
+func (l *Location) ID() borges.LocationID {
+	return l.loc.ID()
+}
+
+// Init initializes a new Repository if the Principal has the init and read
+// permissions, otherwise ErrPermissionDenied is returned.
+func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	if err := l.policy.check(l.p, id, initPermissions...); err != nil {
+		return nil, err
+	}
+
+	return l.loc.Init(id)
+}
+
+// GetOrInit opens the repository in RWMode if it exists and the Principal
+// has the read and write permissions, or initializes it if the Principal has
+// the init and read permissions. Otherwise ErrPermissionDenied is returned.
+func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	if err := l.policy.check(l.p, id, Read); err != nil {
+		return nil, err
+	}
+
+	has, err := l.loc.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return l.Get(id, borges.RWMode)
+	}
+
+	return l.Init(id)
+}
+
+// Has returns true if the given RepositoryID matches any repository of the
+// wrapped Location and the Principal has the read permission.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	if !l.policy.Allowed(l.p, id, Read) {
+		return false, nil
+	}
+
+	return l.loc.Has(id)
+}
+
+// Get opens the repository with the given RepositoryID if the Principal has
+// the read permission, and the write permission in RWMode. Otherwise
+// ErrPermissionDenied is returned.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	if err := l.policy.check(l.p, id, modePermissions(mode)...); err != nil {
+		return nil, err
+	}
+
+	return l.loc.Get(id, mode)
+}
+
+// Repositories returns a RepositoryIterator that iterates through the
+// repositories of the wrapped Location the Principal is allowed to open in
+// the given mode. In RWMode only the allowed repositories are opened in
+// RWMode.
+func (l *Location) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	return repositories(l.loc.Repositories, l.reopen, l.policy, l.p, mode)
+}
+
+func (l *Location) reopen(r borges.Repository) (borges.Repository, error) {
+	id := r.ID()
+	if err := r.Close(); err != nil {
+		return nil, err
+	}
+
+	return l.loc.Get(id, borges.RWMode)
+}
+
+// Delete removes the repository with the given RepositoryID if the Principal
+// has the delete and read permissions. If the wrapped Location doesn't
+// implement Delete ErrDeleteNotSupported is returned.
+func (l *Location) Delete(id borges.RepositoryID) error {
+	if err := l.policy.check(l.p, id, Delete, Read); err != nil {
+		return err
+	}
+
+	d, ok := l.loc.(interface {
+		Delete(borges.RepositoryID) error
+	})
+	if !ok {
+		return ErrDeleteNotSupported.New(l.loc.ID())
+	}
+
+	return d.Delete(id)
+}
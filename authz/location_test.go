@@ -0,0 +1,171 @@
+package authz
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/memory"
+	"github.com/src-d/go-borges/util"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newLocation(require *require.Assertions) *memory.Location {
+	loc, err := memory.NewLocation("foo", nil)
+	require.NoError(err)
+
+	for _, id := range []borges.RepositoryID{
+		"github.com/src-d/go-borges",
+		"public/foo",
+		"private/foo",
+	} {
+		_, err = loc.Init(id)
+		require.NoError(err)
+	}
+
+	return loc
+}
+
+func TestLocation_Get(t *testing.T) {
+	require := require.New(t)
+
+	loc := NewLocation(newLocation(require), newPolicy(require)).WithContext(withPrincipal("bob"))
+
+	r, err := loc.Get("public/foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	_, err = loc.Get("public/foo", borges.RWMode)
+	require.True(ErrPermissionDenied.Is(err))
+
+	_, err = loc.Init("public/bar")
+	require.True(ErrPermissionDenied.Is(err))
+
+	_, err = loc.GetOrInit("public/bar")
+	require.True(ErrPermissionDenied.Is(err))
+
+	has, err := loc.Has("private/foo")
+	require.NoError(err)
+	require.False(has)
+
+	has, err = loc.Has("public/foo")
+	require.NoError(err)
+	require.True(has)
+}
+
+func TestLocation_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	loc := NewLocation(newLocation(require), newPolicy(require)).WithContext(withPrincipal("nobody"))
+
+	iter, err := loc.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return r.Close()
+	})
+	require.NoError(err)
+	require.Equal([]borges.RepositoryID{"public/foo"}, ids)
+}
+
+func TestLocation_Delete(t *testing.T) {
+	require := require.New(t)
+
+	mem := newLocation(require)
+	policy := newPolicy(require)
+
+	loc := NewLocation(mem, policy).WithContext(withPrincipal("bob"))
+	err := loc.Delete("github.com/src-d/go-borges")
+	require.True(ErrPermissionDenied.Is(err))
+
+	loc = NewLocation(mem, policy).WithContext(withPrincipal("alice"))
+	require.NoError(loc.Delete("github.com/src-d/go-borges"))
+
+	has, err := mem.Has("github.com/src-d/go-borges")
+	require.NoError(err)
+	require.False(has)
+
+	loc = NewLocation(struct{ borges.Location }{mem}, policy).WithContext(withPrincipal("alice"))
+	err = loc.Delete("github.com/src-d/go-borges")
+	require.True(ErrDeleteNotSupported.Is(err))
+}
+
+func TestLocation_Hidden(t *testing.T) {
+	require := require.New(t)
+
+	policy, err := ParsePolicy(strings.NewReader(`{"rules": [{
+		"principals": ["dave"],
+		"repositories": ["github.com/**"],
+		"permissions": ["init", "delete"]
+	}]}`))
+	require.NoError(err)
+
+	loc := NewLocation(newLocation(require), policy).WithContext(withPrincipal("dave"))
+
+	// the same error is returned for the existing repositories.
+	for _, id := range []borges.RepositoryID{
+		"github.com/src-d/go-borges",
+		"github.com/src-d/go-git",
+	} {
+		_, err = loc.Init(id)
+		require.True(ErrPermissionDenied.Is(err), id)
+
+		_, err = loc.GetOrInit(id)
+		require.True(ErrPermissionDenied.Is(err), id)
+
+		err = loc.Delete(id)
+		require.True(ErrPermissionDenied.Is(err), id)
+	}
+}
+
+// modeLocation records the mode used to open every repository.
+type modeLocation struct {
+	borges.Location
+	opened map[borges.Mode][]borges.RepositoryID
+}
+
+func (l *modeLocation) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	l.opened[mode] = append(l.opened[mode], id)
+	return l.Location.Get(id, mode)
+}
+
+func (l *modeLocation) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	iter, err := l.Location.Repositories(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapRepositoryIterator(iter, func(r borges.Repository) (borges.Repository, error) {
+		l.opened[mode] = append(l.opened[mode], r.ID())
+		return r, nil
+	}), nil
+}
+
+func TestLocation_Repositories_RWMode(t *testing.T) {
+	require := require.New(t)
+
+	mem := &modeLocation{
+		Location: newLocation(require),
+		opened:   make(map[borges.Mode][]borges.RepositoryID),
+	}
+
+	loc := NewLocation(mem, newPolicy(require)).WithContext(withPrincipal("alice"))
+	iter, err := loc.Repositories(borges.RWMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		require.Equal(borges.RWMode, r.Mode())
+		ids = append(ids, r.ID())
+		return r.Close()
+	})
+	require.NoError(err)
+
+	expected := []borges.RepositoryID{"github.com/src-d/go-borges"}
+	require.Equal(expected, ids)
+	require.Equal(expected, mem.opened[borges.RWMode])
+	require.Len(mem.opened[borges.ReadOnlyMode], 3)
+}
@@ -0,0 +1,216 @@
+// Package authz provides borges.Library and borges.Location wrappers
+// checking the permissions of a principal over the repositories, following
+// the rules of a Policy.
+package authz
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+var (
+	// ErrPermissionDenied is returned when the principal doesn't have the
+	// permission required by an operation.
+	ErrPermissionDenied = errors.NewKind("permission denied: %q can't %s repository %s")
+	// ErrInvalidPolicy is returned when a policy can't be parsed.
+	ErrInvalidPolicy = errors.NewKind("invalid policy: %s")
+)
+
+// Permission is an operation over a repository.
+type Permission string
+
+const (
+	// Read allows to open a repository in ReadOnlyMode and to see it in
+	// Has and the iterators.
+	Read Permission = "read"
+	// Write allows to open a repository in RWMode.
+	Write Permission = "write"
+	// Init allows to initialize a new repository, along with Read.
+	Init Permission = "init"
+	// Delete allows to delete a repository, along with Read.
+	Delete Permission = "delete"
+)
+
+var permissions = map[Permission]bool{Read: true, Write: true, Init: true, Delete: true}
+
+// Rule grants permissions over the repositories matching any pattern to the
+// matching principals.
+type Rule struct {
+	// Principals are the principal names, "group:<name>" to match a group
+	// or "*" to match anyone.
+	Principals []string `json:"principals"`
+	// Repositories are the patterns of the RepositoryIDs, "*" matches any
+	// sequence of characters but "/" and "**" any number of elements.
+	// Eg.: github.com/src-d/* or github.com/**.
+	Repositories []string `json:"repositories"`
+	// Permissions are the granted permissions.
+	Permissions []Permission `json:"permissions"`
+}
+
+func (r *Rule) matchPrincipal(p borges.Principal) bool {
+	for _, name := range r.Principals {
+		if name == "*" || name == p.Name && p.Name != "" {
+			return true
+		}
+
+		if !strings.HasPrefix(name, "group:") {
+			continue
+		}
+
+		for _, g := range p.Groups {
+			if name[len("group:"):] == g {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (r *Rule) matchRepository(id borges.RepositoryID) bool {
+	for _, pattern := range r.Repositories {
+		if matchPattern(strings.Split(pattern, "/"), strings.Split(id.String(), "/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Rule) grants(perm Permission) bool {
+	for _, p := range r.Permissions {
+		if p == perm {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchPattern matches the elements of a RepositoryID against the elements
+// of a pattern, "**" matches zero or more elements.
+func matchPattern(pattern, elems []string) bool {
+	for len(pattern) != 0 {
+		if pattern[0] == "**" {
+			for i := len(elems); i >= 0; i-- {
+				if matchPattern(pattern[1:], elems[i:]) {
+					return true
+				}
+			}
+
+			return false
+		}
+
+		if len(elems) == 0 {
+			return false
+		}
+
+		if ok, err := path.Match(pattern[0], elems[0]); !ok || err != nil {
+			return false
+		}
+
+		pattern, elems = pattern[1:], elems[1:]
+	}
+
+	return len(elems) == 0
+}
+
+// Policy is a set of rules, any permission not granted by a rule is
+// denied.
+type Policy struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Validate checks the permissions and patterns of the rules.
+func (p *Policy) Validate() error {
+	for _, r := range p.Rules {
+		for _, perm := range r.Permissions {
+			if !permissions[perm] {
+				return ErrInvalidPolicy.New("unknown permission " + string(perm))
+			}
+		}
+
+		for _, pattern := range r.Repositories {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return ErrInvalidPolicy.New("bad pattern " + pattern)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Allowed returns true if any rule grants the permission over the
+// repository with the given RepositoryID to the Principal.
+func (p *Policy) Allowed(pr borges.Principal, id borges.RepositoryID, perm Permission) bool {
+	for _, r := range p.Rules {
+		if r.grants(perm) && r.matchPrincipal(pr) && r.matchRepository(id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// check returns ErrPermissionDenied if any of the permissions is denied.
+func (p *Policy) check(pr borges.Principal, id borges.RepositoryID, perms ...Permission) error {
+	for _, perm := range perms {
+		if !p.Allowed(pr, id, perm) {
+			return ErrPermissionDenied.New(pr.Name, perm, id)
+		}
+	}
+
+	return nil
+}
+
+// ParsePolicy reads a JSON policy like:
+//
+//	{"rules": [{
+//		"principals": ["alice", "group:analytics"],
+//		"repositories": ["github.com/src-d/**"],
+//		"permissions": ["read", "write"]
+//	}]}
+func ParsePolicy(r io.Reader) (*Policy, error) {
+	p := &Policy{}
+	if err := json.NewDecoder(r).Decode(p); err != nil {
+		return nil, ErrInvalidPolicy.Wrap(err, err.Error())
+	}
+
+	if err := p.Validate(); err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// LoadPolicy reads the JSON policy file at the given path.
+func LoadPolicy(path string) (*Policy, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return ParsePolicy(f)
+}
+
+// initPermissions are the permissions required to initialize a repository,
+// without read permission ErrRepositoryExists would reveal the existing ones.
+var initPermissions = []Permission{Init, Read}
+
+// modePermissions returns the permissions required to open a repository in
+// the given mode.
+func modePermissions(m borges.Mode) []Permission {
+	if m == borges.RWMode {
+		return []Permission{Read, Write}
+	}
+
+	return []Permission{Read}
+}
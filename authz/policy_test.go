@@ -0,0 +1,104 @@
+package authz
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testPolicy = `{"rules": [{
+	"principals": ["alice", "group:admins"],
+	"repositories": ["github.com/**"],
+	"permissions": ["read", "write", "init", "delete"]
+}, {
+	"principals": ["bob"],
+	"repositories": ["github.com/src-d/*"],
+	"permissions": ["read"]
+}, {
+	"principals": ["*"],
+	"repositories": ["public/*"],
+	"permissions": ["read"]
+}]}`
+
+func newPolicy(require *require.Assertions) *Policy {
+	p, err := ParsePolicy(strings.NewReader(testPolicy))
+	require.NoError(err)
+	return p
+}
+
+func TestPolicy_Allowed(t *testing.T) {
+	require := require.New(t)
+
+	p := newPolicy(require)
+
+	alice := borges.Principal{Name: "alice"}
+	bob := borges.Principal{Name: "bob"}
+	admin := borges.Principal{Name: "carol", Groups: []string{"admins"}}
+
+	tests := []struct {
+		p       borges.Principal
+		id      borges.RepositoryID
+		perm    Permission
+		allowed bool
+	}{
+		{alice, "github.com/src-d/go-borges", Write, true},
+		{alice, "github.com/src-d/go-borges/sub", Delete, true},
+		{alice, "gitlab.com/src-d/go-borges", Read, false},
+		{admin, "github.com/foo", Init, true},
+		{bob, "github.com/src-d/go-borges", Read, true},
+		{bob, "github.com/src-d/go-borges", Write, false},
+		{bob, "github.com/src-d/go-borges/sub", Read, false},
+		{borges.Principal{}, "public/foo", Read, true},
+		{borges.Principal{}, "public/foo/bar", Read, false},
+		{borges.Principal{}, "github.com/src-d/go-borges", Read, false},
+	}
+
+	for _, test := range tests {
+		require.Equal(
+			test.allowed,
+			p.Allowed(test.p, test.id, test.perm),
+			"%s %s %s", test.p.Name, test.perm, test.id,
+		)
+	}
+
+	err := p.check(bob, "github.com/src-d/go-borges", modePermissions(borges.RWMode)...)
+	require.True(ErrPermissionDenied.Is(err))
+}
+
+func TestParsePolicy_Invalid(t *testing.T) {
+	require := require.New(t)
+
+	_, err := ParsePolicy(strings.NewReader(`{"rules": [`))
+	require.True(ErrInvalidPolicy.Is(err))
+
+	_, err = ParsePolicy(strings.NewReader(
+		`{"rules": [{"principals": ["*"], "repositories": ["*"], "permissions": ["admin"]}]}`,
+	))
+	require.True(ErrInvalidPolicy.Is(err))
+
+	_, err = ParsePolicy(strings.NewReader(
+		`{"rules": [{"principals": ["*"], "repositories": ["["], "permissions": ["read"]}]}`,
+	))
+	require.True(ErrInvalidPolicy.Is(err))
+}
+
+func TestLoadPolicy(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "authz")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "policy.json")
+	require.NoError(ioutil.WriteFile(path, []byte(testPolicy), 0644))
+
+	p, err := LoadPolicy(path)
+	require.NoError(err)
+	require.Len(p.Rules, 3)
+}
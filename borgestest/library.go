@@ -0,0 +1,194 @@
+package borgestest
+
+import (
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/suite"
+)
+
+// LibrarySuite checks the behavior of a borges.Library implementation. The
+// repositories are created in the Locations returned by Library.Locations,
+// so the Library returned by NewLibrary must contain at least one empty
+// Location.
+type LibrarySuite struct {
+	suite.Suite
+	// NewLibrary returns a new Library, it's called on every test.
+	NewLibrary func() (borges.Library, error)
+	// Transactional defines if the Locations of the Library are
+	// transactional.
+	Transactional bool
+}
+
+func (s *LibrarySuite) library() borges.Library {
+	l, err := s.NewLibrary()
+	s.Require().NoError(err)
+	s.Require().NotNil(l)
+	return l
+}
+
+func (s *LibrarySuite) locations(l borges.Library) []borges.Location {
+	iter, err := l.Locations()
+	s.Require().NoError(err)
+
+	var locs []borges.Location
+	err = iter.ForEach(func(loc borges.Location) error {
+		locs = append(locs, loc)
+		return nil
+	})
+
+	s.Require().NoError(err)
+	s.Require().NotEmpty(locs, "the library must contain at least a location")
+	return locs
+}
+
+// initRepository initializes a repository at the given Location, committing
+// it in the case of transactional locations.
+func (s *LibrarySuite) initRepository(l borges.Location, id borges.RepositoryID) {
+	r, err := l.Init(id)
+	s.Require().NoError(err)
+
+	if s.Transactional {
+		s.Require().NoError(r.Commit())
+		return
+	}
+
+	s.Require().NoError(r.Close())
+}
+
+func (s *LibrarySuite) TestLocation() {
+	require := s.Require()
+	l := s.library()
+
+	for _, loc := range s.locations(l) {
+		found, err := l.Location(loc.ID())
+		require.NoError(err)
+		require.Equal(loc.ID(), found.ID())
+	}
+}
+
+func (s *LibrarySuite) TestLocation_NotFound() {
+	require := s.Require()
+	l := s.library()
+
+	loc, err := l.Location("borgestest-not-found")
+	require.True(borges.ErrLocationNotExists.Is(err))
+	require.Nil(loc)
+}
+
+func (s *LibrarySuite) TestLibraries() {
+	require := s.Require()
+	l := s.library()
+
+	iter, err := l.Libraries()
+	require.NoError(err)
+
+	err = iter.ForEach(func(lib borges.Library) error {
+		found, err := l.Library(lib.ID())
+		require.NoError(err)
+		require.Equal(lib.ID(), found.ID())
+		return nil
+	})
+
+	require.NoError(err)
+}
+
+func (s *LibrarySuite) TestLibrary_NotFound() {
+	require := s.Require()
+	l := s.library()
+
+	lib, err := l.Library("borgestest-not-found")
+	require.True(borges.ErrLibraryNotExists.Is(err))
+	require.Nil(lib)
+}
+
+func (s *LibrarySuite) TestHas() {
+	require := s.Require()
+	l := s.library()
+
+	locs := s.locations(l)
+	loc := locs[len(locs)-1]
+	s.initRepository(loc, "github.com/foo/bar")
+
+	ok, lib, locID, err := l.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(ok)
+	require.Equal(l.ID(), lib)
+	require.Equal(loc.ID(), locID)
+
+	ok, _, _, err = l.Has("github.com/foo/qux")
+	require.NoError(err)
+	require.False(ok)
+}
+
+func (s *LibrarySuite) TestGet() {
+	require := s.Require()
+	l := s.library()
+
+	locs := s.locations(l)
+	loc := locs[len(locs)-1]
+	s.initRepository(loc, "github.com/foo/bar")
+
+	for _, mode := range []borges.Mode{borges.RWMode, borges.ReadOnlyMode} {
+		r, err := l.Get("github.com/foo/bar", mode)
+		require.NoError(err)
+		require.Equal(borges.RepositoryID("github.com/foo/bar"), r.ID())
+		require.Equal(loc.ID(), r.LocationID())
+		require.Equal(mode, r.Mode())
+		require.NoError(r.Close())
+	}
+}
+
+func (s *LibrarySuite) TestGet_NotFound() {
+	require := s.Require()
+	l := s.library()
+
+	r, err := l.Get("github.com/foo/bar", borges.RWMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+	require.Nil(r)
+}
+
+func (s *LibrarySuite) TestInit() {
+	require := s.Require()
+	l := s.library()
+
+	r, err := l.Init("github.com/foo/bar")
+	if borges.ErrNotImplemented.Is(err) {
+		s.T().Skip("Init not implemented")
+	}
+
+	require.NoError(err)
+	require.Equal(borges.RepositoryID("github.com/foo/bar"), r.ID())
+	require.Equal(borges.RWMode, r.Mode())
+
+	if s.Transactional {
+		require.NoError(r.Commit())
+	}
+
+	_, err = l.Init("github.com/foo/bar")
+	require.True(borges.ErrRepositoryExists.Is(err))
+}
+
+func (s *LibrarySuite) TestRepositories() {
+	require := s.Require()
+	l := s.library()
+
+	var expected []borges.RepositoryID
+	for _, loc := range s.locations(l) {
+		id := borges.RepositoryID("github.com/foo/" + string(loc.ID()))
+		s.initRepository(loc, id)
+		expected = append(expected, id)
+	}
+
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		require.Equal(borges.ReadOnlyMode, r.Mode())
+		ids = append(ids, r.ID())
+		return nil
+	})
+
+	require.NoError(err)
+	require.ElementsMatch(expected, ids)
+}
@@ -0,0 +1,264 @@
+// Package borgestest provides testify suites to check that borges.Location
+// and borges.Library implementations honor the behavior described by the
+// interfaces.
+package borgestest
+
+import (
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// LocationSuite checks the behavior of a borges.Location implementation. It
+// can be run from the tests of the implementation:
+//
+//	func TestLocationSuite(t *testing.T) {
+//		suite.Run(t, &borgestest.LocationSuite{
+//			NewLocation: func() (borges.Location, error) {
+//				return NewLocation("foo", memfs.New(), nil)
+//			},
+//		})
+//	}
+type LocationSuite struct {
+	suite.Suite
+	// NewLocation returns a new empty Location, it's called on every test.
+	NewLocation func() (borges.Location, error)
+	// Transactional defines if the Locations returned by NewLocation are
+	// transactional, if so the repositories are only visible after Commit.
+	Transactional bool
+}
+
+func (s *LocationSuite) location() borges.Location {
+	l, err := s.NewLocation()
+	s.Require().NoError(err)
+	s.Require().NotNil(l)
+	return l
+}
+
+// initRepository initializes a repository, committing it in the case of
+// transactional locations.
+func (s *LocationSuite) initRepository(l borges.Location, id borges.RepositoryID) {
+	r, err := l.Init(id)
+	s.Require().NoError(err)
+
+	if s.Transactional {
+		s.Require().NoError(r.Commit())
+		return
+	}
+
+	s.Require().NoError(r.Close())
+}
+
+func (s *LocationSuite) TestInit() {
+	require := s.Require()
+	l := s.location()
+
+	r, err := l.Init("github.com/foo/bar")
+	require.NoError(err)
+	require.Equal(borges.RepositoryID("github.com/foo/bar"), r.ID())
+	require.Equal(l.ID(), r.LocationID())
+	require.Equal(borges.RWMode, r.Mode())
+	require.NotNil(r.R())
+
+	has, err := l.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.Equal(!s.Transactional, has)
+
+	if s.Transactional {
+		require.NoError(r.Commit())
+
+		has, err = l.Has("github.com/foo/bar")
+		require.NoError(err)
+		require.True(has)
+	}
+}
+
+func (s *LocationSuite) TestInit_Exists() {
+	require := s.Require()
+	l := s.location()
+
+	s.initRepository(l, "github.com/foo/bar")
+
+	r, err := l.Init("github.com/foo/bar")
+	require.True(borges.ErrRepositoryExists.Is(err))
+	require.Nil(r)
+}
+
+func (s *LocationSuite) TestHas_NotFound() {
+	require := s.Require()
+	l := s.location()
+
+	s.initRepository(l, "github.com/foo/bar")
+
+	has, err := l.Has("github.com/foo/qux")
+	require.NoError(err)
+	require.False(has)
+}
+
+func (s *LocationSuite) TestGet() {
+	require := s.Require()
+	l := s.location()
+
+	s.initRepository(l, "github.com/foo/bar")
+
+	for _, mode := range []borges.Mode{borges.RWMode, borges.ReadOnlyMode} {
+		r, err := l.Get("github.com/foo/bar", mode)
+		require.NoError(err)
+		require.Equal(borges.RepositoryID("github.com/foo/bar"), r.ID())
+		require.Equal(l.ID(), r.LocationID())
+		require.Equal(mode, r.Mode())
+		require.NoError(r.Close())
+	}
+}
+
+func (s *LocationSuite) TestGet_NotFound() {
+	require := s.Require()
+	l := s.location()
+
+	r, err := l.Get("github.com/foo/bar", borges.RWMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+	require.Nil(r)
+}
+
+func (s *LocationSuite) TestGet_ReadOnlyMode() {
+	require := s.Require()
+	l := s.location()
+
+	s.initRepository(l, "github.com/foo/bar")
+
+	r, err := l.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref := plumbing.NewHashReference("refs/heads/foo", plumbing.ZeroHash)
+	require.Error(r.R().Storer.SetReference(ref))
+
+	_, err = r.R().Storer.SetEncodedObject(r.R().Storer.NewEncodedObject())
+	require.Error(err)
+}
+
+func (s *LocationSuite) TestGetOrInit() {
+	require := s.Require()
+	l := s.location()
+
+	r, err := l.GetOrInit("github.com/foo/bar")
+	require.NoError(err)
+	require.Equal(borges.RWMode, r.Mode())
+
+	if s.Transactional {
+		require.NoError(r.Commit())
+	}
+
+	r, err = l.GetOrInit("github.com/foo/bar")
+	require.NoError(err)
+	require.Equal(borges.RepositoryID("github.com/foo/bar"), r.ID())
+	require.Equal(borges.RWMode, r.Mode())
+}
+
+func (s *LocationSuite) TestRepositories() {
+	require := s.Require()
+	l := s.location()
+
+	s.initRepository(l, "github.com/foo/bar")
+	s.initRepository(l, "github.com/foo/qux")
+	s.initRepository(l, "gitlab.com/foo/bar")
+
+	for _, mode := range []borges.Mode{borges.RWMode, borges.ReadOnlyMode} {
+		iter, err := l.Repositories(mode)
+		require.NoError(err)
+
+		var ids []borges.RepositoryID
+		err = iter.ForEach(func(r borges.Repository) error {
+			require.Equal(mode, r.Mode())
+			require.Equal(l.ID(), r.LocationID())
+			ids = append(ids, r.ID())
+			return nil
+		})
+
+		require.NoError(err)
+		require.ElementsMatch([]borges.RepositoryID{
+			"github.com/foo/bar",
+			"github.com/foo/qux",
+			"gitlab.com/foo/bar",
+		}, ids)
+	}
+}
+
+func (s *LocationSuite) TestRepositories_Stop() {
+	require := s.Require()
+	l := s.location()
+
+	s.initRepository(l, "github.com/foo/bar")
+	s.initRepository(l, "github.com/foo/qux")
+
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var count int
+	err = iter.ForEach(func(r borges.Repository) error {
+		count++
+		return borges.ErrStop
+	})
+
+	require.NoError(err)
+	require.Equal(1, count)
+}
+
+func (s *LocationSuite) TestCommit() {
+	require := s.Require()
+	l := s.location()
+
+	s.initRepository(l, "github.com/foo/bar")
+
+	r, err := l.Get("github.com/foo/bar", borges.RWMode)
+	require.NoError(err)
+
+	// the reference must point to an existing object, dangling references
+	// are rejected by some transactional locations.
+	obj := r.R().Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	h, err := r.R().Storer.SetEncodedObject(obj)
+	require.NoError(err)
+
+	ref := plumbing.NewHashReference("refs/heads/foo", h)
+	require.NoError(r.R().Storer.SetReference(ref))
+
+	err = r.Commit()
+	if !s.Transactional {
+		require.True(borges.ErrNonTransactional.Is(err))
+		require.NoError(r.Close())
+	} else {
+		require.NoError(err)
+	}
+
+	r, err = l.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref, err = r.R().Storer.Reference("refs/heads/foo")
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+}
+
+func (s *LocationSuite) TestClose() {
+	require := s.Require()
+	l := s.location()
+
+	s.initRepository(l, "github.com/foo/bar")
+
+	r, err := l.Get("github.com/foo/bar", borges.RWMode)
+	require.NoError(err)
+
+	ref := plumbing.NewHashReference("refs/heads/foo", plumbing.ZeroHash)
+	require.NoError(r.R().Storer.SetReference(ref))
+	require.NoError(r.Close())
+
+	r, err = l.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	_, err = r.R().Storer.Reference("refs/heads/foo")
+	if s.Transactional {
+		require.Equal(plumbing.ErrReferenceNotFound, err)
+	} else {
+		require.NoError(err)
+	}
+}
@@ -0,0 +1,64 @@
+package borgestest
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+// NewPlainLibrary returns a plain.Library "foo" with a memfs Location "foo"
+// containing the given repositories, the Location is also contained by the
+// sub Library "sub". It's the fixture shared by the tests of the Library
+// wrappers, without repositories it can be used by LibrarySuite.
+func NewPlainLibrary(
+	opts *plain.LocationOptions,
+	ids ...borges.RepositoryID,
+) (*plain.Library, error) {
+	if opts == nil {
+		opts = &plain.LocationOptions{}
+	}
+
+	loc, err := plain.NewLocation("foo", memfs.New(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, id := range ids {
+		r, err := loc.Init(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Transactional {
+			err = r.Commit()
+		} else {
+			err = r.Close()
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sub := plain.NewLibrary("sub")
+	sub.AddLocation(loc)
+
+	lib := plain.NewLibrary("foo")
+	lib.AddLocation(loc)
+	lib.AddLibrary(sub)
+	return lib, nil
+}
+
+// RequirePlainLibrary returns the Library built by NewPlainLibrary, failing
+// the test on error.
+func RequirePlainLibrary(
+	require *require.Assertions,
+	opts *plain.LocationOptions,
+	ids ...borges.RepositoryID,
+) *plain.Library {
+	lib, err := NewPlainLibrary(opts, ids...)
+	require.NoError(err)
+	return lib
+}
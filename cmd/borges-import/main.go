@@ -0,0 +1,197 @@
+// borges-import imports all the git repositories found in a directory tree
+// into a plain Location.
+//
+// Usage:
+//
+//	borges-import [flags] <source> <location>
+//
+// Every bare repository or working tree found under source is imported,
+// the RepositoryID is the path relative to source unless -origin is given.
+// If source is itself a repository its directory name is used.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/plain"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+)
+
+var mappers = map[string]plain.IDMapper{
+	"identity": plain.IdentityMapper{},
+	"escaped":  plain.EscapedMapper{},
+	"flat":     plain.FlatMapper{},
+	"sharded":  plain.ShardedMapper{},
+}
+
+func main() {
+	bare := flag.Bool("bare", true, "store the repositories as bare repositories")
+	layout := flag.String("layout", "identity", "location layout: identity, escaped, flat or sharded")
+	origin := flag.Bool("origin", false, "build the RepositoryIDs from the origin remote")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: %s [flags] <source> <location>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	mapper, ok := mappers[*layout]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown layout %q\n", *layout)
+		os.Exit(2)
+	}
+
+	l, err := plain.NewLocation("import", osfs.New(flag.Arg(1)), &plain.LocationOptions{
+		Bare:     *bare,
+		IDMapper: mapper,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	source, err := filepath.Abs(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	i := &importer{
+		l:      l,
+		src:    osfs.New(source),
+		root:   rootName(source),
+		origin: *origin,
+	}
+
+	i.walk("")
+
+	fmt.Printf("imported: %d, failed: %d\n", i.imported, i.failed)
+	if i.failed != 0 {
+		os.Exit(1)
+	}
+}
+
+type importer struct {
+	l   *plain.Location
+	src billy.Filesystem
+	// root is the name of the source directory, used when it's a
+	// repository.
+	root   string
+	origin bool
+
+	imported int
+	failed   int
+}
+
+// walk imports the repositories found under the given directory, the
+// directories that can't be read are reported and skipped.
+func (i *importer) walk(dir string) {
+	for _, bare := range []bool{true, false} {
+		is, err := plain.IsRepository(i.src, dir, bare)
+		if err != nil {
+			i.fail(dir, err)
+			return
+		}
+
+		if is {
+			i.importRepository(dir, bare)
+			return
+		}
+	}
+
+	entries, err := i.src.ReadDir(dir)
+	if err != nil {
+		i.fail(dir, err)
+		return
+	}
+
+	for _, e := range entries {
+		if e.IsDir() {
+			i.walk(i.src.Join(dir, e.Name()))
+		}
+	}
+}
+
+func (i *importer) fail(path string, err error) {
+	fmt.Fprintf(os.Stderr, "error importing %s: %s\n", path, err)
+	i.failed++
+}
+
+// errUnnamedRoot is returned when the source is a repository without a
+// directory name to use as RepositoryID.
+var errUnnamedRoot = errors.New("the source has no name to use as repository id, use -origin")
+
+func (i *importer) importRepository(path string, bare bool) {
+	name := path
+	if name == "" {
+		name = i.root
+	}
+
+	id := borges.RepositoryID(name)
+	if i.origin {
+		id = i.originID(path, bare, id)
+	}
+
+	if id == "" {
+		i.fail(flag.Arg(0), errUnnamedRoot)
+		return
+	}
+
+	if err := i.l.Import(i.src, path, id); err != nil {
+		i.fail(name, err)
+		return
+	}
+
+	fmt.Printf("%s -> %s\n", name, id)
+	i.imported++
+}
+
+// rootName returns the name of the source directory, empty if it's the
+// root of the filesystem.
+func rootName(source string) string {
+	name := filepath.Base(source)
+	if name == string(filepath.Separator) || name == "." {
+		return ""
+	}
+
+	return name
+}
+
+// originID returns the RepositoryID based on the origin remote of the
+// repository at path, falling back to the given one like the
+// LocationIterator does with plain.LocationOptions.IDFromOrigin.
+func (i *importer) originID(path string, bare bool, fallback borges.RepositoryID) borges.RepositoryID {
+	if !bare {
+		path = i.src.Join(path, ".git")
+	}
+
+	fs, err := i.src.Chroot(path)
+	if err != nil {
+		return fallback
+	}
+
+	cfg, err := filesystem.NewStorage(fs, cache.NewObjectLRUDefault()).Config()
+	if err != nil {
+		return fallback
+	}
+
+	id, err := plain.OriginID(cfg)
+	if err != nil {
+		return fallback
+	}
+
+	return id
+}
@@ -0,0 +1,265 @@
+// Package events provides a Bus to subscribe to the lifecycle events of the
+// repositories, and borges.Library and borges.Location wrappers publishing
+// them.
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// ErrVetoed is returned when a synchronous handler rejects an operation.
+var ErrVetoed = errors.NewKind("%s of repository %s vetoed")
+
+// Type is the type of an Event.
+type Type string
+
+const (
+	// Init is published when a repository is initialized.
+	Init Type = "init"
+	// Open is published when an existing repository is opened.
+	Open Type = "open"
+	// Commit is published when the changes of a repository are committed.
+	Commit Type = "commit"
+	// Close is published when a repository is closed.
+	Close Type = "close"
+	// Delete is published when a repository is deleted.
+	Delete Type = "delete"
+	// Move is published when a repository is moved to another Location.
+	Move Type = "move"
+)
+
+// Event is a change in the lifecycle of a repository.
+type Event struct {
+	Type Type
+	// Time when the event was published.
+	Time     time.Time
+	Library  borges.LibraryID
+	Location borges.LocationID
+	ID       borges.RepositoryID
+	Mode     borges.Mode
+	// Refs are the references changed by a Commit.
+	Refs []util.RefChange
+	// Discarded is set by Close when the repository is transactional, was
+	// opened in RWMode and closed with changed references not committed.
+	Discarded bool
+	// To is the destination Location of a Move.
+	To borges.LocationID
+}
+
+// Handler is a synchronous handler, it's called before the operation and
+// returning an error vetoes it. The errors returned for Close events don't
+// prevent the repository from being closed.
+type Handler func(*Event) error
+
+// AsyncHandler is an asynchronous handler, it's called by the dispatcher
+// once the operation succeeded.
+type AsyncHandler func(*Event)
+
+// DefaultBuffer is the default size of the queue of asynchronous events.
+const DefaultBuffer = 1024
+
+// Options contains configuration options for a Bus.
+type Options struct {
+	// Buffer is the size of the queue of asynchronous events, when the
+	// queue is full publishing blocks until there is room. By default
+	// DefaultBuffer.
+	Buffer int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *Options) Validate() error {
+	if o.Buffer <= 0 {
+		o.Buffer = DefaultBuffer
+	}
+
+	return nil
+}
+
+// Bus delivers the events to the subscribed handlers. Synchronous handlers
+// are called in subscription order, asynchronous handlers are called by a
+// single dispatcher goroutine in publication order.
+type Bus struct {
+	m      sync.RWMutex
+	sync   map[Type][]Handler
+	async  map[Type][]AsyncHandler
+	closed bool
+
+	queue chan queued
+	// stop is closed by Close, the dispatcher delivers the queued events
+	// and exits.
+	stop chan struct{}
+	done chan struct{}
+	now  func() time.Time
+}
+
+// NewBus returns a new Bus and starts its dispatcher, Close must be called
+// to stop it.
+func NewBus(opts *Options) (*Bus, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	b := &Bus{
+		sync:  make(map[Type][]Handler),
+		async: make(map[Type][]AsyncHandler),
+		queue: make(chan queued, opts.Buffer),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+		now:   time.Now,
+	}
+
+	go b.dispatch()
+	return b, nil
+}
+
+// Subscribe subscribes a synchronous handler to the given event types, or
+// to every type if none is given.
+func (b *Bus) Subscribe(h Handler, types ...Type) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	for _, t := range allTypes(types) {
+		b.sync[t] = append(b.sync[t], h)
+	}
+}
+
+// SubscribeAsync subscribes an asynchronous handler to the given event
+// types, or to every type if none is given.
+func (b *Bus) SubscribeAsync(h AsyncHandler, types ...Type) {
+	b.m.Lock()
+	defer b.m.Unlock()
+
+	for _, t := range allTypes(types) {
+		b.async[t] = append(b.async[t], h)
+	}
+}
+
+func allTypes(types []Type) []Type {
+	if len(types) != 0 {
+		return types
+	}
+
+	return []Type{Init, Open, Commit, Close, Delete, Move}
+}
+
+// Before calls the synchronous handlers of the event, it must be called
+// before performing the operation. The first error stops the calls and is
+// returned wrapped in ErrVetoed.
+func (b *Bus) Before(e *Event) error {
+	if e.Time.IsZero() {
+		e.Time = b.now()
+	}
+
+	b.m.RLock()
+	handlers := b.sync[e.Type]
+	b.m.RUnlock()
+
+	for _, h := range handlers {
+		if err := h(e); err != nil {
+			return ErrVetoed.Wrap(err, e.Type, e.ID)
+		}
+	}
+
+	return nil
+}
+
+// After queues the event for the asynchronous handlers, it must be called
+// once the operation succeeded. Events published after Close are dropped.
+// The lock isn't held while waiting for room in the queue, so the handlers
+// can use the Bus.
+func (b *Bus) After(e *Event) {
+	if e.Time.IsZero() {
+		e.Time = b.now()
+	}
+
+	b.m.RLock()
+	handlers := b.async[e.Type]
+	closed := b.closed
+	b.m.RUnlock()
+
+	if closed || len(handlers) == 0 {
+		return
+	}
+
+	select {
+	case b.queue <- queued{e: e, handlers: handlers}:
+	case <-b.stop:
+	}
+}
+
+// queued is an event with the handlers subscribed when it was published.
+type queued struct {
+	e        *Event
+	handlers []AsyncHandler
+}
+
+// Publish calls Before and, if the event isn't vetoed, After. It's meant for
+// events of operations already done.
+func (b *Bus) Publish(e *Event) error {
+	if err := b.Before(e); err != nil {
+		return err
+	}
+
+	b.After(e)
+	return nil
+}
+
+func (b *Bus) dispatch() {
+	defer close(b.done)
+
+	for {
+		select {
+		case q := <-b.queue:
+			q.deliver()
+		case <-b.stop:
+			b.drain()
+			return
+		}
+	}
+}
+
+// drain delivers the events already queued.
+func (b *Bus) drain() {
+	for {
+		select {
+		case q := <-b.queue:
+			q.deliver()
+		default:
+			return
+		}
+	}
+}
+
+func (q queued) deliver() {
+	for _, h := range q.handlers {
+		h(q.e)
+	}
+}
+
+// Close stops accepting asynchronous events and waits until the queued
+// ones are delivered. The queue is never closed, the publishers waiting for
+// room drop their events instead.
+func (b *Bus) Close() error {
+	b.m.Lock()
+	if b.closed {
+		b.m.Unlock()
+		return nil
+	}
+
+	b.closed = true
+	close(b.stop)
+	b.m.Unlock()
+
+	<-b.done
+	return nil
+}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newBus(require *require.Assertions) *Bus {
+	b, err := NewBus(nil)
+	require.NoError(err)
+	return b
+}
+
+func TestBus_Before(t *testing.T) {
+	require := require.New(t)
+
+	b := newBus(require)
+	defer b.Close()
+
+	var calls []string
+	b.Subscribe(func(e *Event) error {
+		calls = append(calls, "first")
+		return nil
+	}, Commit)
+	b.Subscribe(func(e *Event) error {
+		calls = append(calls, "second")
+		return errors.New("forbidden")
+	})
+	b.Subscribe(func(e *Event) error {
+		calls = append(calls, "third")
+		return nil
+	})
+
+	err := b.Before(&Event{Type: Commit, ID: "foo"})
+	require.True(ErrVetoed.Is(err))
+	require.Contains(err.Error(), "forbidden")
+	require.Equal([]string{"first", "second"}, calls)
+
+	calls = nil
+	err = b.Before(&Event{Type: Open, ID: "foo"})
+	require.True(ErrVetoed.Is(err))
+	require.Equal([]string{"second"}, calls)
+}
+
+func TestBus_After(t *testing.T) {
+	require := require.New(t)
+
+	b, err := NewBus(&Options{Buffer: 1})
+	require.NoError(err)
+
+	var m sync.Mutex
+	var ids []string
+	b.SubscribeAsync(func(e *Event) {
+		m.Lock()
+		defer m.Unlock()
+		ids = append(ids, string(e.ID))
+	}, Init)
+
+	for _, id := range []string{"foo", "bar", "baz"} {
+		b.After(&Event{Type: Init, ID: borges.RepositoryID(id)})
+	}
+
+	b.After(&Event{Type: Open, ID: "qux"})
+	require.NoError(b.Close())
+	require.Equal([]string{"foo", "bar", "baz"}, ids)
+
+	b.After(&Event{Type: Init, ID: "qux"})
+	require.NoError(b.Close())
+}
+
+func TestBus_After_Reentrant(t *testing.T) {
+	require := require.New(t)
+
+	b, err := NewBus(&Options{Buffer: 1})
+	require.NoError(err)
+
+	var subscribed int
+	b.SubscribeAsync(func(e *Event) {
+		// gives time to fill the queue, so After is waiting for room while
+		// subscribing takes the write lock.
+		time.Sleep(10 * time.Millisecond)
+		b.Subscribe(func(*Event) error { return nil })
+		subscribed++
+	}, Init)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 10; i++ {
+			b.After(&Event{Type: Init, ID: "foo"})
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		require.FailNow("deadlock publishing events")
+	}
+
+	require.NoError(b.Close())
+	require.Equal(10, subscribed)
+}
+
+func TestBus_Publish(t *testing.T) {
+	require := require.New(t)
+
+	b := newBus(require)
+
+	var async int
+	b.SubscribeAsync(func(e *Event) { async++ })
+	b.Subscribe(func(e *Event) error {
+		if e.ID == "bar" {
+			return errors.New("no")
+		}
+
+		return nil
+	})
+
+	require.NoError(b.Publish(&Event{Type: Move, ID: "foo"}))
+	require.True(ErrVetoed.Is(b.Publish(&Event{Type: Move, ID: "bar"})))
+	require.NoError(b.Close())
+	require.Equal(1, async)
+}
@@ -0,0 +1,178 @@
+package events
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+)
+
+// publisher publishes the events of a Library or Location.
+type publisher struct {
+	bus *Bus
+	lib borges.LibraryID
+}
+
+// open publishes the events of an operation opening a repository, the
+// returned Repository publishes its commit and close.
+func (p *publisher) open(
+	t Type,
+	id borges.RepositoryID,
+	mode borges.Mode,
+	loc borges.LocationID,
+	fn func() (borges.Repository, error),
+) (borges.Repository, error) {
+	e := &Event{Type: t, Library: p.lib, Location: loc, ID: id, Mode: mode}
+	if err := p.bus.Before(e); err != nil {
+		return nil, err
+	}
+
+	r, err := fn()
+	if err != nil {
+		return nil, err
+	}
+
+	er, err := newRepository(p, r)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	e.Location = r.LocationID()
+	p.bus.After(e)
+	return er, nil
+}
+
+func (p *publisher) repositories(iter borges.RepositoryIterator) borges.RepositoryIterator {
+	return util.NewMapRepositoryIterator(iter, func(r borges.Repository) (borges.Repository, error) {
+		er, err := p.open(Open, r.ID(), r.Mode(), r.LocationID(), func() (borges.Repository, error) {
+			return r, nil
+		})
+
+		// a vetoed repository was never wrapped, so it must be closed here.
+		if ErrVetoed.Is(err) {
+			r.Close()
+		}
+
+		return er, err
+	})
+}
+
+// Library wraps a borges.Library publishing the lifecycle events of its
+// repositories. Every location, library and repository returned by it is
+// wrapped too.
+type Library struct {
+	lib borges.Library
+	p   *publisher
+}
+
+var _ borges.Library = (*Library)(nil)
+
+// NewLibrary returns a new Library wrapping the given one and publishing the
+// events to the given Bus.
+func NewLibrary(lib borges.Library, bus *Bus) *Library {
+	return &Library{lib: lib, p: &publisher{bus: bus, lib: lib.ID()}}
+}
+
+// ID returns the borges.LibraryID of the wrapped Library.
+func (l *Library) ID() borges.LibraryID {
+	return l.lib.ID()
+}
+
+// Init initializes a new Repository publishing an Init event.
+func (l *Library) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return l.p.open(Init, id, borges.RWMode, "", func() (borges.Repository, error) {
+		return l.lib.Init(id)
+	})
+}
+
+// GetOrInit opens the repository publishing an Open event if it exists,
+// otherwise it's initialized publishing an Init event.
+func (l *Library) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	has, _, _, err := l.lib.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return l.Get(id, borges.RWMode)
+	}
+
+	return l.Init(id)
+}
+
+// Get opens the repository with the given RepositoryID publishing an Open
+// event.
+func (l *Library) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	return l.p.open(Open, id, mode, "", func() (borges.Repository, error) {
+		return l.lib.Get(id, mode)
+	})
+}
+
+// Has returns true, the LibraryID and the LocationID if the given
+// RepositoryID matches any repository of the wrapped Library.
+func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	return l.lib.Has(id)
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Library publishing an Open event for each
+// one.
+func (l *Library) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	iter, err := l.lib.Repositories(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.p.repositories(iter), nil
+}
+
+// Location returns the Location with the given LocationID wrapped to
+// publish its events.
+func (l *Library) Location(id borges.LocationID) (borges.Location, error) {
+	loc, err := l.lib.Location(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.location(loc), nil
+}
+
+// Locations returns a LocationIterator that iterates through all the
+// locations of the wrapped Library wrapped to publish their events.
+func (l *Library) Locations() (borges.LocationIterator, error) {
+	iter, err := l.lib.Locations()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLocationIterator(iter, func(loc borges.Location) (borges.Location, error) {
+		return l.location(loc), nil
+	}), nil
+}
+
+// Library returns the Library with the given LibraryID wrapped to publish
+// its events.
+func (l *Library) Library(id borges.LibraryID) (borges.Library, error) {
+	lib, err := l.lib.Library(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLibrary(lib, l.p.bus), nil
+}
+
+// Libraries returns a LibraryIterator that iterates through all the
+// libraries of the wrapped Library wrapped to publish their events.
+func (l *Library) Libraries() (borges.LibraryIterator, error) {
+	iter, err := l.lib.Libraries()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLibraryIterator(iter, func(lib borges.Library) (borges.Library, error) {
+		return NewLibrary(lib, l.p.bus), nil
+	}), nil
+}
+
+func (l *Library) location(loc borges.Location) *Location {
+	return &Location{loc: loc, p: l.p}
+}
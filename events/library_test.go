@@ -0,0 +1,147 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// recorder subscribes to every event of a Bus keeping them in order.
+type recorder struct {
+	events []*Event
+}
+
+func newRecorder(b *Bus) *recorder {
+	r := &recorder{}
+	b.Subscribe(func(e *Event) error {
+		r.events = append(r.events, e)
+		return nil
+	})
+
+	return r
+}
+
+func (r *recorder) types() []Type {
+	var types []Type
+	for _, e := range r.events {
+		types = append(types, e.Type)
+	}
+
+	return types
+}
+
+func newLibrary(require *require.Assertions, transactional bool) *plain.Library {
+	return borgestest.RequirePlainLibrary(require, &plain.LocationOptions{
+		Transactional: transactional,
+	}, "github.com/foo/bar")
+}
+
+func TestLibrary_Get(t *testing.T) {
+	require := require.New(t)
+
+	bus := newBus(require)
+	defer bus.Close()
+
+	rec := newRecorder(bus)
+	lib := NewLibrary(newLibrary(require, true), bus)
+
+	r, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.IsType(&Repository{}, r)
+	require.NoError(r.Close())
+
+	require.Equal([]Type{Open, Close}, rec.types())
+	for _, e := range rec.events {
+		require.Equal(borges.LibraryID("foo"), e.Library)
+		require.Equal(borges.LocationID("foo"), e.Location)
+		require.Equal(borges.RepositoryID("github.com/foo/bar"), e.ID)
+		require.Equal(borges.ReadOnlyMode, e.Mode)
+		require.False(e.Discarded)
+	}
+
+	bus.Subscribe(func(e *Event) error {
+		return borges.ErrRepositoryNotExists.New(e.ID)
+	}, Open)
+
+	_, err = lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.True(ErrVetoed.Is(err))
+}
+
+func TestLibrary_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	bus := newBus(require)
+	defer bus.Close()
+
+	rec := newRecorder(bus)
+	lib := NewLibrary(newLibrary(require, true), bus)
+
+	iter, err := lib.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	err = iter.ForEach(func(r borges.Repository) error {
+		return r.Close()
+	})
+	require.NoError(err)
+	require.Equal([]Type{Open, Close}, rec.types())
+
+	sub, err := lib.Library("sub")
+	require.NoError(err)
+	require.IsType(&Library{}, sub)
+
+	loc, err := lib.Location("foo")
+	require.NoError(err)
+	require.IsType(&Location{}, loc)
+}
+
+func TestLibrary_Discarded(t *testing.T) {
+	require := require.New(t)
+
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	for _, transactional := range []bool{false, true} {
+		bus := newBus(require)
+		rec := newRecorder(bus)
+		lib := NewLibrary(newLibrary(require, transactional), bus)
+
+		r, err := lib.Get("github.com/foo/bar", borges.RWMode)
+		require.NoError(err)
+		ref := plumbing.NewHashReference("refs/heads/master", h)
+		require.NoError(r.R().Storer.SetReference(ref))
+		require.NoError(r.Close())
+
+		// the changes of non transactional repositories are already written.
+		require.Equal([]Type{Open, Close}, rec.types())
+		require.Equal(transactional, rec.events[1].Discarded)
+		require.NoError(bus.Close())
+	}
+}
+
+func TestLibrarySuite(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LibrarySuite{
+			NewLibrary: func() (borges.Library, error) {
+				lib, err := borgestest.NewPlainLibrary(&plain.LocationOptions{
+					Transactional: transactional,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				bus, err := NewBus(nil)
+				if err != nil {
+					return nil, err
+				}
+
+				return NewLibrary(lib, bus), nil
+			},
+			Transactional: transactional,
+		})
+	}
+}
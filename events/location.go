@@ -0,0 +1,97 @@
+package events
+
+import (
+	"github.com/src-d/go-borges"
+)
+
+// Location wraps a borges.Location publishing the lifecycle events of its
+// repositories.
+type Location struct {
+	loc borges.Location
+	p   *publisher
+}
+
+var _ borges.Location = (*Location)(nil)
+
+// NewLocation returns a new Location wrapping the given one and publishing
+// the events to the given Bus.
+func NewLocation(loc borges.Location, bus *Bus) *Location {
+	return &Location{loc: loc, p: &publisher{bus: bus}}
+}
+
+// ID returns the ID of the wrapped Location.
+func (l *Location) ID() borges.LocationID {
+	return l.loc.ID()
+}
+
+// Init initializes a new Repository publishing an Init event.
+func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return l.p.open(Init, id, borges.RWMode, l.loc.ID(), func() (borges.Repository, error) {
+		return l.loc.Init(id)
+	})
+}
+
+// GetOrInit opens the repository publishing an Open event if it exists,
+// otherwise it's initialized publishing an Init event.
+func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	has, err := l.loc.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return l.Get(id, borges.RWMode)
+	}
+
+	return l.Init(id)
+}
+
+// Has returns true if the given RepositoryID matches any repository of the
+// wrapped Location.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	return l.loc.Has(id)
+}
+
+// Get opens the repository with the given RepositoryID publishing an Open
+// event.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	return l.p.open(Open, id, mode, l.loc.ID(), func() (borges.Repository, error) {
+		return l.loc.Get(id, mode)
+	})
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Location publishing an Open event for each
+// one.
+func (l *Location) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	iter, err := l.loc.Repositories(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.p.repositories(iter), nil
+}
+
+// Delete removes the repository with the given RepositoryID publishing a
+// Delete event. If the wrapped Location doesn't implement Delete
+// ErrNotImplemented is returned.
+func (l *Location) Delete(id borges.RepositoryID) error {
+	d, ok := l.loc.(interface {
+		Delete(borges.RepositoryID) error
+	})
+	if !ok {
+		return borges.ErrNotImplemented.New()
+	}
+
+	e := &Event{Type: Delete, Library: l.p.lib, Location: l.loc.ID(), ID: id}
+	if err := l.p.bus.Before(e); err != nil {
+		return err
+	}
+
+	if err := d.Delete(id); err != nil {
+		return err
+	}
+
+	l.p.bus.After(e)
+	return nil
+}
@@ -0,0 +1,80 @@
+package events
+
+import (
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newLocation(require *require.Assertions) *memory.Location {
+	loc, err := memory.NewLocation("mem", &memory.LocationOptions{Transactional: true})
+	require.NoError(err)
+	return loc
+}
+
+func TestLocation_Init(t *testing.T) {
+	require := require.New(t)
+
+	bus := newBus(require)
+
+	var m sync.Mutex
+	var indexed []borges.RepositoryID
+	bus.SubscribeAsync(func(e *Event) {
+		m.Lock()
+		defer m.Unlock()
+		indexed = append(indexed, e.ID)
+	}, Init, Commit)
+
+	rec := newRecorder(bus)
+	loc := NewLocation(newLocation(require), bus)
+
+	r, err := loc.Init("foo")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	_, err = loc.Init("foo")
+	require.True(borges.ErrRepositoryExists.Is(err))
+
+	r, err = loc.GetOrInit("bar")
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	require.NoError(bus.Close())
+	require.Equal([]Type{Init, Commit, Init, Init, Close}, rec.types())
+	require.Equal([]borges.RepositoryID{"foo", "foo", "bar"}, indexed)
+}
+
+func TestLocation_Delete(t *testing.T) {
+	require := require.New(t)
+
+	bus := newBus(require)
+	defer bus.Close()
+
+	mem := newLocation(require)
+	r, err := mem.Init("foo")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	loc := NewLocation(mem, bus)
+	bus.Subscribe(func(e *Event) error {
+		if e.ID == "foo" {
+			return errors.New("protected")
+		}
+
+		return nil
+	}, Delete)
+
+	require.True(ErrVetoed.Is(loc.Delete("foo")))
+
+	has, err := mem.Has("foo")
+	require.NoError(err)
+	require.True(has)
+
+	loc = NewLocation(struct{ borges.Location }{mem}, bus)
+	require.True(borges.ErrNotImplemented.Is(loc.Delete("bar")))
+}
@@ -0,0 +1,103 @@
+package events
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// Repository wraps a borges.Repository publishing its Commit and Close
+// events. The references of repositories opened in RWMode are saved when
+// opened to compute the changed references.
+type Repository struct {
+	borges.Repository
+	p    *publisher
+	refs map[plumbing.ReferenceName]plumbing.Hash
+}
+
+func newRepository(p *publisher, r borges.Repository) (*Repository, error) {
+	er := &Repository{Repository: r, p: p}
+	if r.Mode() != borges.RWMode {
+		return er, nil
+	}
+
+	refs, err := util.References(r.R().Storer)
+	if err != nil {
+		return nil, err
+	}
+
+	er.refs = refs
+	return er, nil
+}
+
+// Commit publishes a Commit event with the references changed since the
+// repository was opened or last committed, and persists the changes if no
+// handler vetoes it.
+func (r *Repository) Commit() error {
+	e := r.event(Commit)
+
+	var refs map[plumbing.ReferenceName]plumbing.Hash
+	if r.refs != nil {
+		var err error
+		refs, err = util.References(r.R().Storer)
+		if err != nil {
+			return err
+		}
+
+		e.Refs = util.DiffReferences(r.refs, refs)
+	}
+
+	if err := r.p.bus.Before(e); err != nil {
+		return err
+	}
+
+	if err := r.Repository.Commit(); err != nil {
+		return err
+	}
+
+	if refs != nil {
+		r.refs = refs
+	}
+
+	r.p.bus.After(e)
+	return nil
+}
+
+// Close closes the wrapped Repository publishing a Close event. The errors
+// of the synchronous handlers are returned but the repository is closed
+// anyway.
+func (r *Repository) Close() error {
+	e := r.event(Close)
+	if r.refs != nil && transactional(r.R().Storer) {
+		refs, err := util.References(r.R().Storer)
+		e.Discarded = err == nil && len(util.DiffReferences(r.refs, refs)) != 0
+	}
+
+	herr := r.p.bus.Before(e)
+	if err := r.Repository.Close(); err != nil {
+		return err
+	}
+
+	r.p.bus.After(e)
+	return herr
+}
+
+// transactional returns true if the changes written to the storer are only
+// persisted on Commit, like with transactional.Storage, the rest of storers
+// persist them on every write.
+func transactional(s storer.Storer) bool {
+	_, ok := s.(interface{ Commit() error })
+	return ok
+}
+
+func (r *Repository) event(t Type) *Event {
+	return &Event{
+		Type:     t,
+		Library:  r.p.lib,
+		Location: r.LocationID(),
+		ID:       r.ID(),
+		Mode:     r.Mode(),
+	}
+}
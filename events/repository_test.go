@@ -0,0 +1,59 @@
+package events
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestRepository_Commit(t *testing.T) {
+	require := require.New(t)
+
+	bus := newBus(require)
+	defer bus.Close()
+
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	bus.Subscribe(func(e *Event) error {
+		for _, ref := range e.Refs {
+			if ref.Name == "refs/heads/forbidden" {
+				return errors.New("forbidden ref")
+			}
+		}
+
+		return nil
+	}, Commit)
+
+	rec := newRecorder(bus)
+	mem := newLocation(require)
+	loc := NewLocation(mem, bus)
+
+	r, err := loc.Init("foo")
+	require.NoError(err)
+	require.NoError(r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/master", h)))
+	require.NoError(r.Commit())
+
+	require.Equal([]util.RefChange{
+		{Name: "refs/heads/master", New: h},
+	}, rec.events[1].Refs)
+
+	r, err = loc.Get("foo", borges.RWMode)
+	require.NoError(err)
+	require.NoError(r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/forbidden", h)))
+	require.True(ErrVetoed.Is(r.Commit()))
+	require.NoError(r.Close())
+
+	// the vetoing handler was subscribed first, so the recorder doesn't see
+	// the rejected commit.
+	require.Equal([]Type{Init, Commit, Open, Close}, rec.types())
+	require.True(rec.events[3].Discarded)
+
+	mr, err := mem.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	_, err = mr.R().Reference("refs/heads/forbidden", false)
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+}
@@ -0,0 +1,412 @@
+// Package fetcher keeps the repositories of a borges.Library in sync with
+// their remotes.
+package fetcher
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+var (
+	// ErrRemoteNotFound is returned by OriginResolver when the repository
+	// doesn't have an origin remote.
+	ErrRemoteNotFound = errors.NewKind("repository %s has no remote %q")
+	// ErrFetch wraps the last error returned fetching a repository.
+	ErrFetch = errors.NewKind("error fetching repository %s from %s")
+)
+
+// DefaultRefSpecs are the RefSpecs used when Options.RefSpecs is empty, the
+// branches and tags of the remote are mirrored in the repository.
+var DefaultRefSpecs = []config.RefSpec{
+	"+refs/heads/*:refs/heads/*",
+	"+refs/tags/*:refs/tags/*",
+}
+
+// Resolver returns the URL the given repository should be fetched from.
+type Resolver func(borges.Repository) (string, error)
+
+// OriginResolver returns the first URL of the origin remote of the
+// repository.
+func OriginResolver(r borges.Repository) (string, error) {
+	cfg, err := r.R().Config()
+	if err != nil {
+		return "", err
+	}
+
+	remote, ok := cfg.Remotes["origin"]
+	if !ok || len(remote.URLs) == 0 {
+		return "", ErrRemoteNotFound.New(r.ID(), "origin")
+	}
+
+	return remote.URLs[0], nil
+}
+
+// TemplateResolver returns a Resolver building the URLs from the
+// RepositoryIDs with the given URLTemplates and protocol. If t is nil
+// borges.DefaultURLTemplates are used.
+func TemplateResolver(t *borges.URLTemplates, protocol string) Resolver {
+	if t == nil {
+		t = borges.DefaultURLTemplates
+	}
+
+	return func(r borges.Repository) (string, error) {
+		return t.URL(r.ID(), protocol)
+	}
+}
+
+// Options contains configuration options for a Fetcher.
+type Options struct {
+	// Resolver returns the URL of each repository, by default OriginResolver.
+	Resolver Resolver
+	// Workers is the number of repositories fetched concurrently, by default
+	// DefaultWorkers.
+	Workers int
+	// HostInterval is the minimum time between the start of two fetches to
+	// the same host, zero means no limit.
+	HostInterval time.Duration
+	// Retries is the number of times a failed fetch is retried.
+	Retries int
+	// Backoff is the time waited before the first retry, it's doubled on
+	// every retry up to MaxBackoff. By default DefaultBackoff.
+	Backoff time.Duration
+	// MaxBackoff is the maximum time waited between retries, by default
+	// DefaultMaxBackoff.
+	MaxBackoff time.Duration
+	// RefSpecs are the RefSpecs used to fetch, by default DefaultRefSpecs.
+	RefSpecs []config.RefSpec
+	// Auth is the authentication method used for every remote.
+	Auth transport.AuthMethod
+}
+
+const (
+	// DefaultWorkers is the default value of Options.Workers.
+	DefaultWorkers = 4
+	// DefaultBackoff is the default value of Options.Backoff.
+	DefaultBackoff = time.Second
+	// DefaultMaxBackoff is the default value of Options.MaxBackoff.
+	DefaultMaxBackoff = time.Minute
+)
+
+// Validate validates the fields and sets the default values.
+func (o *Options) Validate() error {
+	if o.Resolver == nil {
+		o.Resolver = OriginResolver
+	}
+
+	if o.Workers <= 0 {
+		o.Workers = DefaultWorkers
+	}
+
+	if o.Backoff == 0 {
+		o.Backoff = DefaultBackoff
+	}
+
+	if o.MaxBackoff == 0 {
+		o.MaxBackoff = DefaultMaxBackoff
+	}
+
+	if len(o.RefSpecs) == 0 {
+		o.RefSpecs = DefaultRefSpecs
+	}
+
+	for _, rs := range o.RefSpecs {
+		if err := rs.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Result is the outcome of the fetch of a repository.
+type Result struct {
+	// ID is the RepositoryID of the repository.
+	ID borges.RepositoryID
+	// URL is the URL the repository was fetched from.
+	URL string
+	// Updated is true if any reference was updated and the changes were
+	// committed.
+	Updated bool
+	// Attempts is the number of fetches done.
+	Attempts int
+	// Duration is the time spent including the retries.
+	Duration time.Duration
+	// Err is the error of the last attempt, nil on success.
+	Err error
+}
+
+// Fetcher fetches the repositories of a Library. The repositories are
+// opened in RWMode and committed after every successful fetch, in
+// transactional locations the changes of a failed fetch are discarded.
+type Fetcher struct {
+	lib     borges.Library
+	opts    *Options
+	limiter *limiter
+}
+
+// New returns a new Fetcher for the given Library with the given Options.
+func New(lib borges.Library, opts *Options) (*Fetcher, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Fetcher{
+		lib:     lib,
+		opts:    opts,
+		limiter: newLimiter(opts.HostInterval),
+	}, nil
+}
+
+// Fetch fetches the repositories with the given RepositoryIDs, the Results
+// are returned in the same order.
+func (f *Fetcher) Fetch(ids ...borges.RepositoryID) []*Result {
+	return f.FetchContext(context.Background(), ids...)
+}
+
+// FetchContext is Fetch stopping when the given context is done, the fetches
+// in progress are cancelled and the Results of the repositories not fetched
+// contain the error of the context.
+func (f *Fetcher) FetchContext(ctx context.Context, ids ...borges.RepositoryID) []*Result {
+	results := make([]*Result, len(ids))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < f.opts.Workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := range jobs {
+				results[j] = f.fetch(ctx, ids[j])
+			}
+		}()
+	}
+
+	for i := range ids {
+		jobs <- i
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	return results
+}
+
+// FetchAll fetches all the repositories contained in the Library.
+func (f *Fetcher) FetchAll() ([]*Result, error) {
+	return f.FetchAllContext(context.Background())
+}
+
+// FetchAllContext is FetchAll stopping when the given context is done.
+func (f *Fetcher) FetchAllContext(ctx context.Context) ([]*Result, error) {
+	iter, err := f.lib.Repositories(borges.ReadOnlyMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return r.Close()
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return f.FetchContext(ctx, ids...), nil
+}
+
+func (f *Fetcher) fetch(ctx context.Context, id borges.RepositoryID) *Result {
+	start := time.Now()
+	res := &Result{ID: id}
+	defer func() { res.Duration = time.Since(start) }()
+
+	backoff := f.opts.Backoff
+	for {
+		if res.Err = ctx.Err(); res.Err != nil {
+			return res
+		}
+
+		res.Attempts++
+
+		var retry bool
+		res.Updated, retry, res.Err = f.attempt(ctx, res)
+		if res.Err == nil || !retry || res.Attempts > f.opts.Retries {
+			return res
+		}
+
+		if !sleep(ctx, backoff) {
+			return res
+		}
+
+		if backoff *= 2; backoff > f.opts.MaxBackoff {
+			backoff = f.opts.MaxBackoff
+		}
+	}
+}
+
+// sleep waits for the given duration, it returns false if the context is
+// done before.
+func sleep(ctx context.Context, d time.Duration) bool {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-t.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// attempt fetches the repository once, it returns if the error is worth a
+// retry. The repository is only reported as updated if the changes are
+// committed.
+func (f *Fetcher) attempt(ctx context.Context, res *Result) (updated, retry bool, err error) {
+	r, err := f.lib.Get(res.ID, borges.RWMode)
+	if err != nil {
+		return false, false, err
+	}
+
+	res.URL, err = f.opts.Resolver(r)
+	if err != nil {
+		r.Close()
+		return false, false, err
+	}
+
+	if err := f.limiter.wait(ctx, res.URL); err != nil {
+		r.Close()
+		return false, false, err
+	}
+
+	updated, err = FetchRepositoryContext(ctx, r, res.URL, &git.FetchOptions{
+		RefSpecs: f.opts.RefSpecs,
+		Auth:     f.opts.Auth,
+	})
+
+	if err != nil {
+		r.Close()
+		if ctx.Err() != nil {
+			return false, false, ctx.Err()
+		}
+
+		return false, isTemporary(err), ErrFetch.Wrap(err, res.ID, res.URL)
+	}
+
+	err = r.Commit()
+	if borges.ErrNonTransactional.Is(err) {
+		return updated, false, r.Close()
+	}
+
+	if err != nil {
+		return false, false, err
+	}
+
+	return updated, false, nil
+}
+
+// FetchRepository fetches the given repository from the given URL, the
+// remote and RemoteName are ignored and the ones in opts are used. It
+// doesn't commit the changes. It returns true if any reference was updated.
+func FetchRepository(r borges.Repository, url string, opts *git.FetchOptions) (bool, error) {
+	return FetchRepositoryContext(context.Background(), r, url, opts)
+}
+
+// FetchRepositoryContext is FetchRepository cancelling the fetch when the
+// given context is done.
+func FetchRepositoryContext(
+	ctx context.Context,
+	r borges.Repository,
+	url string,
+	opts *git.FetchOptions,
+) (bool, error) {
+	o := *opts
+	o.RemoteName = "fetcher"
+	if len(o.RefSpecs) == 0 {
+		o.RefSpecs = DefaultRefSpecs
+	}
+
+	remote := git.NewRemote(r.R().Storer, &config.RemoteConfig{
+		Name:  o.RemoteName,
+		URLs:  []string{url},
+		Fetch: o.RefSpecs,
+	})
+
+	err := remote.FetchContext(ctx, &o)
+	if err == git.NoErrAlreadyUpToDate {
+		return false, nil
+	}
+
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// isTemporary returns false for the errors that won't be fixed retrying.
+func isTemporary(err error) bool {
+	switch err {
+	case transport.ErrRepositoryNotFound,
+		transport.ErrEmptyRemoteRepository,
+		transport.ErrAuthenticationRequired,
+		transport.ErrAuthorizationFailed,
+		transport.ErrInvalidAuthMethod:
+		return false
+	default:
+		return true
+	}
+}
+
+// limiter enforces a minimum interval between operations on the same host.
+type limiter struct {
+	interval time.Duration
+
+	m    sync.Mutex
+	next map[string]time.Time
+}
+
+func newLimiter(interval time.Duration) *limiter {
+	return &limiter{interval: interval, next: make(map[string]time.Time)}
+}
+
+// wait blocks until an operation on the host of the given URL is allowed,
+// it returns the error of the context if it's done before.
+func (l *limiter) wait(ctx context.Context, url string) error {
+	if l.interval <= 0 {
+		return nil
+	}
+
+	var host string
+	if e, err := transport.NewEndpoint(url); err == nil {
+		host = e.Host
+	}
+
+	l.m.Lock()
+	now := time.Now()
+	at := l.next[host]
+	if at.Before(now) {
+		at = now
+	}
+
+	l.next[host] = at.Add(l.interval)
+	l.m.Unlock()
+
+	if !sleep(ctx, at.Sub(now)) {
+		return ctx.Err()
+	}
+
+	return nil
+}
@@ -0,0 +1,339 @@
+package fetcher
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+)
+
+func init() {
+	// the remotes are served in-process, without requiring git binaries.
+	client.InstallProtocol("file", server.DefaultServer)
+	client.InstallProtocol("flaky", flaky)
+}
+
+// flakyTransport fails the configured number of upload-pack sessions for
+// each path before serving them.
+type flakyTransport struct {
+	m     sync.Mutex
+	fails map[string]int
+}
+
+var flaky = &flakyTransport{fails: make(map[string]int)}
+
+func (t *flakyTransport) failures(path string, n int) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.fails[path] = n
+}
+
+func (t *flakyTransport) NewUploadPackSession(
+	e *transport.Endpoint,
+	a transport.AuthMethod,
+) (transport.UploadPackSession, error) {
+	t.m.Lock()
+	n := t.fails[e.Path]
+	if n > 0 {
+		t.fails[e.Path] = n - 1
+	}
+	t.m.Unlock()
+
+	if n > 0 {
+		return nil, fmt.Errorf("connection reset")
+	}
+
+	return server.DefaultServer.NewUploadPackSession(e, a)
+}
+
+func (t *flakyTransport) NewReceivePackSession(
+	e *transport.Endpoint,
+	a transport.AuthMethod,
+) (transport.ReceivePackSession, error) {
+	return server.DefaultServer.NewReceivePackSession(e, a)
+}
+
+var fixtureHead = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+func setup(
+	require *require.Assertions,
+	transactional bool,
+	ids ...borges.RepositoryID,
+) (*plain.Library, string) {
+	return setupWithOptions(require, &plain.LocationOptions{
+		Transactional: transactional,
+	}, ids...)
+}
+
+func setupWithOptions(
+	require *require.Assertions,
+	opts *plain.LocationOptions,
+	ids ...borges.RepositoryID,
+) (*plain.Library, string) {
+	fixtures.Init()
+
+	// memfs can't be used concurrently, the repositories are fetched by
+	// several workers.
+	dir, err := ioutil.TempDir("", "fetcher")
+	require.NoError(err)
+	tmp, err := ioutil.TempDir("", "fetcher-tmp")
+	require.NoError(err)
+
+	opts.TemporalFilesystem = osfs.New(tmp)
+	loc, err := plain.NewLocation("foo", osfs.New(dir), opts)
+	require.NoError(err)
+
+	for _, id := range ids {
+		r, err := loc.Init(id)
+		require.NoError(err)
+
+		if opts.Transactional {
+			require.NoError(r.Commit())
+			continue
+		}
+
+		require.NoError(r.Close())
+	}
+
+	lib := plain.NewLibrary("foo")
+	lib.AddLocation(loc)
+
+	return lib, fixtures.Basic().One().DotGit().Root()
+}
+
+func requireFetched(require *require.Assertions, lib borges.Library, id borges.RepositoryID) {
+	r, err := lib.Get(id, borges.ReadOnlyMode)
+	require.NoError(err)
+	defer r.Close()
+
+	ref, err := r.R().Reference(plumbing.Master, false)
+	require.NoError(err)
+	require.Equal(fixtureHead, ref.Hash())
+}
+
+func TestFetcher_Fetch(t *testing.T) {
+	for _, transactional := range []bool{true, false} {
+		require := require.New(t)
+
+		lib, url := setup(require, transactional, "foo", "bar")
+		f, err := New(lib, &Options{
+			Resolver: func(borges.Repository) (string, error) { return url, nil },
+		})
+		require.NoError(err)
+
+		results := f.Fetch("foo", "bar", "baz")
+		require.Len(results, 3)
+
+		for i, id := range []borges.RepositoryID{"foo", "bar"} {
+			require.NoError(results[i].Err)
+			require.Equal(id, results[i].ID)
+			require.Equal(url, results[i].URL)
+			require.True(results[i].Updated)
+			require.Equal(1, results[i].Attempts)
+			requireFetched(require, lib, id)
+		}
+
+		require.True(borges.ErrRepositoryNotExists.Is(results[2].Err))
+		require.Equal(1, results[2].Attempts)
+
+		results = f.Fetch("foo")
+		require.NoError(results[0].Err)
+		require.False(results[0].Updated)
+	}
+}
+
+func TestFetcher_FetchAll(t *testing.T) {
+	require := require.New(t)
+
+	lib, url := setup(require, true, "foo", "bar", "baz")
+	f, err := New(lib, &Options{
+		Resolver: func(borges.Repository) (string, error) { return url, nil },
+		Workers:  2,
+	})
+	require.NoError(err)
+
+	results, err := f.FetchAll()
+	require.NoError(err)
+	require.Len(results, 3)
+
+	var ids []borges.RepositoryID
+	for _, res := range results {
+		require.NoError(res.Err)
+		ids = append(ids, res.ID)
+		requireFetched(require, lib, res.ID)
+	}
+
+	require.ElementsMatch([]borges.RepositoryID{"foo", "bar", "baz"}, ids)
+}
+
+func TestFetcher_Retries(t *testing.T) {
+	require := require.New(t)
+
+	lib, path := setup(require, true, "foo", "bar")
+	url := "flaky://example.com" + path
+	flaky.failures(path, 2)
+
+	f, err := New(lib, &Options{
+		Resolver: func(borges.Repository) (string, error) { return url, nil },
+		Workers:  1,
+		Retries:  2,
+		Backoff:  time.Millisecond,
+	})
+	require.NoError(err)
+
+	results := f.Fetch("foo")
+	require.NoError(results[0].Err)
+	require.Equal(3, results[0].Attempts)
+	requireFetched(require, lib, "foo")
+
+	flaky.failures(path, 3)
+	results = f.Fetch("bar")
+	require.True(ErrFetch.Is(results[0].Err))
+	require.Equal(3, results[0].Attempts)
+
+	r, err := lib.Get("bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	_, err = r.R().Reference(plumbing.Master, false)
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+}
+
+func TestFetcher_NotFound(t *testing.T) {
+	require := require.New(t)
+
+	lib, path := setup(require, true, "foo")
+	f, err := New(lib, &Options{
+		Resolver: func(borges.Repository) (string, error) {
+			return path + "-not-found", nil
+		},
+		Retries: 3,
+		Backoff: time.Millisecond,
+	})
+	require.NoError(err)
+
+	results := f.Fetch("foo")
+	require.True(ErrFetch.Is(results[0].Err))
+	require.Equal(1, results[0].Attempts)
+}
+
+func TestFetcher_CommitFailed(t *testing.T) {
+	require := require.New(t)
+
+	var reject bool
+	lib, url := setupWithOptions(require, &plain.LocationOptions{
+		Transactional: true,
+		Validators: []plain.Validator{func(*plain.Changes) error {
+			if reject {
+				return fmt.Errorf("rejected")
+			}
+
+			return nil
+		}},
+	}, "foo")
+
+	reject = true
+	f, err := New(lib, &Options{
+		Resolver: func(borges.Repository) (string, error) { return url, nil },
+	})
+	require.NoError(err)
+
+	results := f.Fetch("foo")
+	require.True(plain.ErrValidation.Is(results[0].Err))
+	require.False(results[0].Updated)
+}
+
+func TestFetcher_FetchContext(t *testing.T) {
+	require := require.New(t)
+
+	lib, url := setup(require, true, "foo", "bar")
+	f, err := New(lib, &Options{
+		Resolver: func(borges.Repository) (string, error) { return url, nil },
+	})
+	require.NoError(err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := f.FetchContext(ctx, "foo", "bar")
+	for _, res := range results {
+		require.Equal(context.Canceled, res.Err)
+		require.Equal(0, res.Attempts)
+		require.False(res.Updated)
+	}
+}
+
+func TestOriginResolver(t *testing.T) {
+	require := require.New(t)
+
+	lib, _ := setup(require, false, "github.com/foo/bar")
+	r, err := lib.Get("github.com/foo/bar", borges.RWMode)
+	require.NoError(err)
+
+	url, err := OriginResolver(r)
+	require.NoError(err)
+	require.Equal("github.com/foo/bar", url)
+
+	require.NoError(r.R().DeleteRemote("origin"))
+	_, err = OriginResolver(r)
+	require.True(ErrRemoteNotFound.Is(err))
+}
+
+func TestTemplateResolver(t *testing.T) {
+	require := require.New(t)
+
+	lib, _ := setup(require, false, "github.com/foo/bar.git")
+	r, err := lib.Get("github.com/foo/bar.git", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	url, err := TemplateResolver(nil, "ssh")(r)
+	require.NoError(err)
+	require.Equal("git@github.com:foo/bar.git", url)
+}
+
+func TestLimiter(t *testing.T) {
+	require := require.New(t)
+
+	l := newLimiter(50 * time.Millisecond)
+	ctx := context.Background()
+
+	start := time.Now()
+	require.NoError(l.wait(ctx, "https://github.com/foo/bar"))
+	require.NoError(l.wait(ctx, "https://gitlab.com/foo/bar"))
+	require.True(time.Since(start) < 50*time.Millisecond)
+
+	require.NoError(l.wait(ctx, "git@github.com:foo/qux"))
+	require.NoError(l.wait(ctx, "https://github.com/foo/baz"))
+	require.True(time.Since(start) >= 100*time.Millisecond)
+
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+	require.Equal(context.Canceled, l.wait(ctx, "https://github.com/foo/quux"))
+}
+
+func TestOptions_Validate(t *testing.T) {
+	require := require.New(t)
+
+	opts := &Options{}
+	require.NoError(opts.Validate())
+	require.NotNil(opts.Resolver)
+	require.Equal(DefaultWorkers, opts.Workers)
+	require.Equal(DefaultBackoff, opts.Backoff)
+	require.Equal(DefaultMaxBackoff, opts.MaxBackoff)
+	require.Equal(DefaultRefSpecs, opts.RefSpecs)
+
+	opts = &Options{RefSpecs: []config.RefSpec{"foo"}}
+	require.Error(opts.Validate())
+}
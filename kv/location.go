@@ -0,0 +1,162 @@
+package kv
+
+import (
+	"io"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// LocationOptions contains configuration options for a kv.Location.
+type LocationOptions struct {
+	// Transactional defines if the write operations are done in a transactional
+	// mode or not. The pending writes are kept in memory and persisted with a
+	// single bolt transaction on Repository.Commit. Otherwise every write is
+	// a bolt transaction, except the packfiles of the fetches that are
+	// written with one transaction each.
+	Transactional bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *LocationOptions) Validate() error {
+	return nil
+}
+
+// Location implements borges.Location for repositories stored in a bbolt
+// database, every repository is stored in a top level bucket named after its
+// RepositoryID.
+type Location struct {
+	id   borges.LocationID
+	db   *bolt.DB
+	opts *LocationOptions
+}
+
+// NewLocation returns a new Location based on the given ID and bolt database
+// with the given LocationOptions.
+func NewLocation(id borges.LocationID, db *bolt.DB, opts *LocationOptions) (*Location, error) {
+	if opts == nil {
+		opts = &LocationOptions{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Location{id: id, db: db, opts: opts}, nil
+}
+
+// ID returns the ID for this Location.
+func (l *Location) ID() borges.LocationID {
+	return l.id
+}
+
+// GetOrInit get the requested repository based on the given id, or inits a
+// new repository. If the repository is opened this will be done in RWMode.
+func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	has, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return l.Get(id, borges.RWMode)
+	}
+
+	return l.Init(id)
+}
+
+// Init initializes a new Repository at this Location.
+func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	has, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return nil, borges.ErrRepositoryExists.New(id)
+	}
+
+	return initRepository(l, id)
+}
+
+// Has returns true if the given RepositoryID matches any repository at this
+// location.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	var has bool
+	err := l.db.View(func(tx *bolt.Tx) error {
+		has = tx.Bucket([]byte(id)) != nil
+		return nil
+	})
+
+	return has, err
+}
+
+// Get open a repository with the given RepositoryID, this operation doesn't
+// perform any read operation. If a repository with the given RepositoryID
+// doesn't exists ErrRepositoryNotExists is returned.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	has, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !has {
+		return nil, borges.ErrRepositoryNotExists.New(id)
+	}
+
+	return openRepository(l, id, mode)
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories contained in this Location.
+func (l *Location) Repositories(m borges.Mode) (borges.RepositoryIterator, error) {
+	return NewLocationIterator(l, m)
+}
+
+// LocationIterator iterates all the repositories contained in a Location.
+type LocationIterator struct {
+	l   *Location
+	m   borges.Mode
+	ids []borges.RepositoryID
+}
+
+// NewLocationIterator returns a new LocationIterator for a given Location.
+func NewLocationIterator(l *Location, m borges.Mode) (*LocationIterator, error) {
+	var ids []borges.RepositoryID
+	err := l.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			ids = append(ids, borges.RepositoryID(name))
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &LocationIterator{l: l, m: m, ids: ids}, nil
+}
+
+// Next returns the next repository from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *LocationIterator) Next() (borges.Repository, error) {
+	if len(iter.ids) == 0 {
+		return nil, io.EOF
+	}
+
+	var id borges.RepositoryID
+	id, iter.ids = iter.ids[0], iter.ids[1:]
+	return openRepository(iter.l, id, iter.m)
+}
+
+// ForEach call the function for each object contained on this iter until an
+// error happens or the end of the iter is reached. If ErrStop is sent the
+// iteration is stop but no error is returned. The iterator is closed.
+func (iter *LocationIterator) ForEach(cb func(borges.Repository) error) error {
+	return util.ForEachRepositoryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *LocationIterator) Close() {}
@@ -0,0 +1,158 @@
+package kv
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/util"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestLocation(t *testing.T) {
+	require := require.New(t)
+
+	id, err := borges.NewRepositoryID("http://github.com/foo/bar")
+	require.NoError(err)
+
+	var location borges.Location
+	location, err = NewLocation("foo", newDB(require), nil)
+	require.NoError(err)
+
+	r, err := location.Init(id)
+	require.NoError(err)
+	require.NotNil(r)
+
+	iter, err := location.Repositories(borges.RWMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return nil
+	})
+
+	require.NoError(err)
+	require.ElementsMatch(ids, []borges.RepositoryID{
+		"github.com/foo/bar.git",
+	})
+}
+
+func TestLocation_Init(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", newDB(require), nil)
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+	require.NotNil(r)
+
+	remote, err := r.R().Remote("origin")
+	require.NoError(err)
+	require.Equal([]string{"github.com/foo/bar"}, remote.Config().URLs)
+
+	has, err := location.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(has)
+
+	r, err = location.Init("github.com/foo/bar")
+	require.True(borges.ErrRepositoryExists.Is(err))
+	require.Nil(r)
+}
+
+func TestLocation_Get(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", newDB(require), nil)
+	require.NoError(err)
+
+	_, err = location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	r, err := location.Get("github.com/foo/bar", borges.RWMode)
+	require.NoError(err)
+	require.Equal(borges.LocationID("foo"), r.LocationID())
+	require.Equal(borges.RepositoryID("github.com/foo/bar"), r.ID())
+
+	r, err = location.Get("github.com/foo/qux", borges.RWMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+	require.Nil(r)
+}
+
+func TestLocation_Get_ReadOnlyMode(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", newDB(require), nil)
+	require.NoError(err)
+
+	_, err = location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	r, err := location.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("foo", plumbing.ZeroHash))
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	err = r.Commit()
+	require.True(borges.ErrNonTransactional.Is(err))
+}
+
+func TestLocation_GetOrInit(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", newDB(require), nil)
+	require.NoError(err)
+
+	r, err := location.GetOrInit("github.com/foo/bar")
+	require.NoError(err)
+	require.NotNil(r)
+
+	r, err = location.GetOrInit("github.com/foo/bar")
+	require.NoError(err)
+	require.NotNil(r)
+}
+
+func TestLocation_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", newDB(require), nil)
+	require.NoError(err)
+
+	_, err = location.Init("github.com/foo/bar")
+	require.NoError(err)
+	_, err = location.Init("github.com/foo/qux")
+	require.NoError(err)
+
+	iter, err := location.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		require.Equal(borges.ReadOnlyMode, r.Mode())
+		ids = append(ids, r.ID())
+		return nil
+	})
+
+	require.NoError(err)
+	require.ElementsMatch(ids, []borges.RepositoryID{
+		"github.com/foo/bar",
+		"github.com/foo/qux",
+	})
+}
+
+func TestLocationSuite(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LocationSuite{
+			NewLocation: func() (borges.Location, error) {
+				return NewLocation("foo", newDB(require.New(t)), &LocationOptions{Transactional: transactional})
+			},
+			Transactional: transactional,
+		})
+	}
+}
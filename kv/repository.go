@@ -0,0 +1,143 @@
+package kv
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"gopkg.in/src-d/go-git.v4/storage/transactional"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// Repository represents a git repository stored in a bbolt database.
+type Repository struct {
+	id   borges.RepositoryID
+	l    *Location
+	mode borges.Mode
+	s    *Storage
+
+	*git.Repository
+}
+
+func initRepository(l *Location, id borges.RepositoryID) (*Repository, error) {
+	s, base, err := repositoryStorer(l, id, borges.RWMode)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := git.Init(s, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{id.String()},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		id:         id,
+		l:          l,
+		mode:       borges.RWMode,
+		s:          base,
+		Repository: r,
+	}, nil
+}
+
+// openRepository, is the basic operation of open a repository without any checking.
+func openRepository(l *Location, id borges.RepositoryID, mode borges.Mode) (*Repository, error) {
+	s, base, err := repositoryStorer(l, id, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := git.Open(s, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		id:         id,
+		l:          l,
+		mode:       mode,
+		s:          base,
+		Repository: r,
+	}, nil
+}
+
+func repositoryStorer(l *Location, id borges.RepositoryID, mode borges.Mode) (
+	storage.Storer, *Storage, error) {
+
+	s := NewStorage(l.db, id.String())
+
+	switch mode {
+	case borges.ReadOnlyMode:
+		return &util.ReadOnlyStorer{s}, s, nil
+	case borges.RWMode:
+		if l.opts.Transactional {
+			return transactional.NewStorage(s, memory.NewStorage()), s, nil
+		}
+
+		return s, s, nil
+	default:
+		return nil, nil, borges.ErrModeNotSupported.New(mode)
+	}
+}
+
+// R returns the git.Repository.
+func (r *Repository) R() *git.Repository {
+	return r.Repository
+}
+
+// ID returns the RepositoryID.
+func (r *Repository) ID() borges.RepositoryID {
+	return r.id
+}
+
+// LocationID returns the LocationID from the Location where it was retrieved.
+func (r *Repository) LocationID() borges.LocationID {
+	return r.l.ID()
+}
+
+// Mode returns the Mode how it was opened.
+func (r *Repository) Mode() borges.Mode {
+	return r.mode
+}
+
+// Close closes the repository, if the repository was opened in transactional
+// Mode, will delete any write operation pending to be written.
+func (r *Repository) Close() error {
+	return nil
+}
+
+// Commit persists all the write operations done since was open in a single
+// bolt transaction, if the repository wasn't opened in a Location with
+// Transactions enable returns ErrNonTransactional.
+func (r *Repository) Commit() (err error) {
+	if !r.l.opts.Transactional || r.mode != borges.RWMode {
+		return borges.ErrNonTransactional.New()
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	ts, ok := r.Storer.(*transactional.Storage)
+	if !ok {
+		panic("unreachable code")
+	}
+
+	err = r.l.db.Update(func(tx *bolt.Tx) error {
+		r.s.tx = tx
+		defer func() { r.s.tx = nil }()
+
+		return ts.Commit()
+	})
+
+	return
+}
@@ -0,0 +1,82 @@
+package kv
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestRepository_Commit(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", newDB(require), &LocationOptions{
+		Transactional: true,
+	})
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	has, err := location.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.False(has)
+
+	h := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/foo", h))
+	require.NoError(err)
+
+	require.NoError(r.Commit())
+
+	r, err = location.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref, err := r.R().Storer.Reference("refs/heads/foo")
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+
+	remote, err := r.R().Remote("origin")
+	require.NoError(err)
+	require.Equal([]string{"github.com/foo/bar"}, remote.Config().URLs)
+}
+
+func TestRepository_Close_Transactional(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", newDB(require), &LocationOptions{
+		Transactional: true,
+	})
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	r, err = location.Get("github.com/foo/bar", borges.RWMode)
+	require.NoError(err)
+
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/foo", plumbing.ZeroHash))
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	r, err = location.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	_, err = r.R().Storer.Reference("refs/heads/foo")
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+}
+
+func TestRepository_Commit_OnNonTransactional(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", newDB(require), nil)
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	err = r.Commit()
+	require.True(borges.ErrNonTransactional.Is(err))
+}
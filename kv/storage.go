@@ -0,0 +1,488 @@
+package kv
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+)
+
+var (
+	objectsBucket = []byte("objects")
+	refsBucket    = []byte("refs")
+	modulesBucket = []byte("modules")
+
+	configKey  = []byte("config")
+	indexKey   = []byte("index")
+	shallowKey = []byte("shallow")
+)
+
+// Storage is a storage.Storer for a single repository stored in a bbolt
+// database. Every repository is kept in a top level bucket named after its
+// RepositoryID, containing a bucket for the objects, other for the references
+// and the config, index and shallow keys.
+type Storage struct {
+	db   *bolt.DB
+	path [][]byte
+	// tx when not nil is used by every operation instead of opening a new
+	// bolt transaction, it's used to commit a transactional repository as
+	// a single bolt transaction.
+	tx *bolt.Tx
+}
+
+// NewStorage returns a new Storage for the repository with the given name in
+// the given database. The repository bucket is created with the first write.
+func NewStorage(db *bolt.DB, name string) *Storage {
+	return &Storage{db: db, path: [][]byte{[]byte(name)}}
+}
+
+func (s *Storage) view(fn func(*bolt.Bucket) error) error {
+	if s.tx != nil {
+		return fn(lookupBucket(s.tx, s.path))
+	}
+
+	return s.db.View(func(tx *bolt.Tx) error {
+		return fn(lookupBucket(tx, s.path))
+	})
+}
+
+func (s *Storage) update(fn func(*bolt.Bucket) error) error {
+	do := func(tx *bolt.Tx) error {
+		b, err := createBucket(tx, s.path)
+		if err != nil {
+			return err
+		}
+
+		return fn(b)
+	}
+
+	if s.tx != nil {
+		return do(s.tx)
+	}
+
+	return s.db.Update(do)
+}
+
+// lookupBucket returns the bucket at the given path or nil if any of the
+// buckets doesn't exist.
+func lookupBucket(tx *bolt.Tx, path [][]byte) *bolt.Bucket {
+	b := tx.Bucket(path[0])
+	for _, name := range path[1:] {
+		if b == nil {
+			return nil
+		}
+
+		b = b.Bucket(name)
+	}
+
+	return b
+}
+
+func createBucket(tx *bolt.Tx, path [][]byte) (*bolt.Bucket, error) {
+	b, err := tx.CreateBucketIfNotExists(path[0])
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range path[1:] {
+		b, err = b.CreateBucketIfNotExists(name)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return b, nil
+}
+
+func subBucket(b *bolt.Bucket, name []byte) *bolt.Bucket {
+	if b == nil {
+		return nil
+	}
+
+	return b.Bucket(name)
+}
+
+func get(b *bolt.Bucket, key []byte) []byte {
+	if b == nil {
+		return nil
+	}
+
+	return b.Get(key)
+}
+
+// NewEncodedObject returns a new plumbing.MemoryObject.
+func (s *Storage) NewEncodedObject() plumbing.EncodedObject {
+	return &plumbing.MemoryObject{}
+}
+
+// SetEncodedObject saves an object into the storage. Unless the repository
+// is being committed every object is written in its own bolt transaction,
+// the objects of a fetch are written with PackfileWriter instead.
+func (s *Storage) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	r, err := obj.Reader()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	defer r.Close()
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	h := obj.Hash()
+	value := append([]byte{byte(obj.Type())}, content...)
+	err = s.update(func(b *bolt.Bucket) error {
+		objects, err := b.CreateBucketIfNotExists(objectsBucket)
+		if err != nil {
+			return err
+		}
+
+		return objects.Put(h[:], value)
+	})
+
+	return h, err
+}
+
+// PackfileWriter honors the storer.PackfileWriter interface. The packfile is
+// kept in memory and its objects are written in a single bolt transaction
+// once the returned writer is closed, so the fetches don't sync the database
+// on every object.
+func (s *Storage) PackfileWriter() (io.WriteCloser, error) {
+	return &packfileWriter{s: s}, nil
+}
+
+type packfileWriter struct {
+	bytes.Buffer
+	s *Storage
+}
+
+// Close writes the objects of the packfile.
+func (w *packfileWriter) Close() error {
+	if w.Len() == 0 {
+		return nil
+	}
+
+	parse := func(s *Storage) error {
+		scanner := packfile.NewScanner(bytes.NewReader(w.Bytes()))
+		p, err := packfile.NewParserWithStorage(scanner, s)
+		if err != nil {
+			return err
+		}
+
+		_, err = p.Parse()
+		return err
+	}
+
+	if w.s.tx != nil {
+		return parse(w.s)
+	}
+
+	return w.s.db.Update(func(tx *bolt.Tx) error {
+		return parse(&Storage{db: w.s.db, path: w.s.path, tx: tx})
+	})
+}
+
+// EncodedObject gets an object by hash with the given plumbing.ObjectType.
+func (s *Storage) EncodedObject(t plumbing.ObjectType, h plumbing.Hash) (plumbing.EncodedObject, error) {
+	var obj plumbing.EncodedObject
+	err := s.view(func(b *bolt.Bucket) error {
+		value := get(subBucket(b, objectsBucket), h[:])
+		if value == nil {
+			return plumbing.ErrObjectNotFound
+		}
+
+		obj = decodeObject(value)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if t != plumbing.AnyObject && obj.Type() != t {
+		return nil, plumbing.ErrObjectNotFound
+	}
+
+	return obj, nil
+}
+
+func decodeObject(value []byte) plumbing.EncodedObject {
+	obj := &plumbing.MemoryObject{}
+	obj.SetType(plumbing.ObjectType(value[0]))
+	obj.Write(value[1:])
+	return obj
+}
+
+// IterEncodedObjects returns an iterator for all the objects of the given
+// plumbing.ObjectType in the storage.
+func (s *Storage) IterEncodedObjects(t plumbing.ObjectType) (storer.EncodedObjectIter, error) {
+	var objs []plumbing.EncodedObject
+	err := s.view(func(b *bolt.Bucket) error {
+		objects := subBucket(b, objectsBucket)
+		if objects == nil {
+			return nil
+		}
+
+		return objects.ForEach(func(_, value []byte) error {
+			obj := decodeObject(value)
+			if t == plumbing.AnyObject || obj.Type() == t {
+				objs = append(objs, obj)
+			}
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewEncodedObjectSliceIter(objs), nil
+}
+
+// HasEncodedObject returns plumbing.ErrObjectNotFound if the object doesn't
+// exist.
+func (s *Storage) HasEncodedObject(h plumbing.Hash) error {
+	return s.view(func(b *bolt.Bucket) error {
+		if get(subBucket(b, objectsBucket), h[:]) == nil {
+			return plumbing.ErrObjectNotFound
+		}
+
+		return nil
+	})
+}
+
+// EncodedObjectSize returns the plaintext size of the encoded object.
+func (s *Storage) EncodedObjectSize(h plumbing.Hash) (size int64, err error) {
+	err = s.view(func(b *bolt.Bucket) error {
+		value := get(subBucket(b, objectsBucket), h[:])
+		if value == nil {
+			return plumbing.ErrObjectNotFound
+		}
+
+		size = int64(len(value) - 1)
+		return nil
+	})
+
+	return
+}
+
+// SetReference stores the given reference.
+func (s *Storage) SetReference(ref *plumbing.Reference) error {
+	return s.update(func(b *bolt.Bucket) error {
+		return putReference(b, ref)
+	})
+}
+
+// CheckAndSetReference stores the given reference if the stored one matches
+// the old reference, otherwise storage.ErrReferenceHasChanged is returned.
+func (s *Storage) CheckAndSetReference(ref, old *plumbing.Reference) error {
+	return s.update(func(b *bolt.Bucket) error {
+		if old != nil {
+			current := getReference(b, ref.Name())
+			if current != nil && current.Hash() != old.Hash() {
+				return storage.ErrReferenceHasChanged
+			}
+		}
+
+		return putReference(b, ref)
+	})
+}
+
+func putReference(b *bolt.Bucket, ref *plumbing.Reference) error {
+	refs, err := b.CreateBucketIfNotExists(refsBucket)
+	if err != nil {
+		return err
+	}
+
+	return refs.Put([]byte(ref.Name()), []byte(ref.Strings()[1]))
+}
+
+func getReference(b *bolt.Bucket, n plumbing.ReferenceName) *plumbing.Reference {
+	target := get(subBucket(b, refsBucket), []byte(n))
+	if target == nil {
+		return nil
+	}
+
+	return plumbing.NewReferenceFromStrings(string(n), string(target))
+}
+
+// Reference returns the reference with the given name, if it doesn't exist
+// plumbing.ErrReferenceNotFound is returned.
+func (s *Storage) Reference(n plumbing.ReferenceName) (*plumbing.Reference, error) {
+	var ref *plumbing.Reference
+	err := s.view(func(b *bolt.Bucket) error {
+		ref = getReference(b, n)
+		if ref == nil {
+			return plumbing.ErrReferenceNotFound
+		}
+
+		return nil
+	})
+
+	return ref, err
+}
+
+// IterReferences returns an iterator for all the references in the storage.
+func (s *Storage) IterReferences() (storer.ReferenceIter, error) {
+	var refs []*plumbing.Reference
+	err := s.view(func(b *bolt.Bucket) error {
+		bucket := subBucket(b, refsBucket)
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(name, target []byte) error {
+			refs = append(refs, plumbing.NewReferenceFromStrings(
+				string(name), string(target),
+			))
+
+			return nil
+		})
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return storer.NewReferenceSliceIter(refs), nil
+}
+
+// RemoveReference deletes the reference with the given name.
+func (s *Storage) RemoveReference(n plumbing.ReferenceName) error {
+	return s.update(func(b *bolt.Bucket) error {
+		refs := b.Bucket(refsBucket)
+		if refs == nil {
+			return nil
+		}
+
+		return refs.Delete([]byte(n))
+	})
+}
+
+// CountLooseRefs returns the number of references in the storage, all of
+// them are considered loose.
+func (s *Storage) CountLooseRefs() (count int, err error) {
+	err = s.view(func(b *bolt.Bucket) error {
+		refs := subBucket(b, refsBucket)
+		if refs != nil {
+			count = refs.Stats().KeyN
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// PackRefs is a no-op, references can't be packed in this storage.
+func (s *Storage) PackRefs() error {
+	return nil
+}
+
+// SetShallow stores the shallow commits.
+func (s *Storage) SetShallow(commits []plumbing.Hash) error {
+	value := make([]byte, 0, len(commits)*len(plumbing.ZeroHash))
+	for _, h := range commits {
+		value = append(value, h[:]...)
+	}
+
+	return s.update(func(b *bolt.Bucket) error {
+		return b.Put(shallowKey, value)
+	})
+}
+
+// Shallow returns the shallow commits.
+func (s *Storage) Shallow() (commits []plumbing.Hash, err error) {
+	err = s.view(func(b *bolt.Bucket) error {
+		value := get(b, shallowKey)
+		for len(value) >= len(plumbing.ZeroHash) {
+			var h plumbing.Hash
+			copy(h[:], value)
+			commits = append(commits, h)
+			value = value[len(h):]
+		}
+
+		return nil
+	})
+
+	return
+}
+
+// SetIndex stores the given index.
+func (s *Storage) SetIndex(idx *index.Index) error {
+	buf := bytes.NewBuffer(nil)
+	if err := index.NewEncoder(buf).Encode(idx); err != nil {
+		return err
+	}
+
+	return s.update(func(b *bolt.Bucket) error {
+		return b.Put(indexKey, buf.Bytes())
+	})
+}
+
+// Index returns the stored index, if none an empty index is returned.
+func (s *Storage) Index() (*index.Index, error) {
+	idx := &index.Index{Version: 2}
+	err := s.view(func(b *bolt.Bucket) error {
+		value := get(b, indexKey)
+		if value == nil {
+			return nil
+		}
+
+		return index.NewDecoder(bytes.NewReader(value)).Decode(idx)
+	})
+
+	return idx, err
+}
+
+// SetConfig stores the given config.
+func (s *Storage) SetConfig(cfg *config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	value, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	return s.update(func(b *bolt.Bucket) error {
+		return b.Put(configKey, value)
+	})
+}
+
+// Config returns the stored config, if none a new config is returned.
+func (s *Storage) Config() (*config.Config, error) {
+	cfg := config.NewConfig()
+	err := s.view(func(b *bolt.Bucket) error {
+		value := get(b, configKey)
+		if value == nil {
+			return nil
+		}
+
+		return cfg.Unmarshal(value)
+	})
+
+	return cfg, err
+}
+
+// Module returns the Storage of the submodule with the given name.
+func (s *Storage) Module(name string) (storage.Storer, error) {
+	path := make([][]byte, len(s.path), len(s.path)+2)
+	copy(path, s.path)
+
+	return &Storage{
+		db:   s.db,
+		path: append(path, modulesBucket, []byte(name)),
+		tx:   s.tx,
+	}, nil
+}
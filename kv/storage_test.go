@@ -0,0 +1,257 @@
+package kv
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	bolt "go.etcd.io/bbolt"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+func TestStorage_EncodedObject(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStorage(newDB(require), "foo")
+
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	require.NoError(err)
+	_, err = w.Write([]byte("foo"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	h, err := s.SetEncodedObject(obj)
+	require.NoError(err)
+	require.Equal(obj.Hash(), h)
+
+	require.NoError(s.HasEncodedObject(h))
+
+	size, err := s.EncodedObjectSize(h)
+	require.NoError(err)
+	require.Equal(int64(3), size)
+
+	o, err := s.EncodedObject(plumbing.AnyObject, h)
+	require.NoError(err)
+	require.Equal(h, o.Hash())
+	require.Equal(plumbing.BlobObject, o.Type())
+
+	_, err = s.EncodedObject(plumbing.CommitObject, h)
+	require.Equal(plumbing.ErrObjectNotFound, err)
+
+	iter, err := s.IterEncodedObjects(plumbing.BlobObject)
+	require.NoError(err)
+
+	var count int
+	err = iter.ForEach(func(plumbing.EncodedObject) error {
+		count++
+		return nil
+	})
+	require.NoError(err)
+	require.Equal(1, count)
+}
+
+func TestStorage_PackfileWriter(t *testing.T) {
+	require := require.New(t)
+
+	src := memory.NewStorage()
+	var hashes []plumbing.Hash
+	for _, content := range []string{"foo bar baz", "foo bar baz qux"} {
+		obj := src.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, err := obj.Writer()
+		require.NoError(err)
+		_, err = w.Write([]byte(content))
+		require.NoError(err)
+		require.NoError(w.Close())
+
+		h, err := src.SetEncodedObject(obj)
+		require.NoError(err)
+		hashes = append(hashes, h)
+	}
+
+	var buf bytes.Buffer
+	_, err := packfile.NewEncoder(&buf, src, false).Encode(hashes, 10)
+	require.NoError(err)
+
+	s := NewStorage(newDB(require), "foo")
+	w, err := s.PackfileWriter()
+	require.NoError(err)
+	_, err = w.Write(buf.Bytes())
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	for _, h := range hashes {
+		obj, err := s.EncodedObject(plumbing.BlobObject, h)
+		require.NoError(err)
+		require.Equal(h, obj.Hash())
+	}
+}
+
+func TestStorage_EncodedObject_NotFound(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStorage(newDB(require), "foo")
+
+	_, err := s.EncodedObject(plumbing.AnyObject, plumbing.ZeroHash)
+	require.Equal(plumbing.ErrObjectNotFound, err)
+
+	err = s.HasEncodedObject(plumbing.ZeroHash)
+	require.Equal(plumbing.ErrObjectNotFound, err)
+}
+
+func TestStorage_Reference(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStorage(newDB(require), "foo")
+
+	_, err := s.Reference("refs/heads/master")
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+
+	h := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", h)))
+	require.NoError(s.SetReference(plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/master")))
+
+	ref, err := s.Reference("refs/heads/master")
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+
+	ref, err = s.Reference(plumbing.HEAD)
+	require.NoError(err)
+	require.Equal(plumbing.ReferenceName("refs/heads/master"), ref.Target())
+
+	count, err := s.CountLooseRefs()
+	require.NoError(err)
+	require.Equal(2, count)
+
+	require.NoError(s.RemoveReference("refs/heads/master"))
+
+	iter, err := s.IterReferences()
+	require.NoError(err)
+
+	var names []plumbing.ReferenceName
+	err = iter.ForEach(func(r *plumbing.Reference) error {
+		names = append(names, r.Name())
+		return nil
+	})
+	require.NoError(err)
+	require.Equal([]plumbing.ReferenceName{plumbing.HEAD}, names)
+}
+
+func TestStorage_CheckAndSetReference(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStorage(newDB(require), "foo")
+
+	foo := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	bar := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/foo", foo)))
+
+	err := s.CheckAndSetReference(
+		plumbing.NewHashReference("refs/heads/foo", bar),
+		plumbing.NewHashReference("refs/heads/foo", bar),
+	)
+	require.Equal(storage.ErrReferenceHasChanged, err)
+
+	err = s.CheckAndSetReference(
+		plumbing.NewHashReference("refs/heads/foo", bar),
+		plumbing.NewHashReference("refs/heads/foo", foo),
+	)
+	require.NoError(err)
+}
+
+func TestStorage_Config(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStorage(newDB(require), "foo")
+
+	cfg, err := s.Config()
+	require.NoError(err)
+	require.Len(cfg.Remotes, 0)
+
+	cfg.Remotes["origin"] = &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"git@github.com:foo/bar.git"},
+	}
+	require.NoError(s.SetConfig(cfg))
+
+	cfg, err = s.Config()
+	require.NoError(err)
+	require.Equal([]string{"git@github.com:foo/bar.git"}, cfg.Remotes["origin"].URLs)
+}
+
+func TestStorage_Index(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStorage(newDB(require), "foo")
+
+	idx, err := s.Index()
+	require.NoError(err)
+	require.Equal(uint32(2), idx.Version)
+
+	idx.Entries = append(idx.Entries, &index.Entry{Name: "foo"})
+	require.NoError(s.SetIndex(idx))
+
+	idx, err = s.Index()
+	require.NoError(err)
+	require.Len(idx.Entries, 1)
+	require.Equal("foo", idx.Entries[0].Name)
+}
+
+func TestStorage_Shallow(t *testing.T) {
+	require := require.New(t)
+
+	s := NewStorage(newDB(require), "foo")
+
+	commits := []plumbing.Hash{
+		plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa"),
+		plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+	}
+	require.NoError(s.SetShallow(commits))
+
+	shallow, err := s.Shallow()
+	require.NoError(err)
+	require.Equal(commits, shallow)
+}
+
+func TestStorage_Module(t *testing.T) {
+	require := require.New(t)
+
+	db := newDB(require)
+	s := NewStorage(db, "foo")
+
+	m, err := s.Module("bar")
+	require.NoError(err)
+
+	h := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	require.NoError(m.SetReference(plumbing.NewHashReference("refs/heads/foo", h)))
+
+	_, err = s.Reference("refs/heads/foo")
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+
+	m, err = NewStorage(db, "foo").Module("bar")
+	require.NoError(err)
+
+	ref, err := m.Reference("refs/heads/foo")
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+}
+
+func newDB(require *require.Assertions) *bolt.DB {
+	dir, err := ioutil.TempDir("", "kv")
+	require.NoError(err)
+
+	db, err := bolt.Open(filepath.Join(dir, "borges.db"), 0600, nil)
+	require.NoError(err)
+
+	return db
+}
@@ -0,0 +1,313 @@
+// Package lazy implements a borges.Library cloning the missing repositories
+// from their upstreams on demand, working as a read-through cache.
+package lazy
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/fetcher"
+
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+var (
+	// ErrClone is returned when a missing repository can't be cloned.
+	ErrClone = errors.NewKind("error cloning repository %s")
+	// ErrNoLocations is returned by the placements when the library doesn't
+	// contain any location.
+	ErrNoLocations = errors.NewKind("no locations available for repository %s")
+	// ErrDeleteNotSupported is returned when a failed clone was persisted in
+	// a Location that can't delete repositories.
+	ErrDeleteNotSupported = errors.NewKind("can't remove failed clone %s, location %s doesn't support delete")
+)
+
+// deleter is implemented by the Locations able to remove repositories, like
+// plain.Location.
+type deleter interface {
+	Delete(borges.RepositoryID) error
+}
+
+// Resolver returns the upstream URL of the repository with the given
+// RepositoryID.
+type Resolver func(borges.RepositoryID) (string, error)
+
+// HTTPSResolver resolves the URLs using borges.DefaultURLTemplates and the
+// https protocol.
+func HTTPSResolver(id borges.RepositoryID) (string, error) {
+	return id.URL("https")
+}
+
+// Placement chooses the Location where a missing repository is cloned, the
+// locations are sorted by LocationID.
+type Placement func(borges.RepositoryID, []borges.Location) (borges.Location, error)
+
+// HashPlacement spreads the repositories over all the locations based on the
+// hash of the RepositoryID, a repository is always placed in the same
+// location while the locations don't change.
+func HashPlacement(id borges.RepositoryID, locs []borges.Location) (borges.Location, error) {
+	if len(locs) == 0 {
+		return nil, ErrNoLocations.New(id)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(id))
+	return locs[h.Sum32()%uint32(len(locs))], nil
+}
+
+// LocationPlacement returns a Placement always choosing the Location with the
+// given LocationID.
+func LocationPlacement(loc borges.LocationID) Placement {
+	return func(id borges.RepositoryID, locs []borges.Location) (borges.Location, error) {
+		for _, l := range locs {
+			if l.ID() == loc {
+				return l, nil
+			}
+		}
+
+		return nil, borges.ErrLocationNotExists.New(loc)
+	}
+}
+
+// Options contains configuration options for a Library.
+type Options struct {
+	// Resolver returns the upstream URL of the repositories, by default
+	// HTTPSResolver.
+	Resolver Resolver
+	// Placement chooses the location of the cloned repositories, by default
+	// HashPlacement.
+	Placement Placement
+	// RefSpecs are the RefSpecs used to clone, by default
+	// fetcher.DefaultRefSpecs.
+	RefSpecs []config.RefSpec
+	// Auth is the authentication method used for every upstream.
+	Auth transport.AuthMethod
+}
+
+// Validate validates the fields and sets the default values.
+func (o *Options) Validate() error {
+	if o.Resolver == nil {
+		o.Resolver = HTTPSResolver
+	}
+
+	if o.Placement == nil {
+		o.Placement = HashPlacement
+	}
+
+	if len(o.RefSpecs) == 0 {
+		o.RefSpecs = fetcher.DefaultRefSpecs
+	}
+
+	for _, rs := range o.RefSpecs {
+		if err := rs.Validate(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Library wraps a borges.Library cloning the repositories not found by Get
+// or Has into one of its locations. Concurrent misses of the same repository
+// are coalesced into a single clone.
+//
+// The repositories are cloned using GetOrInit and committed once fetched. If
+// the clone fails the changes are discarded, in non-transactional locations
+// the repository is removed with Delete, locations not supporting it return
+// ErrDeleteNotSupported and should only be used in transactional mode.
+type Library struct {
+	borges.Library
+	opts *Options
+
+	m     sync.Mutex
+	calls map[borges.RepositoryID]*call
+}
+
+type call struct {
+	wg  sync.WaitGroup
+	err error
+}
+
+// NewLibrary returns a new Library wrapping the given borges.Library with
+// the given Options.
+func NewLibrary(lib borges.Library, opts *Options) (*Library, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Library{
+		Library: lib,
+		opts:    opts,
+		calls:   make(map[borges.RepositoryID]*call),
+	}, nil
+}
+
+// Get open a repository with the given RepositoryID, if it can't be found
+// it's cloned from its upstream. If the upstream doesn't exist
+// ErrRepositoryNotExists is returned.
+func (l *Library) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	r, err := l.Library.Get(id, mode)
+	if !borges.ErrRepositoryNotExists.Is(err) {
+		return r, err
+	}
+
+	if err := l.clone(id); err != nil {
+		return nil, err
+	}
+
+	return l.Library.Get(id, mode)
+}
+
+// Has returns true, the LibraryID and the LocationID if the given
+// RepositoryID matches any repository of the wrapped Library, if it can't be
+// found it's cloned from its upstream.
+func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	ok, lib, loc, err := l.Library.Has(id)
+	if ok || err != nil {
+		return ok, lib, loc, err
+	}
+
+	err = l.clone(id)
+	if borges.ErrRepositoryNotExists.Is(err) {
+		return false, "", "", nil
+	}
+
+	if err != nil {
+		return false, "", "", err
+	}
+
+	return l.Library.Has(id)
+}
+
+// clone clones the repository, waiting for the clone in progress if any.
+func (l *Library) clone(id borges.RepositoryID) error {
+	l.m.Lock()
+	if c, ok := l.calls[id]; ok {
+		l.m.Unlock()
+		c.wg.Wait()
+		return c.err
+	}
+
+	c := &call{}
+	c.wg.Add(1)
+	l.calls[id] = c
+	l.m.Unlock()
+
+	c.err = l.doClone(id)
+	c.wg.Done()
+
+	l.m.Lock()
+	delete(l.calls, id)
+	l.m.Unlock()
+
+	return c.err
+}
+
+func (l *Library) doClone(id borges.RepositoryID) error {
+	// the repository could be cloned by a call finished before this one
+	// started.
+	ok, _, _, err := l.Library.Has(id)
+	if ok || err != nil {
+		return err
+	}
+
+	locs, err := l.locations()
+	if err != nil {
+		return err
+	}
+
+	loc, err := l.opts.Placement(id, locs)
+	if err != nil {
+		return err
+	}
+
+	url, err := l.opts.Resolver(id)
+	if err != nil {
+		return ErrClone.Wrap(err, id)
+	}
+
+	// Init instead of GetOrInit, a failed fetch must only discard the
+	// repository created by this call.
+	r, err := loc.Init(id)
+	if borges.ErrRepositoryExists.Is(err) {
+		// created by another writer since the lookup.
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	_, err = fetcher.FetchRepository(r, url, &git.FetchOptions{
+		RefSpecs: l.opts.RefSpecs,
+		Auth:     l.opts.Auth,
+	})
+
+	if err != nil {
+		r.Close()
+		if derr := discard(loc, id); derr != nil {
+			return derr
+		}
+
+		if err == transport.ErrRepositoryNotFound {
+			return borges.ErrRepositoryNotExists.New(id)
+		}
+
+		return ErrClone.Wrap(err, id)
+	}
+
+	// in transactional locations a failed commit discards the changes, the
+	// repository isn't persisted.
+	err = r.Commit()
+	if borges.ErrNonTransactional.Is(err) {
+		return r.Close()
+	}
+
+	return err
+}
+
+// discard removes the repository of a failed clone if it was persisted,
+// which happens in non-transactional locations.
+func discard(loc borges.Location, id borges.RepositoryID) error {
+	ok, err := loc.Has(id)
+	if err != nil || !ok {
+		return err
+	}
+
+	d, ok := loc.(deleter)
+	if !ok {
+		return ErrDeleteNotSupported.New(id, loc.ID())
+	}
+
+	return d.Delete(id)
+}
+
+// locations returns the locations of the wrapped Library sorted by ID.
+func (l *Library) locations() ([]borges.Location, error) {
+	iter, err := l.Library.Locations()
+	if err != nil {
+		return nil, err
+	}
+
+	var locs []borges.Location
+	err = iter.ForEach(func(loc borges.Location) error {
+		locs = append(locs, loc)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(locs, func(i, j int) bool { return locs[i].ID() < locs[j].ID() })
+	return locs, nil
+}
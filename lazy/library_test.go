@@ -0,0 +1,265 @@
+package lazy
+
+import (
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/client"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport/server"
+)
+
+func init() {
+	// the upstreams are served in-process, without requiring git binaries.
+	client.InstallProtocol("file", server.DefaultServer)
+}
+
+var fixtureHead = plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+
+func newLibrary(require *require.Assertions, locs ...borges.LocationID) *plain.Library {
+	lib := plain.NewLibrary("foo")
+	for _, id := range locs {
+		lib.AddLocation(newLocation(require, id, true))
+	}
+
+	return lib
+}
+
+func newLocation(
+	require *require.Assertions,
+	id borges.LocationID,
+	transactional bool,
+) *plain.Location {
+	dir, err := ioutil.TempDir("", "lazy")
+	require.NoError(err)
+	tmp, err := ioutil.TempDir("", "lazy-tmp")
+	require.NoError(err)
+
+	loc, err := plain.NewLocation(id, osfs.New(dir), &plain.LocationOptions{
+		Transactional:      transactional,
+		TemporalFilesystem: osfs.New(tmp),
+	})
+	require.NoError(err)
+
+	return loc
+}
+
+// upstreams returns a Resolver serving the basic fixture for any RepositoryID
+// but not-found, it counts the resolved ids.
+func upstreams(count *int32) Resolver {
+	fixtures.Init()
+	url := fixtures.Basic().One().DotGit().Root()
+
+	return func(id borges.RepositoryID) (string, error) {
+		atomic.AddInt32(count, 1)
+		if id == "not-found" {
+			return url + "-not-found", nil
+		}
+
+		return url, nil
+	}
+}
+
+func TestLibrary_Get(t *testing.T) {
+	require := require.New(t)
+
+	var count int32
+	lib, err := NewLibrary(newLibrary(require, "a", "b"), &Options{
+		Resolver: upstreams(&count),
+	})
+	require.NoError(err)
+
+	r, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref, err := r.R().Reference(plumbing.Master, false)
+	require.NoError(err)
+	require.Equal(fixtureHead, ref.Hash())
+	require.NoError(r.Close())
+
+	r, err = lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+	require.Equal(int32(1), count)
+
+	_, err = lib.Get("not-found", borges.ReadOnlyMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+}
+
+func TestLibrary_Has(t *testing.T) {
+	require := require.New(t)
+
+	var count int32
+	lib, err := NewLibrary(newLibrary(require, "a", "b"), &Options{
+		Resolver:  upstreams(&count),
+		Placement: LocationPlacement("b"),
+	})
+	require.NoError(err)
+
+	ok, libID, locID, err := lib.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(ok)
+	require.Equal(borges.LibraryID("foo"), libID)
+	require.Equal(borges.LocationID("b"), locID)
+
+	ok, _, _, err = lib.Has("not-found")
+	require.NoError(err)
+	require.False(ok)
+	require.Equal(int32(2), count)
+}
+
+func TestLibrary_Get_NonTransactional(t *testing.T) {
+	require := require.New(t)
+
+	loc := newLocation(require, "a", false)
+	base := plain.NewLibrary("foo")
+	base.AddLocation(loc)
+
+	var count int32
+	lib, err := NewLibrary(base, &Options{Resolver: upstreams(&count)})
+	require.NoError(err)
+
+	_, err = lib.Get("not-found", borges.ReadOnlyMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+
+	ok, err := loc.Has("not-found")
+	require.NoError(err)
+	require.False(ok)
+
+	r, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+}
+
+// noDeleteLocation hides the Delete method of the wrapped Location.
+type noDeleteLocation struct {
+	borges.Location
+}
+
+func TestLibrary_Get_CreatedByOther(t *testing.T) {
+	require := require.New(t)
+
+	base := plain.NewLibrary("foo")
+	base.AddLocation(newLocation(require, "a", false))
+
+	var count int32
+	resolver := upstreams(&count)
+	lib, err := NewLibrary(base, &Options{
+		// the upstream fails, the repository created by another writer
+		// after the lookup must be kept.
+		Resolver: func(borges.RepositoryID) (string, error) {
+			return resolver("not-found")
+		},
+		Placement: func(
+			id borges.RepositoryID,
+			locs []borges.Location,
+		) (borges.Location, error) {
+			r, err := locs[0].Init(id)
+			if err != nil {
+				return nil, err
+			}
+
+			return locs[0], r.Close()
+		},
+	})
+	require.NoError(err)
+
+	r, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	ok, _, _, err := base.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(ok)
+}
+
+func TestDiscard(t *testing.T) {
+	require := require.New(t)
+
+	loc := newLocation(require, "a", false)
+	_, err := loc.Init("foo")
+	require.NoError(err)
+
+	err = discard(noDeleteLocation{loc}, "foo")
+	require.True(ErrDeleteNotSupported.Is(err))
+
+	require.NoError(discard(loc, "foo"))
+	require.NoError(discard(loc, "foo"))
+
+	ok, err := loc.Has("foo")
+	require.NoError(err)
+	require.False(ok)
+}
+
+func TestLibrary_Coalesce(t *testing.T) {
+	require := require.New(t)
+
+	var count int32
+	lib, err := NewLibrary(newLibrary(require, "a"), &Options{
+		Resolver: upstreams(&count),
+	})
+	require.NoError(err)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 10)
+	for i := range errs {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			r, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+			if err == nil {
+				err = r.Close()
+			}
+
+			errs[i] = err
+		}(i)
+	}
+
+	wg.Wait()
+	for _, err := range errs {
+		require.NoError(err)
+	}
+
+	require.Equal(int32(1), count)
+}
+
+func TestHashPlacement(t *testing.T) {
+	require := require.New(t)
+
+	locs := newLibrary(require, "a", "b", "c")
+	lib, err := NewLibrary(locs, nil)
+	require.NoError(err)
+
+	sorted, err := lib.locations()
+	require.NoError(err)
+	require.Len(sorted, 3)
+	require.Equal(borges.LocationID("a"), sorted[0].ID())
+
+	used := make(map[borges.LocationID]bool)
+	for _, id := range []borges.RepositoryID{"foo", "bar", "baz", "qux", "quux"} {
+		loc, err := HashPlacement(id, sorted)
+		require.NoError(err)
+
+		again, err := HashPlacement(id, sorted)
+		require.NoError(err)
+		require.Equal(loc.ID(), again.ID())
+
+		used[loc.ID()] = true
+	}
+
+	require.True(len(used) > 1)
+
+	_, err = HashPlacement("foo", nil)
+	require.True(ErrNoLocations.Is(err))
+
+	_, err = LocationPlacement("d")("foo", sorted)
+	require.True(borges.ErrLocationNotExists.Is(err))
+}
@@ -1,12 +1,8 @@
 package borges
 
 import (
-	"path"
-	"strings"
-
 	"gopkg.in/src-d/go-errors.v1"
 	"gopkg.in/src-d/go-git.v4"
-	"gopkg.in/src-d/go-git.v4/plumbing/transport"
 )
 
 var (
@@ -25,34 +21,14 @@ var (
 	ErrRepositoryExists = errors.NewKind("repository %s already exists")
 	// ErrRepositoryNotExists when a Repository is requested and can't be found.
 	ErrRepositoryNotExists = errors.NewKind("repository %s not exists")
+	// ErrInvalidRepositoryID is returned when a RepositoryID can't be handled
+	// by a Location, eg.: a path traversal in a filesystem based location.
+	ErrInvalidRepositoryID = errors.NewKind("invalid repository id %q: %s")
 	// ErrNonTransactional returned when Repository.Commit is called on a
 	// repository that not support transactions.
 	ErrNonTransactional = errors.NewKind("non transactional repository")
 )
 
-// RepositoryID represents a Repository identifier, these IDs regularly are
-// based on a http or git remore URL, but can be based on any other concept.
-type RepositoryID string
-
-// NewRepositoryID returns a new RepositoryID based on a given endpoint.
-// Eg.: git@github.com:src-d/go-borges becomes github.com/src-d/go-borges.git
-func NewRepositoryID(endpoint string) (RepositoryID, error) {
-	e, err := transport.NewEndpoint(endpoint)
-	if err != nil {
-		return "", err
-	}
-
-	if !strings.HasSuffix(e.Path, ".git") {
-		e.Path += ".git"
-	}
-
-	return RepositoryID(path.Join(e.Host, e.Path)), nil
-}
-
-func (id RepositoryID) String() string {
-	return string(id)
-}
-
 // Mode is the different modes to open a Repository.
 type Mode int
 
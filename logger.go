@@ -0,0 +1,37 @@
+package borges
+
+// Fields are the structured fields of a log entry.
+type Fields map[string]interface{}
+
+// Logger is a leveled and structured logger used by the implementations to
+// report their decisions and the errors they don't return. It's meant to be
+// implemented by an adapter to the logging library of the application.
+type Logger interface {
+	// Debug logs the internal decisions, like the directories skipped by a
+	// scan.
+	Debug(msg string, fields Fields)
+	// Info logs the relevant operations, like a commit.
+	Info(msg string, fields Fields)
+	// Warn logs the errors that don't prevent the operation to succeed.
+	Warn(msg string, err error, fields Fields)
+	// Error logs the errors that make an operation fail.
+	Error(msg string, err error, fields Fields)
+}
+
+// NoopLogger is a Logger discarding every entry, it's used when no Logger
+// is configured.
+type NoopLogger struct{}
+
+var _ Logger = NoopLogger{}
+
+// Debug honors the Logger interface.
+func (NoopLogger) Debug(string, Fields) {}
+
+// Info honors the Logger interface.
+func (NoopLogger) Info(string, Fields) {}
+
+// Warn honors the Logger interface.
+func (NoopLogger) Warn(string, error, Fields) {}
+
+// Error honors the Logger interface.
+func (NoopLogger) Error(string, error, Fields) {}
@@ -0,0 +1,256 @@
+package memory
+
+import (
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+// LocationOptions contains configuration options for a memory.Location.
+type LocationOptions struct {
+	// Transactional defines if the write operations are done in a transactional
+	// mode or not.
+	Transactional bool
+}
+
+// Validate validates the fields and sets the default values.
+func (o *LocationOptions) Validate() error {
+	return nil
+}
+
+// Location implements borges.Location keeping every repository in a go-git
+// memory.Storage, it's meant to be used in tests and short lived processes.
+//
+// The Location can be used concurrently, but a repository must not be
+// written from several goroutines at the same time.
+type Location struct {
+	id   borges.LocationID
+	opts *LocationOptions
+
+	m     sync.RWMutex
+	repos map[borges.RepositoryID]*memory.Storage
+}
+
+// NewLocation returns a new empty Location with the given ID and
+// LocationOptions.
+func NewLocation(id borges.LocationID, opts *LocationOptions) (*Location, error) {
+	if opts == nil {
+		opts = &LocationOptions{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Location{
+		id:    id,
+		opts:  opts,
+		repos: make(map[borges.RepositoryID]*memory.Storage),
+	}, nil
+}
+
+// ID returns the ID for this Location.
+func (l *Location) ID() borges.LocationID {
+	return l.id
+}
+
+// GetOrInit get the requested repository based on the given id, or inits a
+// new repository. If the repository is opened this will be done in RWMode.
+func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	has, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return l.Get(id, borges.RWMode)
+	}
+
+	return l.Init(id)
+}
+
+// Init initializes a new Repository at this Location.
+func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	has, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return nil, borges.ErrRepositoryExists.New(id)
+	}
+
+	return initRepository(l, id)
+}
+
+// Has returns true if the given RepositoryID matches any repository at this
+// location.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	_, ok := l.repos[id]
+	return ok, nil
+}
+
+// Get open a repository with the given RepositoryID, this operation doesn't
+// perform any read operation. If a repository with the given RepositoryID
+// doesn't exists ErrRepositoryNotExists is returned.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	s, ok := l.storage(id)
+	if !ok {
+		return nil, borges.ErrRepositoryNotExists.New(id)
+	}
+
+	return openRepository(l, id, s, mode)
+}
+
+func (l *Location) storage(id borges.RepositoryID) (*memory.Storage, bool) {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	s, ok := l.repos[id]
+	return s, ok
+}
+
+// register adds the given storage as the repository with the given id, if
+// the repository already exists ErrRepositoryExists is returned.
+func (l *Location) register(id borges.RepositoryID, s *memory.Storage) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if current, ok := l.repos[id]; ok && current != s {
+		return borges.ErrRepositoryExists.New(id)
+	}
+
+	l.repos[id] = s
+	return nil
+}
+
+// Delete removes the repository with the given RepositoryID from this
+// Location. If the repository doesn't exist ErrRepositoryNotExists is
+// returned.
+func (l *Location) Delete(id borges.RepositoryID) error {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if _, ok := l.repos[id]; !ok {
+		return borges.ErrRepositoryNotExists.New(id)
+	}
+
+	delete(l.repos, id)
+	return nil
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories contained in this Location.
+func (l *Location) Repositories(m borges.Mode) (borges.RepositoryIterator, error) {
+	return NewLocationIterator(l, m), nil
+}
+
+// Snapshot returns a new Location with the given LocationID containing a
+// copy of all the repositories of this Location. Later writes to any of them
+// aren't visible in the other one.
+func (l *Location) Snapshot(id borges.LocationID) (*Location, error) {
+	opts := *l.opts
+	snapshot, err := NewLocation(id, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	for rid, s := range l.repos {
+		copied := memory.NewStorage()
+		if err := util.CopyStorer(copied, s); err != nil {
+			return nil, err
+		}
+
+		snapshot.repos[rid] = copied
+	}
+
+	return snapshot, nil
+}
+
+// Clone copies the repository with the RepositoryID from into a new
+// repository with the RepositoryID to. If from doesn't exist
+// ErrRepositoryNotExists is returned and if to already exists
+// ErrRepositoryExists is returned.
+func (l *Location) Clone(from, to borges.RepositoryID) error {
+	s, ok := l.storage(from)
+	if !ok {
+		return borges.ErrRepositoryNotExists.New(from)
+	}
+
+	has, err := l.Has(to)
+	if err != nil {
+		return err
+	}
+
+	if has {
+		return borges.ErrRepositoryExists.New(to)
+	}
+
+	copied := memory.NewStorage()
+	if err := util.CopyStorer(copied, s); err != nil {
+		return err
+	}
+
+	return l.register(to, copied)
+}
+
+// LocationIterator iterates all the repositories contained in a Location.
+type LocationIterator struct {
+	l   *Location
+	m   borges.Mode
+	ids []borges.RepositoryID
+}
+
+// NewLocationIterator returns a new LocationIterator for a given Location,
+// the repositories are iterated in lexicographical order.
+func NewLocationIterator(l *Location, m borges.Mode) *LocationIterator {
+	l.m.RLock()
+	defer l.m.RUnlock()
+
+	ids := make([]borges.RepositoryID, 0, len(l.repos))
+	for id := range l.repos {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return &LocationIterator{l: l, m: m, ids: ids}
+}
+
+// Next returns the next repository from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *LocationIterator) Next() (borges.Repository, error) {
+	for len(iter.ids) != 0 {
+		var id borges.RepositoryID
+		id, iter.ids = iter.ids[0], iter.ids[1:]
+
+		s, ok := iter.l.storage(id)
+		if !ok {
+			continue
+		}
+
+		return openRepository(iter.l, id, s, iter.m)
+	}
+
+	return nil, io.EOF
+}
+
+// ForEach call the function for each object contained on this iter until an
+// error happens or the end of the iter is reached. If ErrStop is sent the
+// iteration is stop but no error is returned. The iterator is closed.
+func (iter *LocationIterator) ForEach(cb func(borges.Repository) error) error {
+	return util.ForEachRepositoryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *LocationIterator) Close() {}
@@ -0,0 +1,203 @@
+package memory
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/util"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestLocation(t *testing.T) {
+	require := require.New(t)
+
+	id, err := borges.NewRepositoryID("http://github.com/foo/bar")
+	require.NoError(err)
+
+	var location borges.Location
+	location, err = NewLocation("foo", nil)
+	require.NoError(err)
+
+	r, err := location.Init(id)
+	require.NoError(err)
+	require.NotNil(r)
+
+	iter, err := location.Repositories(borges.RWMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return nil
+	})
+
+	require.NoError(err)
+	require.ElementsMatch(ids, []borges.RepositoryID{
+		"github.com/foo/bar.git",
+	})
+}
+
+func TestLocation_Init(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", nil)
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	remote, err := r.R().Remote("origin")
+	require.NoError(err)
+	require.Equal([]string{"github.com/foo/bar"}, remote.Config().URLs)
+
+	has, err := location.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(has)
+
+	r, err = location.Init("github.com/foo/bar")
+	require.True(borges.ErrRepositoryExists.Is(err))
+	require.Nil(r)
+}
+
+func TestLocation_Get(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", nil)
+	require.NoError(err)
+
+	_, err = location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	r, err := location.Get("github.com/foo/bar", borges.RWMode)
+	require.NoError(err)
+	require.Equal(borges.LocationID("foo"), r.LocationID())
+
+	r, err = location.Get("github.com/foo/qux", borges.RWMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+	require.Nil(r)
+}
+
+func TestLocation_Get_ReadOnlyMode(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", nil)
+	require.NoError(err)
+
+	_, err = location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	r, err := location.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("foo", plumbing.ZeroHash))
+	require.True(util.ErrReadOnlyStorer.Is(err))
+}
+
+func TestLocation_GetOrInit(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", nil)
+	require.NoError(err)
+
+	r, err := location.GetOrInit("github.com/foo/bar")
+	require.NoError(err)
+	require.NotNil(r)
+
+	r, err = location.GetOrInit("github.com/foo/bar")
+	require.NoError(err)
+	require.NotNil(r)
+}
+
+func TestLocation_Snapshot(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", nil)
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	h := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/foo", h))
+	require.NoError(err)
+
+	snapshot, err := location.Snapshot("bar")
+	require.NoError(err)
+	require.Equal(borges.LocationID("bar"), snapshot.ID())
+
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/bar", h))
+	require.NoError(err)
+
+	r, err = snapshot.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	_, err = r.R().Reference("refs/heads/foo", false)
+	require.NoError(err)
+
+	_, err = r.R().Reference("refs/heads/bar", false)
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+}
+
+func TestLocation_Clone(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", nil)
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	h := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/foo", h))
+	require.NoError(err)
+
+	require.NoError(location.Clone("github.com/foo/bar", "github.com/foo/qux"))
+
+	r, err = location.Get("github.com/foo/qux", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref, err := r.R().Reference("refs/heads/foo", false)
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+
+	err = location.Clone("github.com/foo/bar", "github.com/foo/qux")
+	require.True(borges.ErrRepositoryExists.Is(err))
+
+	err = location.Clone("github.com/foo/baz", "github.com/foo/quux")
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+}
+
+func TestLocation_Delete(t *testing.T) {
+	require := require.New(t)
+
+	l, err := NewLocation("foo", nil)
+	require.NoError(err)
+
+	_, err = l.Init("foo")
+	require.NoError(err)
+
+	require.NoError(l.Delete("foo"))
+
+	has, err := l.Has("foo")
+	require.NoError(err)
+	require.False(has)
+
+	err = l.Delete("foo")
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+}
+
+func TestLocationSuite(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LocationSuite{
+			NewLocation: func() (borges.Location, error) {
+				return NewLocation("foo", &LocationOptions{Transactional: transactional})
+			},
+			Transactional: transactional,
+		})
+	}
+}
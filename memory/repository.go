@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+	"gopkg.in/src-d/go-git.v4/storage/transactional"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// Repository represents a git repository stored in memory.
+type Repository struct {
+	id   borges.RepositoryID
+	l    *Location
+	mode borges.Mode
+	s    *memory.Storage
+
+	*git.Repository
+}
+
+func initRepository(l *Location, id borges.RepositoryID) (*Repository, error) {
+	base := memory.NewStorage()
+	s, err := repositoryStorer(l, base, borges.RWMode)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := git.Init(s, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = r.CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{id.String()},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !l.opts.Transactional {
+		if err := l.register(id, base); err != nil {
+			return nil, err
+		}
+	}
+
+	return &Repository{
+		id:         id,
+		l:          l,
+		mode:       borges.RWMode,
+		s:          base,
+		Repository: r,
+	}, nil
+}
+
+// openRepository, is the basic operation of open a repository without any checking.
+func openRepository(
+	l *Location,
+	id borges.RepositoryID,
+	base *memory.Storage,
+	mode borges.Mode,
+) (*Repository, error) {
+	s, err := repositoryStorer(l, base, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := git.Open(s, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{
+		id:         id,
+		l:          l,
+		mode:       mode,
+		s:          base,
+		Repository: r,
+	}, nil
+}
+
+func repositoryStorer(l *Location, base *memory.Storage, mode borges.Mode) (
+	storage.Storer, error) {
+
+	switch mode {
+	case borges.ReadOnlyMode:
+		return &util.ReadOnlyStorer{base}, nil
+	case borges.RWMode:
+		if l.opts.Transactional {
+			return transactional.NewStorage(base, memory.NewStorage()), nil
+		}
+
+		return base, nil
+	default:
+		return nil, borges.ErrModeNotSupported.New(mode)
+	}
+}
+
+// R returns the git.Repository.
+func (r *Repository) R() *git.Repository {
+	return r.Repository
+}
+
+// ID returns the RepositoryID.
+func (r *Repository) ID() borges.RepositoryID {
+	return r.id
+}
+
+// LocationID returns the LocationID from the Location where it was retrieved.
+func (r *Repository) LocationID() borges.LocationID {
+	return r.l.ID()
+}
+
+// Mode returns the Mode how it was opened.
+func (r *Repository) Mode() borges.Mode {
+	return r.mode
+}
+
+// Close closes the repository, if the repository was opened in transactional
+// Mode, will delete any write operation pending to be written.
+func (r *Repository) Close() error {
+	return nil
+}
+
+// Commit persists all the write operations done since was open, if the
+// repository wasn't opened in a Location with Transactions enable returns
+// ErrNonTransactional. The repositories initialized in transactional mode
+// are added to the Location on Commit.
+func (r *Repository) Commit() (err error) {
+	if !r.l.opts.Transactional || r.mode != borges.RWMode {
+		return borges.ErrNonTransactional.New()
+	}
+
+	defer ioutil.CheckClose(r, &err)
+	ts, ok := r.Storer.(*transactional.Storage)
+	if !ok {
+		panic("unreachable code")
+	}
+
+	r.l.m.Lock()
+	defer r.l.m.Unlock()
+
+	if current, ok := r.l.repos[r.id]; ok && current != r.s {
+		return borges.ErrRepositoryExists.New(r.id)
+	}
+
+	if err = ts.Commit(); err != nil {
+		return
+	}
+
+	r.l.repos[r.id] = r.s
+	return
+}
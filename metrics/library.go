@@ -0,0 +1,199 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+)
+
+// Options contains configuration options for the metrics wrappers.
+type Options struct {
+	// Registry receives the metrics, by default DefaultRegistry.
+	Registry *Registry
+}
+
+// Validate validates the fields and sets the default values.
+func (o *Options) Validate() error {
+	if o.Registry == nil {
+		o.Registry = DefaultRegistry
+	}
+
+	return nil
+}
+
+func validate(opts *Options) *Options {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	opts.Validate()
+	return opts
+}
+
+// recorder records the operations of a Library or a Location.
+type recorder struct {
+	reg *Registry
+	lib borges.LibraryID
+}
+
+func (r *recorder) labels(op string, loc borges.LocationID) Labels {
+	return Labels{Op: op, Library: r.lib, Location: loc}
+}
+
+// open records an operation opening a repository, the returned Repository
+// records its commit and close.
+func (r *recorder) open(
+	op string,
+	mode borges.Mode,
+	loc borges.LocationID,
+	fn func() (borges.Repository, error),
+) (borges.Repository, error) {
+	start := time.Now()
+	repo, err := fn()
+
+	l := r.labels(op, loc)
+	l.Mode = modeName(mode)
+	if err == nil {
+		l.Location = repo.LocationID()
+	}
+
+	r.reg.observe(l, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{Repository: repo, rec: r}, nil
+}
+
+func modeName(m borges.Mode) string {
+	if m == borges.RWMode {
+		return "rw"
+	}
+
+	return "read-only"
+}
+
+// Library wraps a borges.Library recording the metrics of its operations,
+// every location, library, repository and iterator returned by it is
+// wrapped too.
+type Library struct {
+	lib  borges.Library
+	opts *Options
+	rec  *recorder
+}
+
+var _ borges.Library = (*Library)(nil)
+
+// NewLibrary returns a new Library wrapping the given one.
+func NewLibrary(lib borges.Library, opts *Options) *Library {
+	opts = validate(opts)
+	return &Library{
+		lib:  lib,
+		opts: opts,
+		rec:  &recorder{reg: opts.Registry, lib: lib.ID()},
+	}
+}
+
+// ID returns the borges.LibraryID of the wrapped Library.
+func (l *Library) ID() borges.LibraryID {
+	return l.lib.ID()
+}
+
+// Init initializes a new Repository in the wrapped Library.
+func (l *Library) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return l.rec.open("init", borges.RWMode, "", func() (borges.Repository, error) {
+		return l.lib.Init(id)
+	})
+}
+
+// GetOrInit opens or initializes the repository in the wrapped Library.
+func (l *Library) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	return l.rec.open("get_or_init", borges.RWMode, "", func() (borges.Repository, error) {
+		return l.lib.GetOrInit(id)
+	})
+}
+
+// Get opens the repository with the given RepositoryID.
+func (l *Library) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	return l.rec.open("get", mode, "", func() (borges.Repository, error) {
+		return l.lib.Get(id, mode)
+	})
+}
+
+// Has returns true, the LibraryID and the LocationID if the given
+// RepositoryID matches any repository of the wrapped Library.
+func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	start := time.Now()
+	ok, lib, loc, err := l.lib.Has(id)
+	l.rec.reg.observe(l.rec.labels("has", ""), start, err)
+	return ok, lib, loc, err
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Library, every step is recorded.
+func (l *Library) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	start := time.Now()
+	iter, err := l.lib.Repositories(mode)
+
+	labels := l.rec.labels("repositories", "")
+	labels.Mode = modeName(mode)
+	l.rec.reg.observe(labels, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RepositoryIterator{iter: iter, rec: l.rec, mode: mode}, nil
+}
+
+// Location returns the Location with the given LocationID wrapped to record
+// its metrics.
+func (l *Library) Location(id borges.LocationID) (borges.Location, error) {
+	loc, err := l.lib.Location(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.location(loc), nil
+}
+
+// Locations returns a LocationIterator that iterates through all the
+// locations of the wrapped Library wrapped to record their metrics.
+func (l *Library) Locations() (borges.LocationIterator, error) {
+	iter, err := l.lib.Locations()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLocationIterator(iter, func(loc borges.Location) (borges.Location, error) {
+		return l.location(loc), nil
+	}), nil
+}
+
+// Library returns the Library with the given LibraryID wrapped to record
+// its metrics.
+func (l *Library) Library(id borges.LibraryID) (borges.Library, error) {
+	lib, err := l.lib.Library(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLibrary(lib, l.opts), nil
+}
+
+// Libraries returns a LibraryIterator that iterates through all the
+// libraries of the wrapped Library wrapped to record their metrics.
+func (l *Library) Libraries() (borges.LibraryIterator, error) {
+	iter, err := l.lib.Libraries()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLibraryIterator(iter, func(lib borges.Library) (borges.Library, error) {
+		return NewLibrary(lib, l.opts), nil
+	}), nil
+}
+
+func (l *Library) location(loc borges.Location) *Location {
+	return &Location{loc: loc, rec: l.rec}
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// count returns the counter of the operation with the given labels.
+func count(reg *Registry, l Labels) uint64 {
+	return reg.Counters()[l]
+}
+
+func TestLibrary_Get(t *testing.T) {
+	require := require.New(t)
+
+	reg := NewRegistry(nil)
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), &Options{Registry: reg})
+
+	r, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	_, err = lib.Get("github.com/foo/qux", borges.RWMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+
+	ok, _, _, err := lib.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(ok)
+
+	require.Equal(map[Labels]uint64{
+		{Op: "get", Library: "foo", Location: "foo", Mode: "read-only"}:         1,
+		{Op: "close", Library: "foo", Location: "foo", Mode: "read-only"}:       1,
+		{Op: "get", Library: "foo", Mode: "rw", Error: "repository_not_exists"}: 1,
+		{Op: "has", Library: "foo"}:                                             1,
+	}, reg.Counters())
+}
+
+func TestLibrary_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	reg := NewRegistry(nil)
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), &Options{Registry: reg})
+
+	iter, err := lib.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	err = iter.ForEach(func(r borges.Repository) error {
+		require.IsType(&Repository{}, r)
+		return r.Close()
+	})
+	require.NoError(err)
+
+	l := Labels{Op: "next", Library: "foo", Location: "foo", Mode: "read-only"}
+	require.Equal(uint64(1), count(reg, l))
+	require.Equal(uint64(1), count(reg, Labels{Op: "repositories", Library: "foo", Mode: "read-only"}))
+
+	sub, err := lib.Library("sub")
+	require.NoError(err)
+	_, _, _, err = sub.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.Equal(uint64(1), count(reg, Labels{Op: "has", Library: "sub"}))
+
+	loc, err := lib.Location("foo")
+	require.NoError(err)
+	_, err = loc.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.Equal(uint64(1), count(reg, Labels{Op: "has", Library: "foo", Location: "foo"}))
+}
+
+func TestLibrary_DefaultRegistry(t *testing.T) {
+	require := require.New(t)
+
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), nil)
+	l := Labels{Op: "has", Library: "foo"}
+	before := count(DefaultRegistry, l)
+
+	_, _, _, err := lib.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.Equal(before+1, count(DefaultRegistry, l))
+}
+
+func TestLibrarySuite(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LibrarySuite{
+			NewLibrary: func() (borges.Library, error) {
+				lib, err := borgestest.NewPlainLibrary(&plain.LocationOptions{
+					Transactional: transactional,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return NewLibrary(lib, &Options{Registry: NewRegistry(nil)}), nil
+			},
+			Transactional: transactional,
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"time"
+
+	"github.com/src-d/go-borges"
+)
+
+// Location wraps a borges.Location recording the metrics of its operations,
+// every repository and iterator returned by it is wrapped too.
+type Location struct {
+	loc borges.Location
+	rec *recorder
+}
+
+var _ borges.Location = (*Location)(nil)
+
+// NewLocation returns a new Location wrapping the given one.
+func NewLocation(loc borges.Location, opts *Options) *Location {
+	opts = validate(opts)
+	return &Location{loc: loc, rec: &recorder{reg: opts.Registry}}
+}
+
+// ID returns the ID of the wrapped Location.
+func (l *Location) ID() borges.LocationID {
+	return l.loc.ID()
+}
+
+// Init initializes a new Repository in the wrapped Location.
+func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return l.rec.open("init", borges.RWMode, l.loc.ID(), func() (borges.Repository, error) {
+		return l.loc.Init(id)
+	})
+}
+
+// GetOrInit opens or initializes the repository in the wrapped Location.
+func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	return l.rec.open("get_or_init", borges.RWMode, l.loc.ID(), func() (borges.Repository, error) {
+		return l.loc.GetOrInit(id)
+	})
+}
+
+// Has returns true if the given RepositoryID matches any repository of the
+// wrapped Location.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	start := time.Now()
+	ok, err := l.loc.Has(id)
+	l.rec.reg.observe(l.rec.labels("has", l.loc.ID()), start, err)
+	return ok, err
+}
+
+// Get opens the repository with the given RepositoryID.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	return l.rec.open("get", mode, l.loc.ID(), func() (borges.Repository, error) {
+		return l.loc.Get(id, mode)
+	})
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Location, every step is recorded.
+func (l *Location) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	start := time.Now()
+	iter, err := l.loc.Repositories(mode)
+
+	labels := l.rec.labels("repositories", l.loc.ID())
+	labels.Mode = modeName(mode)
+	l.rec.reg.observe(labels, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RepositoryIterator{iter: iter, rec: l.rec, mode: mode, loc: l.loc.ID()}, nil
+}
@@ -0,0 +1,48 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/memory"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocation_Init(t *testing.T) {
+	require := require.New(t)
+
+	mem, err := memory.NewLocation("mem", &memory.LocationOptions{Transactional: true})
+	require.NoError(err)
+
+	reg := NewRegistry(nil)
+	loc := NewLocation(mem, &Options{Registry: reg})
+
+	r, err := loc.Init("foo")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	_, err = loc.Init("foo")
+	require.True(borges.ErrRepositoryExists.Is(err))
+
+	r, err = loc.GetOrInit("foo")
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	iter, err := loc.Repositories(borges.RWMode)
+	require.NoError(err)
+	err = iter.ForEach(func(r borges.Repository) error {
+		return r.Close()
+	})
+	require.NoError(err)
+
+	require.Equal(map[Labels]uint64{
+		{Op: "init", Location: "mem", Mode: "rw"}:                             1,
+		{Op: "init", Location: "mem", Mode: "rw", Error: "repository_exists"}: 1,
+		{Op: "commit", Location: "mem", Mode: "rw"}:                           1,
+		{Op: "get_or_init", Location: "mem", Mode: "rw"}:                      1,
+		{Op: "repositories", Location: "mem", Mode: "rw"}:                     1,
+		{Op: "next", Location: "mem", Mode: "rw"}:                             1,
+		{Op: "close", Location: "mem", Mode: "rw"}:                            2,
+	}, reg.Counters())
+}
@@ -0,0 +1,240 @@
+// Package metrics provides borges.Library and borges.Location wrappers
+// recording the number, latency and errors of the operations into a
+// Registry, which can be exposed in the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// DefaultBuckets are the default upper bounds of the latency histograms in
+// seconds.
+var DefaultBuckets = []float64{
+	.0005, .001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+// DefaultRegistry is the Registry used by the wrappers when none is given.
+var DefaultRegistry = NewRegistry(nil)
+
+// Labels identifies the series of an operation.
+type Labels struct {
+	// Op is the name of the operation, eg.: get, commit or next.
+	Op       string
+	Library  borges.LibraryID
+	Location borges.LocationID
+	// Mode is rw or read-only, empty for the operations without mode.
+	Mode string
+	// Error is the kind of the error returned by the operation, empty if it
+	// succeeded.
+	Error string
+}
+
+// Histogram is a snapshot of a latency histogram.
+type Histogram struct {
+	// Buckets are the upper bounds of the buckets in seconds.
+	Buckets []float64
+	// Counts are the cumulative number of observations of each bucket.
+	Counts []uint64
+	// Count is the total number of observations.
+	Count uint64
+	// Sum is the sum of the observations in seconds.
+	Sum float64
+}
+
+// Registry keeps in memory the counters and the latency histograms of the
+// operations, it's safe for concurrent use.
+type Registry struct {
+	buckets []float64
+
+	m          sync.Mutex
+	counters   map[Labels]uint64
+	histograms map[Labels]*Histogram
+}
+
+// NewRegistry returns a new Registry using the given histogram buckets, if
+// empty DefaultBuckets are used.
+func NewRegistry(buckets []float64) *Registry {
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Registry{
+		buckets:    buckets,
+		counters:   make(map[Labels]uint64),
+		histograms: make(map[Labels]*Histogram),
+	}
+}
+
+// Observe records an operation with the given labels that took d.
+func (r *Registry) Observe(l Labels, d time.Duration) {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	r.counters[l]++
+
+	h, ok := r.histograms[l]
+	if !ok {
+		h = &Histogram{Buckets: r.buckets, Counts: make([]uint64, len(r.buckets))}
+		r.histograms[l] = h
+	}
+
+	s := d.Seconds()
+	for i, b := range r.buckets {
+		if s <= b {
+			h.Counts[i]++
+		}
+	}
+
+	h.Count++
+	h.Sum += s
+}
+
+// observe records an operation started at the given time, the error kind is
+// taken from err.
+func (r *Registry) observe(l Labels, start time.Time, err error) {
+	l.Error = ErrorKind(err)
+	r.Observe(l, time.Since(start))
+}
+
+// Counters returns a snapshot of the counters.
+func (r *Registry) Counters() map[Labels]uint64 {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	counters := make(map[Labels]uint64, len(r.counters))
+	for l, c := range r.counters {
+		counters[l] = c
+	}
+
+	return counters
+}
+
+// Histograms returns a snapshot of the latency histograms.
+func (r *Registry) Histograms() map[Labels]Histogram {
+	r.m.Lock()
+	defer r.m.Unlock()
+
+	histograms := make(map[Labels]Histogram, len(r.histograms))
+	for l, h := range r.histograms {
+		c := *h
+		c.Counts = append([]uint64(nil), h.Counts...)
+		histograms[l] = c
+	}
+
+	return histograms
+}
+
+var errorKinds = []struct {
+	kind *errors.Kind
+	name string
+}{
+	{borges.ErrRepositoryNotExists, "repository_not_exists"},
+	{borges.ErrRepositoryExists, "repository_exists"},
+	{borges.ErrLocationNotExists, "location_not_exists"},
+	{borges.ErrLibraryNotExists, "library_not_exists"},
+	{borges.ErrInvalidRepositoryID, "invalid_repository_id"},
+	{borges.ErrModeNotSupported, "mode_not_supported"},
+	{borges.ErrNonTransactional, "non_transactional"},
+	{borges.ErrNotImplemented, "not_implemented"},
+}
+
+// ErrorKind returns the name of the kind of the error used as label, empty
+// for nil and "other" for the errors not defined by borges.
+func ErrorKind(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	for _, k := range errorKinds {
+		if k.kind.Is(err) {
+			return k.name
+		}
+	}
+
+	return "other"
+}
+
+const (
+	countersName   = "borges_operations_total"
+	histogramsName = "borges_operation_duration_seconds"
+)
+
+// Handler returns an http.Handler exposing the metrics in the Prometheus
+// text format.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+		r.WriteTo(w)
+	})
+}
+
+// WriteTo writes the metrics in the Prometheus text format.
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	counters := r.Counters()
+	histograms := r.Histograms()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# HELP %s Number of operations.\n", countersName)
+	fmt.Fprintf(&b, "# TYPE %s counter\n", countersName)
+	for _, l := range sortedLabels(counters) {
+		fmt.Fprintf(&b, "%s{%s} %d\n", countersName, formatLabels(l), counters[l])
+	}
+
+	fmt.Fprintf(&b, "# HELP %s Latency of the operations.\n", histogramsName)
+	fmt.Fprintf(&b, "# TYPE %s histogram\n", histogramsName)
+	for _, l := range sortedLabels(counters) {
+		h := histograms[l]
+		labels := formatLabels(l)
+		for i, bound := range h.Buckets {
+			le := strconv.FormatFloat(bound, 'g', -1, 64)
+			fmt.Fprintf(&b, "%s_bucket{%s,le=%q} %d\n", histogramsName, labels, le, h.Counts[i])
+		}
+
+		fmt.Fprintf(&b, "%s_bucket{%s,le=\"+Inf\"} %d\n", histogramsName, labels, h.Count)
+		fmt.Fprintf(&b, "%s_sum{%s} %g\n", histogramsName, labels, h.Sum)
+		fmt.Fprintf(&b, "%s_count{%s} %d\n", histogramsName, labels, h.Count)
+	}
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+func sortedLabels(counters map[Labels]uint64) []Labels {
+	labels := make([]Labels, 0, len(counters))
+	for l := range counters {
+		labels = append(labels, l)
+	}
+
+	sort.Slice(labels, func(i, j int) bool {
+		return formatLabels(labels[i]) < formatLabels(labels[j])
+	})
+
+	return labels
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(l Labels) string {
+	return fmt.Sprintf(
+		`op="%s",library="%s",location="%s",mode="%s",error="%s"`,
+		labelEscaper.Replace(l.Op),
+		labelEscaper.Replace(string(l.Library)),
+		labelEscaper.Replace(string(l.Location)),
+		labelEscaper.Replace(l.Mode),
+		labelEscaper.Replace(l.Error),
+	)
+}
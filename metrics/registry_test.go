@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistry_Observe(t *testing.T) {
+	require := require.New(t)
+
+	reg := NewRegistry([]float64{1, 0.1})
+	l := Labels{Op: "get", Library: "foo", Mode: "rw"}
+
+	reg.Observe(l, 50*time.Millisecond)
+	reg.Observe(l, 500*time.Millisecond)
+	reg.Observe(l, 5*time.Second)
+
+	require.Equal(map[Labels]uint64{l: 3}, reg.Counters())
+
+	h := reg.Histograms()[l]
+	require.Equal([]float64{0.1, 1}, h.Buckets)
+	require.Equal([]uint64{1, 2}, h.Counts)
+	require.Equal(uint64(3), h.Count)
+	require.InDelta(5.55, h.Sum, 0.0001)
+}
+
+func TestErrorKind(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("", ErrorKind(nil))
+	require.Equal("repository_not_exists", ErrorKind(borges.ErrRepositoryNotExists.New("foo")))
+	require.Equal("non_transactional", ErrorKind(borges.ErrNonTransactional.New()))
+	require.Equal("other", ErrorKind(fmt.Errorf("foo")))
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	require := require.New(t)
+
+	reg := NewRegistry([]float64{0.1})
+	reg.Observe(Labels{Op: "has", Location: `quo"ted`}, time.Millisecond)
+	reg.Observe(Labels{Op: "get", Mode: "rw", Error: "other"}, time.Second)
+
+	srv := httptest.NewServer(reg.Handler())
+	defer srv.Close()
+
+	res, err := http.Get(srv.URL)
+	require.NoError(err)
+	defer res.Body.Close()
+
+	require.Equal(http.StatusOK, res.StatusCode)
+	require.True(strings.HasPrefix(res.Header.Get("Content-Type"), "text/plain"))
+
+	body, err := ioutil.ReadAll(res.Body)
+	require.NoError(err)
+
+	expected := `# HELP borges_operations_total Number of operations.
+# TYPE borges_operations_total counter
+borges_operations_total{op="get",library="",location="",mode="rw",error="other"} 1
+borges_operations_total{op="has",library="",location="quo\"ted",mode="",error=""} 1
+# HELP borges_operation_duration_seconds Latency of the operations.
+# TYPE borges_operation_duration_seconds histogram
+borges_operation_duration_seconds_bucket{op="get",library="",location="",mode="rw",error="other",le="0.1"} 0
+borges_operation_duration_seconds_bucket{op="get",library="",location="",mode="rw",error="other",le="+Inf"} 1
+borges_operation_duration_seconds_sum{op="get",library="",location="",mode="rw",error="other"} 1
+borges_operation_duration_seconds_count{op="get",library="",location="",mode="rw",error="other"} 1
+borges_operation_duration_seconds_bucket{op="has",library="",location="quo\"ted",mode="",error="",le="0.1"} 1
+borges_operation_duration_seconds_bucket{op="has",library="",location="quo\"ted",mode="",error="",le="+Inf"} 1
+borges_operation_duration_seconds_sum{op="has",library="",location="quo\"ted",mode="",error=""} 0.001
+borges_operation_duration_seconds_count{op="has",library="",location="quo\"ted",mode="",error=""} 1
+`
+	require.Equal(expected, string(body))
+}
@@ -0,0 +1,82 @@
+package metrics
+
+import (
+	"io"
+	"time"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+)
+
+// Repository wraps a borges.Repository recording the metrics of its commit
+// and close.
+type Repository struct {
+	borges.Repository
+	rec *recorder
+}
+
+// Commit persists the changes of the wrapped Repository.
+func (r *Repository) Commit() error {
+	start := time.Now()
+	err := r.Repository.Commit()
+	r.rec.reg.observe(r.labels("commit"), start, err)
+	return err
+}
+
+// Close closes the wrapped Repository.
+func (r *Repository) Close() error {
+	start := time.Now()
+	err := r.Repository.Close()
+	r.rec.reg.observe(r.labels("close"), start, err)
+	return err
+}
+
+func (r *Repository) labels(op string) Labels {
+	l := r.rec.labels(op, r.LocationID())
+	l.Mode = modeName(r.Mode())
+	return l
+}
+
+// RepositoryIterator wraps a borges.RepositoryIterator recording the metrics
+// of every step, the end of the iteration isn't recorded.
+type RepositoryIterator struct {
+	iter borges.RepositoryIterator
+	rec  *recorder
+	mode borges.Mode
+	loc  borges.LocationID
+}
+
+// Next returns the next repository from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *RepositoryIterator) Next() (borges.Repository, error) {
+	start := time.Now()
+	r, err := iter.iter.Next()
+	if err == io.EOF {
+		return nil, err
+	}
+
+	l := iter.rec.labels("next", iter.loc)
+	l.Mode = modeName(iter.mode)
+	if err == nil {
+		l.Location = r.LocationID()
+	}
+
+	iter.rec.reg.observe(l, start, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{Repository: r, rec: iter.rec}, nil
+}
+
+// ForEach call the function for each object contained on this iter until an
+// error happens or the end of the iter is reached. If ErrStop is sent the
+// iteration is stop but no error is returned. The iterator is closed.
+func (iter *RepositoryIterator) ForEach(cb func(borges.Repository) error) error {
+	return util.ForEachRepositoryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *RepositoryIterator) Close() {
+	iter.iter.Close()
+}
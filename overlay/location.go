@@ -0,0 +1,235 @@
+// Package overlay implements a borges.Location composing a read-only lower
+// Location with a writable upper Location.
+package overlay
+
+import (
+	"io"
+	"sort"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+)
+
+// Location implements borges.Location on top of two locations, the lower
+// one is never written, all the new objects, references and configuration
+// are written to the upper one. It allows to share a big dataset between
+// several users keeping their changes apart.
+//
+// The repositories of both layers are merged, the references and the config
+// of the upper layer take precedence. A repository of the lower layer is
+// initialized in the upper one on its first write, starting with the config
+// and HEAD of the lower layer. The references of the lower layer can't be
+// removed, ErrLowerReference is returned instead.
+type Location struct {
+	id    borges.LocationID
+	lower borges.Location
+	upper borges.Location
+}
+
+// NewLocation returns a new Location with the given ID composing the given
+// lower and upper locations.
+func NewLocation(id borges.LocationID, lower, upper borges.Location) *Location {
+	return &Location{id: id, lower: lower, upper: upper}
+}
+
+// ID returns the ID for this Location.
+func (l *Location) ID() borges.LocationID {
+	return l.id
+}
+
+// Init initializes a new Repository in the upper layer. If a repository with
+// the given RepositoryID already exists in any layer ErrRepositoryExists is
+// returned.
+func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	has, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return nil, borges.ErrRepositoryExists.New(id)
+	}
+
+	r, err := l.upper.Init(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRepository(l, id, borges.RWMode, nil, r)
+}
+
+// GetOrInit get the requested repository based on the given id, or inits a
+// new repository. If the repository is opened this will be done in RWMode.
+func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	has, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if has {
+		return l.Get(id, borges.RWMode)
+	}
+
+	return l.Init(id)
+}
+
+// Has returns true if the given RepositoryID matches any repository in any
+// of the layers.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	has, err := l.upper.Has(id)
+	if has || err != nil {
+		return has, err
+	}
+
+	return l.lower.Has(id)
+}
+
+// Get open a repository with the given RepositoryID merging both layers. If
+// the repository only exists in the lower layer and is opened in RWMode, the
+// repository is initialized in the upper one on the first write. If a
+// repository with the given RepositoryID doesn't exists
+// ErrRepositoryNotExists is returned.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	inLower, err := l.lower.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	inUpper, err := l.upper.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if !inLower && !inUpper {
+		return nil, borges.ErrRepositoryNotExists.New(id)
+	}
+
+	var lower, upper borges.Repository
+	if inLower {
+		if lower, err = l.lower.Get(id, borges.ReadOnlyMode); err != nil {
+			return nil, err
+		}
+	}
+
+	if inUpper {
+		if upper, err = l.upper.Get(id, mode); err != nil {
+			closeRepository(lower)
+			return nil, err
+		}
+	}
+
+	return newRepository(l, id, mode, lower, upper)
+}
+
+// Repositories returns a RepositoryIterator that iterates through the
+// repositories of both layers.
+func (l *Location) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	seen := make(map[borges.RepositoryID]struct{})
+	for _, loc := range []borges.Location{l.lower, l.upper} {
+		ids, err := repositoryIDs(loc)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, id := range ids {
+			seen[id] = struct{}{}
+		}
+	}
+
+	ids := make([]borges.RepositoryID, 0, len(seen))
+	for id := range seen {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return &LocationIterator{l: l, m: mode, ids: ids}, nil
+}
+
+// Flatten copies every repository of this Location, merging both layers,
+// into new repositories of the destination Location.
+func (l *Location) Flatten(dst borges.Location) error {
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	if err != nil {
+		return err
+	}
+
+	return iter.ForEach(func(r borges.Repository) error {
+		defer r.Close()
+		return flatten(r, dst)
+	})
+}
+
+// FlattenRepository copies the repository with the given RepositoryID,
+// merging both layers, into a new repository of the destination Location.
+func (l *Location) FlattenRepository(id borges.RepositoryID, dst borges.Location) error {
+	r, err := l.Get(id, borges.ReadOnlyMode)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+	return flatten(r, dst)
+}
+
+func flatten(r borges.Repository, dst borges.Location) error {
+	copied, err := dst.Init(r.ID())
+	if err != nil {
+		return err
+	}
+
+	if err := util.CopyStorer(copied.R().Storer, r.R().Storer); err != nil {
+		copied.Close()
+		return err
+	}
+
+	err = copied.Commit()
+	if borges.ErrNonTransactional.Is(err) {
+		return copied.Close()
+	}
+
+	return err
+}
+
+func repositoryIDs(loc borges.Location) ([]borges.RepositoryID, error) {
+	iter, err := loc.Repositories(borges.ReadOnlyMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return r.Close()
+	})
+
+	return ids, err
+}
+
+// LocationIterator iterates all the repositories contained in a Location.
+type LocationIterator struct {
+	l   *Location
+	m   borges.Mode
+	ids []borges.RepositoryID
+}
+
+// Next returns the next repository from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *LocationIterator) Next() (borges.Repository, error) {
+	if len(iter.ids) == 0 {
+		return nil, io.EOF
+	}
+
+	var id borges.RepositoryID
+	id, iter.ids = iter.ids[0], iter.ids[1:]
+	return iter.l.Get(id, iter.m)
+}
+
+// ForEach call the function for each object contained on this iter until an
+// error happens or the end of the iter is reached. If ErrStop is sent the
+// iteration is stop but no error is returned. The iterator is closed.
+func (iter *LocationIterator) ForEach(cb func(borges.Repository) error) error {
+	return util.ForEachRepositoryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *LocationIterator) Close() {}
@@ -0,0 +1,352 @@
+package overlay
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/memory"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage"
+)
+
+func newMemoryLocation(require *require.Assertions, id borges.LocationID, transactional bool) *memory.Location {
+	l, err := memory.NewLocation(id, &memory.LocationOptions{
+		Transactional: transactional,
+	})
+	require.NoError(err)
+	return l
+}
+
+// setBlobRef stores a new blob with the given content and points the
+// reference to it.
+func setBlobRef(
+	require *require.Assertions,
+	s storage.Storer,
+	name plumbing.ReferenceName,
+	content string,
+) plumbing.Hash {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	require.NoError(err)
+	_, err = w.Write([]byte(content))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	h, err := s.SetEncodedObject(obj)
+	require.NoError(err)
+	require.NoError(s.SetReference(plumbing.NewHashReference(name, h)))
+	return h
+}
+
+func newLocation(require *require.Assertions) (*Location, *memory.Location, *memory.Location, plumbing.Hash) {
+	lower := newMemoryLocation(require, "lower", false)
+	upper := newMemoryLocation(require, "upper", true)
+
+	r, err := lower.Init("foo")
+	require.NoError(err)
+	h := setBlobRef(require, r.R().Storer, "refs/heads/master", "lower")
+
+	_, err = lower.Init("bar")
+	require.NoError(err)
+
+	return NewLocation("overlay", lower, upper), lower, upper, h
+}
+
+func TestLocation_Get_RWMode(t *testing.T) {
+	require := require.New(t)
+
+	l, lower, upper, base := newLocation(require)
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+	require.Equal(borges.LocationID("overlay"), r.LocationID())
+
+	_, err = r.R().Storer.EncodedObject(plumbing.BlobObject, base)
+	require.NoError(err)
+
+	h := setBlobRef(require, r.R().Storer, "refs/heads/feature", "upper")
+	require.NoError(r.Commit())
+
+	r, err = l.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref, err := r.R().Reference("refs/heads/master", false)
+	require.NoError(err)
+	require.Equal(base, ref.Hash())
+
+	ref, err = r.R().Reference("refs/heads/feature", false)
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+	require.NoError(r.Close())
+
+	lr, err := lower.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	_, err = lr.R().Reference("refs/heads/feature", false)
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+	_, err = lr.R().Storer.EncodedObject(plumbing.BlobObject, h)
+	require.Equal(plumbing.ErrObjectNotFound, err)
+
+	ur, err := upper.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	_, err = ur.R().Storer.EncodedObject(plumbing.BlobObject, base)
+	require.Equal(plumbing.ErrObjectNotFound, err)
+	_, err = ur.R().Storer.EncodedObject(plumbing.BlobObject, h)
+	require.NoError(err)
+}
+
+func TestLocation_Get_Override(t *testing.T) {
+	require := require.New(t)
+
+	l, _, _, _ := newLocation(require)
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+	h := setBlobRef(require, r.R().Storer, "refs/heads/master", "upper")
+
+	cfg, err := r.R().Config()
+	require.NoError(err)
+	cfg.Raw.Section("overlay").SetOption("layer", "upper")
+	require.NoError(r.R().Storer.SetConfig(cfg))
+	require.NoError(r.Commit())
+
+	r, err = l.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref, err := r.R().Reference("refs/heads/master", false)
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+
+	cfg, err = r.R().Config()
+	require.NoError(err)
+	require.Equal("upper", cfg.Raw.Section("overlay").Option("layer"))
+}
+
+func TestLocation_Get_RWMode_NoWrites(t *testing.T) {
+	require := require.New(t)
+
+	lower := newMemoryLocation(require, "lower", false)
+	upper := newMemoryLocation(require, "upper", false)
+	l := NewLocation("overlay", lower, upper)
+
+	_, err := lower.Init("foo")
+	require.NoError(err)
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+	_, err = r.R().Head()
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+	require.NoError(r.Close())
+
+	has, err := upper.Has("foo")
+	require.NoError(err)
+	require.False(has)
+}
+
+func TestLocation_Get_LowerConfig(t *testing.T) {
+	require := require.New(t)
+
+	l, lower, _, base := newLocation(require)
+
+	lr, err := lower.Get("foo", borges.RWMode)
+	require.NoError(err)
+	_, err = lr.R().CreateRemote(&config.RemoteConfig{
+		Name: "lower",
+		URLs: []string{"https://github.com/foo/bar"},
+	})
+	require.NoError(err)
+	setBlobRef(require, lr.R().Storer, "refs/heads/main", "lower")
+	head := plumbing.NewSymbolicReference(plumbing.HEAD, "refs/heads/main")
+	require.NoError(lr.R().Storer.SetReference(head))
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+	setBlobRef(require, r.R().Storer, "refs/heads/feature", "upper")
+	require.NoError(r.Commit())
+
+	r, err = l.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	cfg, err := r.R().Config()
+	require.NoError(err)
+	require.Contains(cfg.Remotes, "lower")
+
+	ref, err := r.R().Reference(plumbing.HEAD, false)
+	require.NoError(err)
+	require.Equal(plumbing.ReferenceName("refs/heads/main"), ref.Target())
+
+	ref, err = r.R().Reference("refs/heads/master", false)
+	require.NoError(err)
+	require.Equal(base, ref.Hash())
+}
+
+func TestLocation_RemoveReference(t *testing.T) {
+	require := require.New(t)
+
+	l, _, _, base := newLocation(require)
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+
+	err = r.R().Storer.RemoveReference("refs/heads/master")
+	require.True(ErrLowerReference.Is(err))
+	require.NoError(r.R().Storer.RemoveReference("refs/heads/missing"))
+
+	setBlobRef(require, r.R().Storer, "refs/heads/feature", "upper")
+	err = r.R().Storer.RemoveReference("refs/heads/master")
+	require.True(ErrLowerReference.Is(err))
+	require.NoError(r.R().Storer.RemoveReference("refs/heads/feature"))
+	require.NoError(r.Commit())
+
+	r, err = l.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref, err := r.R().Reference("refs/heads/master", false)
+	require.NoError(err)
+	require.Equal(base, ref.Hash())
+
+	_, err = r.R().Reference("refs/heads/feature", false)
+	require.Equal(plumbing.ErrReferenceNotFound, err)
+}
+
+func TestLocation_Get_ReadOnlyMode(t *testing.T) {
+	require := require.New(t)
+
+	l, _, upper, _ := newLocation(require)
+
+	r, err := l.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/foo", plumbing.ZeroHash))
+	require.Error(err)
+	require.True(borges.ErrNonTransactional.Is(r.Commit()))
+
+	has, err := upper.Has("foo")
+	require.NoError(err)
+	require.False(has)
+}
+
+func TestLocation_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	l, _, _, _ := newLocation(require)
+
+	r, err := l.Init("baz")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	r, err = l.Get("foo", borges.RWMode)
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return r.Close()
+	})
+
+	require.NoError(err)
+	require.Equal([]borges.RepositoryID{"bar", "baz", "foo"}, ids)
+}
+
+func TestLocation_Flatten(t *testing.T) {
+	require := require.New(t)
+
+	l, _, _, base := newLocation(require)
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+	h := setBlobRef(require, r.R().Storer, "refs/heads/feature", "upper")
+	require.NoError(r.Commit())
+
+	dst := newMemoryLocation(require, "dst", true)
+	require.NoError(l.Flatten(dst))
+
+	for _, id := range []borges.RepositoryID{"foo", "bar"} {
+		has, err := dst.Has(id)
+		require.NoError(err)
+		require.True(has, id)
+	}
+
+	r, err = dst.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	for name, hash := range map[plumbing.ReferenceName]plumbing.Hash{
+		"refs/heads/master":  base,
+		"refs/heads/feature": h,
+	} {
+		ref, err := r.R().Reference(name, false)
+		require.NoError(err)
+		require.Equal(hash, ref.Hash())
+
+		_, err = r.R().Storer.EncodedObject(plumbing.BlobObject, hash)
+		require.NoError(err)
+	}
+
+	err = l.FlattenRepository("foo", dst)
+	require.True(borges.ErrRepositoryExists.Is(err))
+}
+
+func TestLocation_Flatten_Packfile(t *testing.T) {
+	require := require.New(t)
+
+	l, _, _, _ := newLocation(require)
+
+	// memfs can't be used concurrently, the packfile is indexed while it's
+	// written.
+	dir, err := ioutil.TempDir("", "overlay")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fs := osfs.New(dir)
+	dst, err := plain.NewLocation("dst", fs, nil)
+	require.NoError(err)
+	require.NoError(l.FlattenRepository("foo", dst))
+
+	entries, err := fs.ReadDir(fs.Join(dst.RepositoryPath("foo"), "objects"))
+	require.NoError(err)
+
+	// no loose objects were written.
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	require.ElementsMatch([]string{"info", "pack"}, names)
+}
+
+func TestLocationSuite(t *testing.T) {
+	for _, transactional := range []bool{true, false} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LocationSuite{
+			NewLocation: func() (borges.Location, error) {
+				lower, err := memory.NewLocation("lower", nil)
+				if err != nil {
+					return nil, err
+				}
+
+				upper, err := memory.NewLocation("upper", &memory.LocationOptions{
+					Transactional: transactional,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				return NewLocation("foo", lower, upper), nil
+			},
+			Transactional: transactional,
+		})
+	}
+}
@@ -0,0 +1,404 @@
+package overlay
+
+import (
+	"io"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/transactional"
+)
+
+// ErrLowerReference is returned when a reference of the lower layer is
+// removed, the lower layer is never written.
+var ErrLowerReference = errors.NewKind("reference %s is in the lower layer and can't be removed")
+
+// Repository represents a git repository merging the lower and upper layers
+// of a Location.
+type Repository struct {
+	id    borges.RepositoryID
+	l     *Location
+	mode  borges.Mode
+	lower borges.Repository
+	upper borges.Repository
+
+	*git.Repository
+}
+
+// newRepository returns a Repository merging the given repositories, any of
+// them can be nil but not both. If upper is nil and the mode is RWMode, the
+// repository is initialized in the upper layer on the first write.
+func newRepository(
+	l *Location,
+	id borges.RepositoryID,
+	mode borges.Mode,
+	lower, upper borges.Repository,
+) (*Repository, error) {
+	r := &Repository{
+		id:    id,
+		l:     l,
+		mode:  mode,
+		lower: lower,
+		upper: upper,
+	}
+
+	var s storage.Storer
+	switch {
+	case mode == borges.ReadOnlyMode:
+		s = &util.ReadOnlyStorer{repositoryStorer(lower, upper)}
+	case upper == nil:
+		s = &lazyStorer{
+			Storer: &util.ReadOnlyStorer{lower.R().Storer},
+			lower:  lower.R().Storer,
+			init:   r.initUpper,
+		}
+	default:
+		s = repositoryStorer(lower, upper)
+	}
+
+	repo, err := git.Open(s, nil)
+	if err != nil {
+		closeRepository(lower)
+		closeRepository(upper)
+		return nil, err
+	}
+
+	r.Repository = repo
+	return r, nil
+}
+
+// initUpper initializes the repository in the upper layer with the config
+// and HEAD of the lower one, returning the storer merging both layers.
+func (r *Repository) initUpper() (storage.Storer, error) {
+	upper, err := r.l.upper.Init(r.id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := seedRepository(upper.R().Storer, r.lower.R().Storer); err != nil {
+		upper.Close()
+		return nil, err
+	}
+
+	r.upper = upper
+	return repositoryStorer(r.lower, upper), nil
+}
+
+// seedRepository copies the config and HEAD from src into dst, otherwise
+// the ones set by Init would take precedence over the lower layer.
+func seedRepository(dst, src storage.Storer) error {
+	if err := util.CopyConfig(dst, src); err != nil {
+		return err
+	}
+
+	head, err := src.Reference(plumbing.HEAD)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return dst.SetReference(head)
+}
+
+func repositoryStorer(lower, upper borges.Repository) storage.Storer {
+	if lower == nil {
+		return upper.R().Storer
+	}
+
+	if upper == nil {
+		return lower.R().Storer
+	}
+
+	base := &util.ReadOnlyStorer{lower.R().Storer}
+	s := &layeredStorer{
+		Storer: transactional.NewStorage(base, upper.R().Storer),
+		lower:  lower.R().Storer,
+		upper:  upper.R().Storer,
+	}
+
+	if pw, ok := upper.R().Storer.(storer.PackfileWriter); ok {
+		return &layeredPackfileStorer{s, pw}
+	}
+
+	return s
+}
+
+// layeredStorer reads the config only from the upper layer, the
+// transactional.Storage reads it from the lower layer unless it was set.
+type layeredStorer struct {
+	storage.Storer
+	lower storage.Storer
+	upper storage.Storer
+}
+
+// Config honors the storage.Storer interface.
+func (s *layeredStorer) Config() (*config.Config, error) {
+	return s.upper.Config()
+}
+
+// SetConfig honors the storage.Storer interface.
+func (s *layeredStorer) SetConfig(cfg *config.Config) error {
+	return s.upper.SetConfig(cfg)
+}
+
+// RemoveReference honors the storer.ReferenceStorer interface, it returns
+// ErrLowerReference if the reference is in the lower layer.
+func (s *layeredStorer) RemoveReference(n plumbing.ReferenceName) error {
+	if err := checkLowerReference(s.lower, n); err != nil {
+		return err
+	}
+
+	return s.Storer.RemoveReference(n)
+}
+
+func checkLowerReference(s storage.Storer, n plumbing.ReferenceName) error {
+	_, err := s.Reference(n)
+	if err == plumbing.ErrReferenceNotFound {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return ErrLowerReference.New(n)
+}
+
+// layeredPackfileStorer writes the packfiles directly into the upper layer.
+type layeredPackfileStorer struct {
+	*layeredStorer
+	pw storer.PackfileWriter
+}
+
+// PackfileWriter honors the storer.PackfileWriter interface.
+func (s *layeredPackfileStorer) PackfileWriter() (io.WriteCloser, error) {
+	return s.pw.PackfileWriter()
+}
+
+// lazyStorer reads only from the lower layer until the first write, then
+// the repository is initialized in the upper layer and both are merged.
+type lazyStorer struct {
+	storage.Storer
+	lower       storage.Storer
+	init        func() (storage.Storer, error)
+	initialized bool
+}
+
+func (s *lazyStorer) layered() (storage.Storer, error) {
+	if s.initialized {
+		return s.Storer, nil
+	}
+
+	st, err := s.init()
+	if err != nil {
+		return nil, err
+	}
+
+	s.Storer, s.initialized = st, true
+	return st, nil
+}
+
+// SetEncodedObject honors the storer.EncodedObjectStorer interface.
+func (s *lazyStorer) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	st, err := s.layered()
+	if err != nil {
+		return plumbing.ZeroHash, err
+	}
+
+	return st.SetEncodedObject(obj)
+}
+
+// SetReference honors the storer.ReferenceStorer interface.
+func (s *lazyStorer) SetReference(ref *plumbing.Reference) error {
+	st, err := s.layered()
+	if err != nil {
+		return err
+	}
+
+	return st.SetReference(ref)
+}
+
+// CheckAndSetReference honors the storer.ReferenceStorer interface.
+func (s *lazyStorer) CheckAndSetReference(new, old *plumbing.Reference) error {
+	st, err := s.layered()
+	if err != nil {
+		return err
+	}
+
+	return st.CheckAndSetReference(new, old)
+}
+
+// RemoveReference honors the storer.ReferenceStorer interface, it returns
+// ErrLowerReference if the reference is in the lower layer.
+func (s *lazyStorer) RemoveReference(n plumbing.ReferenceName) error {
+	if s.initialized {
+		return s.Storer.RemoveReference(n)
+	}
+
+	// without upper layer there is nothing else to remove.
+	return checkLowerReference(s.lower, n)
+}
+
+// PackRefs honors the storer.ReferenceStorer interface, the references of
+// the lower layer are never packed.
+func (s *lazyStorer) PackRefs() error {
+	if !s.initialized {
+		return nil
+	}
+
+	return s.Storer.PackRefs()
+}
+
+// SetShallow honors the storer.ShallowStorer interface.
+func (s *lazyStorer) SetShallow(commits []plumbing.Hash) error {
+	st, err := s.layered()
+	if err != nil {
+		return err
+	}
+
+	return st.SetShallow(commits)
+}
+
+// SetIndex honors the storer.IndexStorer interface.
+func (s *lazyStorer) SetIndex(idx *index.Index) error {
+	st, err := s.layered()
+	if err != nil {
+		return err
+	}
+
+	return st.SetIndex(idx)
+}
+
+// SetConfig honors the config.ConfigStorer interface.
+func (s *lazyStorer) SetConfig(cfg *config.Config) error {
+	st, err := s.layered()
+	if err != nil {
+		return err
+	}
+
+	return st.SetConfig(cfg)
+}
+
+// Module honors the storage.ModuleStorer interface.
+func (s *lazyStorer) Module(name string) (storage.Storer, error) {
+	st, err := s.layered()
+	if err != nil {
+		return nil, err
+	}
+
+	return st.Module(name)
+}
+
+// PackfileWriter honors the storer.PackfileWriter interface. If the upper
+// layer doesn't support it, the packfile is parsed into the storer.
+func (s *lazyStorer) PackfileWriter() (io.WriteCloser, error) {
+	st, err := s.layered()
+	if err != nil {
+		return nil, err
+	}
+
+	if pw, ok := st.(storer.PackfileWriter); ok {
+		return pw.PackfileWriter()
+	}
+
+	return newPackfileParser(st), nil
+}
+
+// packfileParser stores the objects of the packfile written to it.
+type packfileParser struct {
+	w    *io.PipeWriter
+	done chan error
+}
+
+func newPackfileParser(s storer.Storer) *packfileParser {
+	r, w := io.Pipe()
+	p := &packfileParser{w: w, done: make(chan error, 1)}
+
+	go func() {
+		err := packfile.UpdateObjectStorage(s, r)
+		r.CloseWithError(err)
+		p.done <- err
+	}()
+
+	return p
+}
+
+// Write honors the io.Writer interface.
+func (p *packfileParser) Write(b []byte) (int, error) {
+	return p.w.Write(b)
+}
+
+// Close waits until the packfile is parsed.
+func (p *packfileParser) Close() error {
+	p.w.Close()
+	return <-p.done
+}
+
+// R returns the git.Repository.
+func (r *Repository) R() *git.Repository {
+	return r.Repository
+}
+
+// ID returns the RepositoryID.
+func (r *Repository) ID() borges.RepositoryID {
+	return r.id
+}
+
+// LocationID returns the LocationID from the Location where it was retrieved.
+func (r *Repository) LocationID() borges.LocationID {
+	return r.l.ID()
+}
+
+// Mode returns the Mode how it was opened.
+func (r *Repository) Mode() borges.Mode {
+	return r.mode
+}
+
+// Close closes the repositories of both layers.
+func (r *Repository) Close() error {
+	err := closeRepository(r.lower)
+	if uerr := closeRepository(r.upper); err == nil {
+		err = uerr
+	}
+
+	return err
+}
+
+// Commit persists the changes of the upper layer, if the upper Location
+// isn't transactional or the repository was opened in ReadOnlyMode
+// ErrNonTransactional is returned. If nothing was written the repository is
+// closed.
+func (r *Repository) Commit() error {
+	if r.mode != borges.RWMode {
+		return borges.ErrNonTransactional.New()
+	}
+
+	if r.upper == nil {
+		return closeRepository(r.lower)
+	}
+
+	if err := r.upper.Commit(); err != nil {
+		return err
+	}
+
+	return closeRepository(r.lower)
+}
+
+func closeRepository(r borges.Repository) error {
+	if r == nil {
+		return nil
+	}
+
+	return r.Close()
+}
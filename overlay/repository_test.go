@@ -0,0 +1,96 @@
+package overlay
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+func TestRepository_Commit_NonTransactional(t *testing.T) {
+	require := require.New(t)
+
+	lower := newMemoryLocation(require, "lower", false)
+	upper := newMemoryLocation(require, "upper", false)
+	l := NewLocation("overlay", lower, upper)
+
+	_, err := lower.Init("foo")
+	require.NoError(err)
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+
+	h := setBlobRef(require, r.R().Storer, "refs/heads/master", "upper")
+	require.True(borges.ErrNonTransactional.Is(r.Commit()))
+	require.NoError(r.Close())
+
+	ur, err := upper.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	ref, err := ur.R().Reference("refs/heads/master", false)
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+}
+
+func TestRepositoryStorer_PackfileWriter(t *testing.T) {
+	require := require.New(t)
+
+	lower := newMemoryLocation(require, "lower", false)
+	upper, err := plain.NewLocation("upper", memfs.New(), nil)
+	require.NoError(err)
+
+	l := NewLocation("overlay", lower, upper)
+
+	_, err = lower.Init("foo")
+	require.NoError(err)
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+
+	_, ok := r.R().Storer.(storer.PackfileWriter)
+	require.True(ok)
+	require.NoError(r.Close())
+}
+
+func TestRepositoryStorer_PackfileParser(t *testing.T) {
+	require := require.New(t)
+
+	lower := newMemoryLocation(require, "lower", false)
+	upper := newMemoryLocation(require, "upper", true)
+	l := NewLocation("overlay", lower, upper)
+
+	_, err := lower.Init("foo")
+	require.NoError(err)
+
+	src := memory.NewStorage()
+	h := setBlobRef(require, src, "refs/heads/master", "upper")
+
+	var buf bytes.Buffer
+	_, err = packfile.NewEncoder(&buf, src, false).Encode([]plumbing.Hash{h}, 10)
+	require.NoError(err)
+
+	r, err := l.Get("foo", borges.RWMode)
+	require.NoError(err)
+
+	pw, ok := r.R().Storer.(storer.PackfileWriter)
+	require.True(ok)
+	w, err := pw.PackfileWriter()
+	require.NoError(err)
+	_, err = w.Write(buf.Bytes())
+	require.NoError(err)
+	require.NoError(w.Close())
+	require.NoError(r.Commit())
+
+	ur, err := upper.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	_, err = ur.R().Storer.EncodedObject(plumbing.BlobObject, h)
+	require.NoError(err)
+}
@@ -0,0 +1,122 @@
+package plain
+
+import (
+	"context"
+
+	"github.com/src-d/go-borges"
+)
+
+// Hook is notified of the internal operations of a Library or a Location
+// used through WithContext, like the lookups in every location and
+// sub-library. It allows instrumenting them, as the tracing package does,
+// without plain depending on any implementation.
+type Hook interface {
+	// Start is called when an operation starts with the context of its
+	// parent operation. The returned context is given to the nested
+	// operations and the function is called with the result of the
+	// operation when it finishes.
+	Start(ctx context.Context, op Operation) (context.Context, func(error))
+}
+
+// Operation describes an operation notified to a Hook, the IDs not known by
+// the operation are empty.
+type Operation struct {
+	// Name is the name of the operation, like plain.Library.Has.
+	Name string
+	// ID is the RepositoryID of the repository.
+	ID borges.RepositoryID
+	// Location is the LocationID of the Location performing it.
+	Location borges.LocationID
+	// Library is the LibraryID of the Library performing it.
+	Library borges.LibraryID
+}
+
+type hookKey struct{}
+
+// WithHook returns a copy of ctx carrying the given Hook, the Library and
+// Location returned by WithContext with that context notify it.
+func WithHook(ctx context.Context, h Hook) context.Context {
+	return context.WithValue(ctx, hookKey{}, h)
+}
+
+// trace notifies the operations to the Hook of a context, the zero value
+// notifies nothing.
+type trace struct {
+	ctx  context.Context
+	hook Hook
+}
+
+func newTrace(ctx context.Context) trace {
+	h, _ := ctx.Value(hookKey{}).(Hook)
+	return trace{ctx: ctx, hook: h}
+}
+
+// start notifies the start of the operation, the returned trace is the one
+// of the nested operations.
+func (t trace) start(op Operation) (trace, func(error)) {
+	if t.hook == nil {
+		return t, func(error) {}
+	}
+
+	ctx, done := t.hook.Start(t.ctx, op)
+	return trace{ctx: ctx, hook: t.hook}, done
+}
+
+// WithContext returns the Library notifying its operations to the Hook
+// carried by ctx, see WithHook. Without Hook the Library itself is returned.
+func (l *Library) WithContext(ctx context.Context) borges.Library {
+	t := newTrace(ctx)
+	if t.hook == nil {
+		return l
+	}
+
+	return &hookedLibrary{library: l, t: t}
+}
+
+// library allows embedding Library, otherwise the field would clash with the
+// Library method.
+type library = Library
+
+// hookedLibrary is a Library notifying the lookups of Has and Get.
+type hookedLibrary struct {
+	*library
+	t trace
+}
+
+// Has honors the borges.Library interface.
+func (l *hookedLibrary) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	return l.library.has(l.t, id)
+}
+
+// Get honors the borges.Library interface.
+func (l *hookedLibrary) Get(id borges.RepositoryID, m borges.Mode) (borges.Repository, error) {
+	return l.library.get(l.t, id, m)
+}
+
+// WithContext returns the Location notifying its operations to the Hook
+// carried by ctx, see WithHook. Without Hook the Location itself is
+// returned.
+func (l *Location) WithContext(ctx context.Context) borges.Location {
+	t := newTrace(ctx)
+	if t.hook == nil {
+		return l
+	}
+
+	return &hookedLocation{Location: l, t: t}
+}
+
+// hookedLocation is a Location notifying the operations of Has and Get.
+type hookedLocation struct {
+	*Location
+	t trace
+}
+
+// Has honors the borges.Location interface.
+func (l *hookedLocation) Has(id borges.RepositoryID) (bool, error) {
+	return l.Location.has(l.t, id)
+}
+
+// Get honors the borges.Location interface.
+func (l *hookedLocation) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	return l.Location.get(l.t, id, mode)
+}
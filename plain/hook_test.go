@@ -0,0 +1,64 @@
+package plain
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+type depthKey struct{}
+
+// recordingHook records the operations indented by their depth.
+type recordingHook struct {
+	ops []string
+}
+
+func (h *recordingHook) Start(ctx context.Context, op Operation) (context.Context, func(error)) {
+	depth, _ := ctx.Value(depthKey{}).(int)
+	h.ops = append(h.ops, strings.Repeat("  ", depth)+op.Name+" "+op.ID.String())
+	return context.WithValue(ctx, depthKey{}, depth+1), func(error) {}
+}
+
+func TestLibrary_WithContext(t *testing.T) {
+	require := require.New(t)
+
+	loc, err := NewLocation("foo", memfs.New(), nil)
+	require.NoError(err)
+	r, err := loc.Init("github.com/foo/bar")
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	sub := NewLibrary("sub")
+	sub.AddLocation(loc)
+	lib := NewLibrary("foo")
+	lib.AddLibrary(sub)
+
+	require.Equal(lib, lib.WithContext(context.Background()))
+	require.Equal(loc, loc.WithContext(context.Background()))
+
+	h := &recordingHook{}
+	ctx := WithHook(context.Background(), h)
+
+	r, err = lib.WithContext(ctx).Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	ok, err := loc.WithContext(ctx).Has("github.com/foo/qux")
+	require.NoError(err)
+	require.False(ok)
+
+	require.Equal([]string{
+		"plain.Library.Get github.com/foo/bar",
+		"  plain.Library.doGetOnLocations github.com/foo/bar",
+		"  plain.Library.doGetOnLibraries github.com/foo/bar",
+		"    plain.Library.doHasOnLocations github.com/foo/bar",
+		"      plain.Location.Has github.com/foo/bar",
+		"    plain.openRepository github.com/foo/bar",
+		"plain.Location.Has github.com/foo/qux",
+	}, h.ops)
+}
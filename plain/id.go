@@ -0,0 +1,161 @@
+package plain
+
+import (
+	"strings"
+
+	"github.com/src-d/go-borges"
+)
+
+const (
+	escapeChar = '%'
+	upperhex   = "0123456789ABCDEF"
+	// MaxNameLength is the maximum length of every element of the path
+	// where a repository is stored, the NAME_MAX of most filesystems.
+	MaxNameLength = 255
+)
+
+// gitInternalNames are the entries of a git directory, they can't be used
+// as elements of a RepositoryID, other than the first, because in bare
+// locations the repository would end inside the git directory of its parent.
+var gitInternalNames = []string{
+	"HEAD", "config", "description", "index", "packed-refs", "shallow",
+	"objects", "refs", "hooks", "info", "logs", "modules", "worktrees",
+	"branches", "FETCH_HEAD", "ORIG_HEAD", "MERGE_HEAD", "COMMIT_EDITMSG",
+}
+
+// ValidateRepositoryID returns ErrInvalidRepositoryID if the given
+// RepositoryID can't be safely stored in a filesystem: empty IDs, absolute
+// paths, NUL bytes and ".", ".." or ".git" elements are rejected.
+func ValidateRepositoryID(id borges.RepositoryID) error {
+	s := id.String()
+	switch {
+	case s == "":
+		return borges.ErrInvalidRepositoryID.New(id, "empty id")
+	case strings.IndexByte(s, 0) != -1:
+		return borges.ErrInvalidRepositoryID.New(id, "contains NUL bytes")
+	case s[0] == '/' || s[0] == '\\':
+		return borges.ErrInvalidRepositoryID.New(id, "absolute path")
+	}
+
+	for _, part := range strings.Split(s, "/") {
+		if part == "." || part == ".." {
+			return borges.ErrInvalidRepositoryID.New(id, "relative path element")
+		}
+
+		if strings.EqualFold(part, ".git") {
+			return borges.ErrInvalidRepositoryID.New(id, "git directory element")
+		}
+	}
+
+	return nil
+}
+
+// validateNestedID returns ErrInvalidRepositoryID if any element of the
+// given RepositoryID, other than the first, is the name of a git directory
+// entry.
+func validateNestedID(id borges.RepositoryID) error {
+	parts := strings.Split(id.String(), "/")
+	for _, part := range parts[1:] {
+		for _, name := range gitInternalNames {
+			if strings.EqualFold(part, name) {
+				return borges.ErrInvalidRepositoryID.New(id, "git directory entry "+part)
+			}
+		}
+	}
+
+	return nil
+}
+
+// validatePath returns ErrInvalidRepositoryID if any element of the path
+// where the repository with the given RepositoryID is stored is longer than
+// MaxNameLength.
+func validatePath(id borges.RepositoryID, path string) error {
+	for _, part := range strings.Split(path, "/") {
+		if len(part) > MaxNameLength {
+			return borges.ErrInvalidRepositoryID.New(id, "path element too long")
+		}
+	}
+
+	return nil
+}
+
+// EscapeRepositoryID returns the relative path where a repository with the
+// given RepositoryID is stored. The escaping is reversible with
+// UnescapeRepositoryID and produces paths safe on case-insensitive
+// filesystems:
+//
+//   - lowercase letters, digits, '-', '_' and '.' are kept, except a '.' at
+//     the beginning of a path element, avoiding clashes with names like .git.
+//   - '/' is kept as separator unless it would produce an empty element.
+//   - any other byte, including uppercase letters, is encoded as %XX.
+func EscapeRepositoryID(id borges.RepositoryID) string {
+	s := id.String()
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c == '/':
+			if i == 0 || s[i-1] == '/' || i == len(s)-1 {
+				escapeByte(&b, c)
+				continue
+			}
+		case c == '.':
+			if i == 0 || s[i-1] == '/' {
+				escapeByte(&b, c)
+				continue
+			}
+		case !isSafeByte(c):
+			escapeByte(&b, c)
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return b.String()
+}
+
+func isSafeByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_'
+}
+
+func escapeByte(b *strings.Builder, c byte) {
+	b.WriteByte(escapeChar)
+	b.WriteByte(upperhex[c>>4])
+	b.WriteByte(upperhex[c&15])
+}
+
+// UnescapeRepositoryID returns the RepositoryID stored at the given path
+// escaped with EscapeRepositoryID. Invalid escape sequences are kept as they
+// are, so paths not created by EscapeRepositoryID are returned unchanged.
+func UnescapeRepositoryID(path string) borges.RepositoryID {
+	var b strings.Builder
+	for i := 0; i < len(path); i++ {
+		c := path[i]
+		if c == escapeChar && i+2 < len(path) && isHex(path[i+1]) && isHex(path[i+2]) {
+			b.WriteByte(unhex(path[i+1])<<4 | unhex(path[i+2]))
+			i += 2
+			continue
+		}
+
+		b.WriteByte(c)
+	}
+
+	return borges.RepositoryID(b.String())
+}
+
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+func unhex(c byte) byte {
+	switch {
+	case c >= '0' && c <= '9':
+		return c - '0'
+	case c >= 'a' && c <= 'f':
+		return c - 'a' + 10
+	default:
+		return c - 'A' + 10
+	}
+}
@@ -0,0 +1,151 @@
+package plain
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+func TestValidateRepositoryID(t *testing.T) {
+	require := require.New(t)
+
+	for _, id := range []borges.RepositoryID{
+		"github.com/foo/bar",
+		"github.com/foo/bar.git",
+		"http://github.com/foo/bar",
+		"foo..bar",
+		"foo/.gitignore",
+	} {
+		require.NoError(ValidateRepositoryID(id), id)
+	}
+
+	for _, id := range []borges.RepositoryID{
+		"",
+		"/etc/passwd",
+		"\\windows",
+		"foo/../../bar",
+		"..",
+		"foo/./bar",
+		"foo\x00bar",
+		"foo/.git",
+		"foo/.GIT/refs",
+		".git",
+	} {
+		err := ValidateRepositoryID(id)
+		require.True(borges.ErrInvalidRepositoryID.Is(err), id)
+	}
+}
+
+func TestEscapeRepositoryID(t *testing.T) {
+	require := require.New(t)
+
+	for id, expected := range map[borges.RepositoryID]string{
+		"github.com/foo/bar":        "github.com/foo/bar",
+		"github.com/foo/bar.git":    "github.com/foo/bar.git",
+		"github.com/Foo/Bar":        "github.com/%46oo/%42ar",
+		"http://github.com/foo/bar": "http%3A/%2Fgithub.com/foo/bar",
+		"foo/.git":                  "foo/%2Egit",
+		"foo/":                      "foo%2F",
+		"foo%bar":                   "foo%25bar",
+		"foo bar:baz":               "foo%20bar%3Abaz",
+	} {
+		require.Equal(expected, EscapeRepositoryID(id), id)
+		require.Equal(id, UnescapeRepositoryID(expected), id)
+	}
+}
+
+func TestUnescapeRepositoryID_Invalid(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal(borges.RepositoryID("foo%zz"), UnescapeRepositoryID("foo%zz"))
+	require.Equal(borges.RepositoryID("foo%2"), UnescapeRepositoryID("foo%2"))
+}
+
+func TestLocation_InvalidRepositoryID(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), nil)
+	require.NoError(err)
+
+	_, err = location.Init("../foo")
+	require.True(borges.ErrInvalidRepositoryID.Is(err))
+
+	_, err = location.Has("/foo")
+	require.True(borges.ErrInvalidRepositoryID.Is(err))
+
+	_, err = location.Get("foo/../../bar", borges.RWMode)
+	require.True(borges.ErrInvalidRepositoryID.Is(err))
+
+	_, err = location.GetOrInit("")
+	require.True(borges.ErrInvalidRepositoryID.Is(err))
+}
+
+func TestLocation_EscapedRepositoryID(t *testing.T) {
+	require := require.New(t)
+
+	fs := memfs.New()
+	location, err := NewLocation("foo", fs, &LocationOptions{
+		IDMapper: EscapedMapper{},
+	})
+	require.NoError(err)
+
+	ids := []borges.RepositoryID{
+		"github.com/foo/bar",
+		"github.com/Foo/Bar",
+		"http://github.com/foo/bar",
+		"github.com/foo/.bar",
+	}
+
+	for _, id := range ids {
+		_, err = location.Init(id)
+		require.NoError(err)
+	}
+
+	_, err = fs.Stat("github.com/%46oo/%42ar/.git")
+	require.NoError(err)
+
+	iter, err := location.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var found []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		found = append(found, r.ID())
+		return nil
+	})
+
+	require.NoError(err)
+	require.ElementsMatch(ids, found)
+}
+
+func TestLocation_IdentityLayout(t *testing.T) {
+	require := require.New(t)
+
+	fs := memfs.New()
+	createValidDotGit(require, fs, "github.com/Foo/Bar/.git")
+	createValidDotGit(require, fs, "github.com/foo/%42az/.git")
+
+	location, err := NewLocation("foo", fs, nil)
+	require.NoError(err)
+
+	iter, err := location.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var found []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		found = append(found, r.ID())
+		return nil
+	})
+	require.NoError(err)
+
+	expected := []borges.RepositoryID{"github.com/Foo/Bar", "github.com/foo/%42az"}
+	require.ElementsMatch(expected, found)
+
+	for _, id := range expected {
+		r, err := location.Get(id, borges.ReadOnlyMode)
+		require.NoError(err, id)
+		require.NoError(r.Close())
+	}
+}
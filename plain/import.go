@@ -0,0 +1,250 @@
+package plain
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	butil "gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// ErrNotRepository is returned when the path to import doesn't contain a git
+// repository.
+var ErrNotRepository = errors.NewKind("path %s is not a git repository")
+
+// Import copies the git repository stored at srcPath in srcFS into this
+// Location as the repository with the given RepositoryID. The repository can
+// be bare or not, it's converted to the layout required by
+// LocationOptions.Bare, the working tree and the index are never imported.
+//
+// When both filesystems are osfs the objects are hardlinked instead of
+// copied if possible. The origin remote is set to the RepositoryID as Init
+// does. Import doesn't honor the LocationOptions.StorerFactory, the files are
+// written as a regular git repository.
+//
+// If a repository with the given RepositoryID already exists
+// ErrRepositoryExists is returned and if srcPath doesn't contain a repository
+// ErrNotRepository is returned.
+func (l *Location) Import(srcFS billy.Filesystem, srcPath string, id borges.RepositoryID) error {
+	has, err := l.Has(id)
+	if err != nil {
+		return err
+	}
+
+	if has {
+		return borges.ErrRepositoryExists.New(id)
+	}
+
+	src, err := gitDirOf(srcFS, srcPath)
+	if err != nil {
+		return err
+	}
+
+	defer l.usage.invalidate()
+	path := l.RepositoryPath(id)
+	if err := l.importRepository(srcFS, src, path, id); err != nil {
+		_ = butil.RemoveAll(l.fs, path)
+		l.removeEmptyDirs(filepath.Dir(path))
+		return err
+	}
+
+	return nil
+}
+
+// removeEmptyDirs removes the given directory and its parents while they
+// are empty, the root of the Location is never removed.
+func (l *Location) removeEmptyDirs(dir string) {
+	for dir != "" && dir != "." && dir != "/" {
+		entries, err := l.fs.ReadDir(dir)
+		if err != nil || len(entries) != 0 {
+			return
+		}
+
+		if err := l.fs.Remove(dir); err != nil {
+			return
+		}
+
+		dir = filepath.Dir(dir)
+	}
+}
+
+// gitDirOf returns the path of the git directory of the repository stored
+// at path, it can be a bare repository or a working tree containing it.
+func gitDirOf(fs billy.Filesystem, path string) (string, error) {
+	for _, bare := range []bool{true, false} {
+		is, err := IsRepository(fs, path, bare)
+		if err != nil {
+			return "", err
+		}
+
+		if !is {
+			continue
+		}
+
+		if bare {
+			return path, nil
+		}
+
+		return fs.Join(path, ".git"), nil
+	}
+
+	return "", ErrNotRepository.New(path)
+}
+
+func (l *Location) importRepository(
+	srcFS billy.Filesystem,
+	src, dst string,
+	id borges.RepositoryID,
+) error {
+	if err := importDir(srcFS, src, l.fs, dst, false); err != nil {
+		return err
+	}
+
+	if err := l.importConfig(dst, id); err != nil {
+		return err
+	}
+
+	is, err := IsRepository(l.fs, dst, true)
+	if err != nil {
+		return err
+	}
+
+	if !is {
+		return ErrNotRepository.New(dst)
+	}
+
+	return nil
+}
+
+// importConfig updates the configuration of the imported repository to
+// match the Location layout and sets the origin remote.
+func (l *Location) importConfig(path string, id borges.RepositoryID) error {
+	fs, err := l.fs.Chroot(path)
+	if err != nil {
+		return err
+	}
+
+	s := l.opts.newStorage(fs)
+	defer s.Close()
+
+	cfg, err := s.Config()
+	if err != nil {
+		return err
+	}
+
+	cfg.Core.IsBare = l.opts.Bare
+	cfg.Core.Worktree = ""
+	cfg.Remotes["origin"] = &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{id.String()},
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	return s.SetConfig(cfg)
+}
+
+// importDir copies recursively the directory src into dst, the index is
+// skipped and the objects are hardlinked when possible.
+func importDir(
+	srcFS billy.Filesystem, src string,
+	dstFS billy.Filesystem, dst string,
+	objects bool,
+) error {
+	entries, err := srcFS.ReadDir(src)
+	if err != nil {
+		return err
+	}
+
+	if err := dstFS.MkdirAll(dst, 0755); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		name := e.Name()
+		from, to := srcFS.Join(src, name), dstFS.Join(dst, name)
+
+		switch {
+		case e.IsDir():
+			err = importDir(srcFS, from, dstFS, to, objects || name == "objects")
+		case name == "index" && !objects:
+			continue
+		case objects && link(srcFS, from, dstFS, to):
+			continue
+		default:
+			err = copyFile(srcFS, from, dstFS, to, e.Mode())
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// link tries to hardlink the given file, it returns false if both
+// filesystems aren't osfs or the link fails.
+func link(srcFS billy.Filesystem, src string, dstFS billy.Filesystem, dst string) bool {
+	from, ok := osPath(srcFS, src)
+	if !ok {
+		return false
+	}
+
+	to, ok := osPath(dstFS, dst)
+	if !ok {
+		return false
+	}
+
+	return os.Link(from, to) == nil
+}
+
+// osPath returns the path in the os filesystem of the given file if the
+// filesystem is an osfs.
+func osPath(fs billy.Filesystem, path string) (string, bool) {
+	var b billy.Basic = fs
+	for {
+		if _, ok := b.(*osfs.OS); ok {
+			return filepath.Join(fs.Root(), path), true
+		}
+
+		u, ok := b.(interface{ Underlying() billy.Basic })
+		if !ok {
+			return "", false
+		}
+
+		b = u.Underlying()
+	}
+}
+
+func copyFile(
+	srcFS billy.Filesystem, src string,
+	dstFS billy.Filesystem, dst string,
+	mode os.FileMode,
+) (err error) {
+	from, err := srcFS.Open(src)
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(from, &err)
+
+	to, err := dstFS.OpenFile(dst, os.O_CREATE|os.O_EXCL|os.O_WRONLY, mode.Perm())
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(to, &err)
+
+	_, err = io.Copy(to, from)
+	return err
+}
@@ -0,0 +1,171 @@
+package plain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git-fixtures.v3"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func newImportSource(require *require.Assertions, bare bool) (billy.Filesystem, string) {
+	fixtures.Init()
+
+	dir, err := ioutil.TempDir("", "import")
+	require.NoError(err)
+
+	path := filepath.Join(dir, "src")
+	if !bare {
+		require.NoError(os.MkdirAll(path, 0755))
+		path = filepath.Join(path, ".git")
+	}
+
+	extractFixture(require, fixtures.Basic().One(), path)
+	return osfs.New(dir), "src"
+}
+
+func TestLocation_Import(t *testing.T) {
+	for _, c := range []struct{ srcBare, bare bool }{
+		{true, true},
+		{true, false},
+		{false, true},
+		{false, false},
+	} {
+		require := require.New(t)
+
+		fs, path := newImportSource(require, c.srcBare)
+		location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+			Bare: c.bare,
+		})
+		require.NoError(err)
+
+		id := borges.RepositoryID("github.com/foo/bar")
+		require.NoError(location.Import(fs, path, id))
+
+		is, err := IsRepository(location.fs, "github.com/foo/bar", c.bare)
+		require.NoError(err)
+		require.True(is)
+
+		_, err = location.fs.Stat(location.fs.Join(location.RepositoryPath(id), "index"))
+		require.True(os.IsNotExist(err))
+
+		r, err := location.Get(id, borges.ReadOnlyMode)
+		require.NoError(err)
+
+		head, err := r.R().Head()
+		require.NoError(err)
+		require.Equal(
+			plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5"),
+			head.Hash(),
+		)
+
+		cfg, err := r.R().Config()
+		require.NoError(err)
+		require.Equal(c.bare, cfg.Core.IsBare)
+		require.Equal([]string{id.String()}, cfg.Remotes["origin"].URLs)
+		require.NoError(r.Close())
+		require.NoError(os.RemoveAll(fs.Root()))
+	}
+}
+
+func TestLocation_Import_Hardlink(t *testing.T) {
+	require := require.New(t)
+
+	fs, path := newImportSource(require, true)
+	defer os.RemoveAll(fs.Root())
+
+	dir, err := ioutil.TempDir("", "location")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	location, err := NewLocation("foo", osfs.New(dir), &LocationOptions{
+		Bare: true,
+	})
+	require.NoError(err)
+	require.NoError(location.Import(fs, path, "foo"))
+
+	packs, err := fs.ReadDir("src/objects/pack")
+	require.NoError(err)
+	require.NotEmpty(packs)
+
+	for _, p := range packs {
+		src, err := os.Stat(filepath.Join(fs.Root(), "src/objects/pack", p.Name()))
+		require.NoError(err)
+		dst, err := os.Stat(filepath.Join(dir, "foo/objects/pack", p.Name()))
+		require.NoError(err)
+		require.True(os.SameFile(src, dst), p.Name())
+	}
+
+	src, err := os.Stat(filepath.Join(fs.Root(), "src/config"))
+	require.NoError(err)
+	dst, err := os.Stat(filepath.Join(dir, "foo/config"))
+	require.NoError(err)
+	require.False(os.SameFile(src, dst))
+}
+
+func TestLocation_Import_Errors(t *testing.T) {
+	require := require.New(t)
+
+	fs, path := newImportSource(require, true)
+	defer os.RemoveAll(fs.Root())
+
+	location, err := NewLocation("foo", memfs.New(), nil)
+	require.NoError(err)
+
+	_, err = location.Init("foo")
+	require.NoError(err)
+
+	err = location.Import(fs, path, "foo")
+	require.True(borges.ErrRepositoryExists.Is(err))
+
+	err = location.Import(fs, "src/objects", "bar")
+	require.True(ErrNotRepository.Is(err))
+
+	err = location.Import(fs, path, "../bar")
+	require.True(borges.ErrInvalidRepositoryID.Is(err))
+
+	has, err := location.Has("bar")
+	require.NoError(err)
+	require.False(has)
+}
+
+func TestLocation_Import_CleanupOnError(t *testing.T) {
+	for _, c := range []struct {
+		bare   bool
+		mapper IDMapper
+	}{
+		{false, IdentityMapper{}},
+		{true, ShardedMapper{}},
+	} {
+		require := require.New(t)
+
+		src := memfs.New()
+		createValidDotGit(require, src, "src")
+		f, err := src.Create("src/config")
+		require.NoError(err)
+		_, err = f.Write([]byte("[core"))
+		require.NoError(err)
+		require.NoError(f.Close())
+
+		fs := memfs.New()
+		location, err := NewLocation("foo", fs, &LocationOptions{
+			Bare:     c.bare,
+			IDMapper: c.mapper,
+		})
+		require.NoError(err)
+
+		require.Error(location.Import(src, "src", "github.com/foo/bar"))
+
+		entries, err := fs.ReadDir("")
+		require.NoError(err)
+		require.Empty(entries)
+	}
+}
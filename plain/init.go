@@ -0,0 +1,200 @@
+package plain
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-billy.v4"
+	butil "gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	format "gopkg.in/src-d/go-git.v4/plumbing/format/config"
+)
+
+var (
+	// ErrInvalidConfigKey is returned when a config key of InitOptions
+	// doesn't follow the format section.option or section.subsection.option.
+	ErrInvalidConfigKey = errors.NewKind("invalid config key %q")
+	// ErrInvalidTemplatePath is returned when a template path of
+	// InitOptions is empty, absolute or contains ".." elements.
+	ErrInvalidTemplatePath = errors.NewKind("invalid template path %q")
+)
+
+// InitOptions contains the configuration applied to the new repositories.
+type InitOptions struct {
+	// RemoteName is the name of the remote pointing to the repository, by
+	// default origin.
+	RemoteName string
+	// URLs are the URLs of the remote called RemoteName, if empty the
+	// RepositoryID is used.
+	URLs []string
+	// Remotes contains extra remotes to be created, if the fetch refspecs
+	// are empty the default ones are used.
+	Remotes []*config.RemoteConfig
+	// HEAD is the reference HEAD points to, by default refs/heads/master.
+	HEAD plumbing.ReferenceName
+	// Config contains arbitrary config options, the keys have the format
+	// section.option or section.subsection.option, eg.: gc.auto or
+	// branch.master.remote.
+	Config map[string]string
+	// Templates contains files to be written in the git directory, the keys
+	// are paths relative to it, eg.: description or info/exclude, they can't
+	// point outside of it. In transactional mode they are written on Commit.
+	Templates map[string][]byte
+}
+
+// Validate validates the fields, the InitOptions aren't modified, the empty
+// fields get their default values when the options are applied.
+func (o *InitOptions) Validate() error {
+	for k := range o.Config {
+		if _, _, _, err := splitConfigKey(k); err != nil {
+			return err
+		}
+	}
+
+	for name := range o.Templates {
+		if err := validateTemplatePath(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *InitOptions) remoteName() string {
+	if o.RemoteName == "" {
+		return "origin"
+	}
+
+	return o.RemoteName
+}
+
+func (o *InitOptions) head() plumbing.ReferenceName {
+	if o.HEAD == "" {
+		return plumbing.Master
+	}
+
+	return o.HEAD
+}
+
+// validateTemplatePath returns ErrInvalidTemplatePath if the given path is
+// empty, absolute or contains ".." elements.
+func validateTemplatePath(name string) error {
+	if name == "" || name[0] == '/' || name[0] == '\\' || filepath.IsAbs(name) {
+		return ErrInvalidTemplatePath.New(name)
+	}
+
+	for _, part := range strings.FieldsFunc(name, func(r rune) bool {
+		return r == '/' || r == '\\'
+	}) {
+		if part == ".." {
+			return ErrInvalidTemplatePath.New(name)
+		}
+	}
+
+	return nil
+}
+
+// splitConfigKey splits a key like section.subsection.option, the
+// subsection can contain dots.
+func splitConfigKey(key string) (section, subsection, option string, err error) {
+	first := strings.Index(key, ".")
+	last := strings.LastIndex(key, ".")
+	if first <= 0 || last == len(key)-1 {
+		return "", "", "", ErrInvalidConfigKey.New(key)
+	}
+
+	section, option = key[:first], key[last+1:]
+	if first != last {
+		subsection = key[first+1 : last]
+	}
+
+	return section, subsection, option, nil
+}
+
+// apply configures the given repository, initialized with the given
+// RepositoryID, following the InitOptions.
+func (o *InitOptions) apply(r *git.Repository, id borges.RepositoryID) error {
+	urls := o.URLs
+	if len(urls) == 0 {
+		urls = []string{id.String()}
+	}
+
+	remotes := append([]*config.RemoteConfig{{
+		Name: o.remoteName(),
+		URLs: urls,
+	}}, o.Remotes...)
+
+	for _, rc := range remotes {
+		rc := *rc
+		if _, err := r.CreateRemote(&rc); err != nil {
+			return err
+		}
+	}
+
+	if o.head() != plumbing.Master {
+		head := plumbing.NewSymbolicReference(plumbing.HEAD, o.head())
+		if err := r.Storer.SetReference(head); err != nil {
+			return err
+		}
+	}
+
+	if len(o.Config) == 0 {
+		return nil
+	}
+
+	cfg, err := r.Storer.Config()
+	if err != nil {
+		return err
+	}
+
+	for k, v := range o.Config {
+		section, subsection, option, err := splitConfigKey(k)
+		if err != nil {
+			return err
+		}
+
+		s := cfg.Raw.Section(section)
+		if subsection == "" {
+			s.SetOption(option, v)
+			continue
+		}
+
+		s.Subsection(subsection).SetOption(option, v)
+	}
+
+	// the raw config is parsed again to keep the structured fields, like
+	// Core or Remotes, in sync with the options set.
+	var buf bytes.Buffer
+	if err := format.NewEncoder(&buf).Encode(cfg.Raw); err != nil {
+		return err
+	}
+
+	if err := cfg.Unmarshal(buf.Bytes()); err != nil {
+		return err
+	}
+
+	return r.Storer.SetConfig(cfg)
+}
+
+// writeTemplates writes the template files at the git directory in path.
+func (o *InitOptions) writeTemplates(fs billy.Filesystem, path string) error {
+	for name, content := range o.Templates {
+		if err := validateTemplatePath(name); err != nil {
+			return err
+		}
+
+		err := butil.WriteFile(fs, fs.Join(path, name), content, os.FileMode(0644))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,205 @@
+package plain
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func TestInitOptions_Validate(t *testing.T) {
+	require := require.New(t)
+
+	opts := &InitOptions{Templates: map[string][]byte{"info/exclude": nil}}
+	require.NoError(opts.Validate())
+	require.Equal(&InitOptions{Templates: map[string][]byte{"info/exclude": nil}}, opts)
+	require.Equal("origin", opts.remoteName())
+	require.Equal(plumbing.Master, opts.head())
+
+	for _, key := range []string{"foo", ".foo", "foo.", "foo.bar."} {
+		opts := &InitOptions{Config: map[string]string{key: "qux"}}
+		require.True(ErrInvalidConfigKey.Is(opts.Validate()), key)
+	}
+
+	for _, name := range []string{
+		"",
+		"/etc/passwd",
+		"\\config",
+		"../../other/repo/config",
+		"info/../../config",
+		"info\\..\\..\\config",
+	} {
+		opts := &InitOptions{Templates: map[string][]byte{name: nil}}
+		require.True(ErrInvalidTemplatePath.Is(opts.Validate()), name)
+	}
+}
+
+func TestLocation_InitWithOptions_InvalidTemplate(t *testing.T) {
+	require := require.New(t)
+
+	fs := memfs.New()
+	location, err := NewLocation("foo", fs, nil)
+	require.NoError(err)
+
+	_, err = location.InitWithOptions("foo/bar", &InitOptions{
+		Templates: map[string][]byte{"../../../baz/config": []byte("foo")},
+	})
+	require.True(ErrInvalidTemplatePath.Is(err))
+
+	_, err = fs.Stat("baz")
+	require.Error(err)
+}
+
+func TestLocation_InitWithOptions_TemplateFailed(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "location")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	location, err := NewLocation("foo", osfs.New(dir), nil)
+	require.NoError(err)
+
+	// HEAD is a file so the template can't be written.
+	_, err = location.InitWithOptions("foo/bar", &InitOptions{
+		Templates: map[string][]byte{"HEAD/foo": []byte("foo")},
+	})
+	require.Error(err)
+
+	_, err = os.Stat(filepath.Join(dir, "foo", "bar"))
+	require.True(os.IsNotExist(err))
+
+	r, err := location.Init("foo/bar")
+	require.NoError(err)
+	require.NoError(r.Close())
+}
+
+func TestSplitConfigKey(t *testing.T) {
+	require := require.New(t)
+
+	section, subsection, option, err := splitConfigKey("gc.auto")
+	require.NoError(err)
+	require.Equal([]string{"gc", "", "auto"}, []string{section, subsection, option})
+
+	section, subsection, option, err = splitConfigKey("url.https://github.com/.insteadOf")
+	require.NoError(err)
+	require.Equal(
+		[]string{"url", "https://github.com/", "insteadOf"},
+		[]string{section, subsection, option},
+	)
+}
+
+func newInitOptions() *InitOptions {
+	return &InitOptions{
+		RemoteName: "upstream",
+		URLs:       []string{"https://github.com/foo/bar"},
+		Remotes: []*config.RemoteConfig{{
+			Name:  "mirror",
+			URLs:  []string{"https://mirror.example.com/foo/bar"},
+			Fetch: []config.RefSpec{"+refs/heads/master:refs/remotes/mirror/master"},
+		}},
+		HEAD: plumbing.NewBranchReferenceName("main"),
+		Config: map[string]string{
+			"gc.auto":                      "0",
+			"branch.main.remote":           "upstream",
+			"core.repositoryformatversion": "0",
+		},
+		Templates: map[string][]byte{
+			"description":  []byte("foo\n"),
+			"info/exclude": []byte("*.o\n"),
+		},
+	}
+}
+
+func testInitOptions(require *require.Assertions, l *Location, r borges.Repository) {
+	cfg, err := r.R().Config()
+	require.NoError(err)
+
+	require.Len(cfg.Remotes, 2)
+	require.Equal([]string{"https://github.com/foo/bar"}, cfg.Remotes["upstream"].URLs)
+	require.Equal(
+		[]config.RefSpec{"+refs/heads/master:refs/remotes/mirror/master"},
+		cfg.Remotes["mirror"].Fetch,
+	)
+	require.Equal("0", cfg.Raw.Section("gc").Option("auto"))
+	require.Equal("upstream", cfg.Raw.Section("branch").Subsection("main").Option("remote"))
+
+	head, err := r.R().Storer.Reference(plumbing.HEAD)
+	require.NoError(err)
+	require.Equal(plumbing.NewBranchReferenceName("main"), head.Target())
+
+	path := l.RepositoryPath(r.ID())
+	content := readFile(require, l.fs, l.fs.Join(path, "description"))
+	require.Equal("foo\n", string(content))
+
+	content = readFile(require, l.fs, l.fs.Join(path, "info", "exclude"))
+	require.Equal("*.o\n", string(content))
+}
+
+func TestLocation_InitWithOptions(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), nil)
+	require.NoError(err)
+
+	r, err := location.InitWithOptions("foo", newInitOptions())
+	require.NoError(err)
+	testInitOptions(require, location, r)
+
+	r, err = location.Init("bar")
+	require.NoError(err)
+
+	remote, err := r.R().Remote("origin")
+	require.NoError(err)
+	require.Equal([]string{"bar"}, remote.Config().URLs)
+
+	_, err = location.InitWithOptions("baz", &InitOptions{
+		Config: map[string]string{"foo": "bar"},
+	})
+	require.True(ErrInvalidConfigKey.Is(err))
+
+	has, err := location.Has("baz")
+	require.NoError(err)
+	require.False(has)
+}
+
+func TestLocation_InitWithOptions_Transactional(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional: true,
+		InitOptions:   newInitOptions(),
+	})
+	require.NoError(err)
+
+	r, err := location.GetOrInit("foo")
+	require.NoError(err)
+
+	_, err = location.fs.Stat(location.fs.Join(location.RepositoryPath("foo"), "description"))
+	require.Error(err)
+
+	require.NoError(r.Commit())
+
+	r, err = location.GetOrInitWithOptions("foo", &InitOptions{})
+	require.NoError(err)
+	testInitOptions(require, location, r)
+}
+
+func readFile(require *require.Assertions, fs billy.Filesystem, path string) []byte {
+	f, err := fs.Open(path)
+	require.NoError(err)
+	defer f.Close()
+
+	content, err := ioutil.ReadAll(f)
+	require.NoError(err)
+	return content
+}
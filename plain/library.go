@@ -1,24 +1,70 @@
 package plain
 
 import (
+	"time"
+
 	"github.com/src-d/go-borges"
 	"github.com/src-d/go-borges/util"
 )
 
+// LibraryOptions contains configuration options for a plain.Library.
+type LibraryOptions struct {
+	// Logger receives the lookups of the repositories. If empty
+	// borges.NoopLogger is used.
+	Logger borges.Logger
+	// StatsTTL is the time the result of Library.Stats is cached. If zero
+	// the statistics are aggregated on every call.
+	StatsTTL time.Duration
+	// LargestRepositories is the number of repositories reported in
+	// Stats.Largest. If zero DefaultLargestRepositories is used.
+	LargestRepositories int
+}
+
+// Validate validates the fields and sets the default values.
+func (o *LibraryOptions) Validate() error {
+	if o.Logger == nil {
+		o.Logger = borges.NoopLogger{}
+	}
+
+	if o.LargestRepositories <= 0 {
+		o.LargestRepositories = DefaultLargestRepositories
+	}
+
+	return nil
+}
+
 // Library represents a borges.Library implementation based on billy.Filesystems.
 type Library struct {
-	id   borges.LibraryID
-	locs map[borges.LocationID]*Location
-	libs map[borges.LibraryID]*Library
+	id    borges.LibraryID
+	locs  map[borges.LocationID]*Location
+	libs  map[borges.LibraryID]*Library
+	opts  *LibraryOptions
+	stats statsCache
 }
 
 // NewLibrary returns a new empty Library instance.
 func NewLibrary(id borges.LibraryID) *Library {
+	lib, _ := NewLibraryWithOptions(id, nil)
+	return lib
+}
+
+// NewLibraryWithOptions returns a new empty Library instance configured with
+// the given options.
+func NewLibraryWithOptions(id borges.LibraryID, opts *LibraryOptions) (*Library, error) {
+	if opts == nil {
+		opts = &LibraryOptions{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &Library{
 		id:   id,
 		locs: make(map[borges.LocationID]*Location, 0),
 		libs: make(map[borges.LibraryID]*Library, 0),
-	}
+		opts: opts,
+	}, nil
 }
 
 // ID returns the borges.LibraryID for this Library.
@@ -49,18 +95,34 @@ func (l *Library) Init(borges.RepositoryID) (borges.Repository, error) {
 // Has returns true, the LibraryID and the LocationID if the given RepositoryID
 // matches any repository at any location belonging to this Library.
 func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
-	ok, loc, err := l.doHasOnLocations(id)
+	return l.has(trace{}, id)
+}
+
+func (l *Library) has(t trace, id borges.RepositoryID) (
+	_ bool, _ borges.LibraryID, _ borges.LocationID, err error) {
+
+	t, done := t.start(Operation{Name: "plain.Library.Has", ID: id, Library: l.id})
+	defer func() { done(err) }()
+
+	ok, loc, err := l.doHasOnLocations(t, id)
 	if ok || err != nil {
 		return ok, l.ID(), loc.ID(), err
 	}
 
-	ok, lib, loc, err := l.doHasOnLibraries(id)
+	ok, lib, loc, err := l.doHasOnLibraries(t, id)
+	if !ok {
+		return false, "", "", err
+	}
+
 	return ok, lib.ID(), loc.ID(), err
 }
 
-func (l *Library) doHasOnLocations(id borges.RepositoryID) (bool, *Location, error) {
+func (l *Library) doHasOnLocations(t trace, id borges.RepositoryID) (_ bool, _ *Location, err error) {
+	t, done := t.start(Operation{Name: "plain.Library.doHasOnLocations", ID: id, Library: l.id})
+	defer func() { done(err) }()
+
 	for _, loc := range l.locs {
-		ok, err := loc.Has(id)
+		ok, err := loc.has(t, id)
 		if ok || err != nil {
 			return ok, loc, err
 		}
@@ -69,14 +131,19 @@ func (l *Library) doHasOnLocations(id borges.RepositoryID) (bool, *Location, err
 	return false, nil, nil
 }
 
-func (l *Library) doHasOnLibraries(id borges.RepositoryID) (bool, *Library, *Location, error) {
+func (l *Library) doHasOnLibraries(t trace, id borges.RepositoryID) (
+	_ bool, _ *Library, _ *Location, err error) {
+
+	t, done := t.start(Operation{Name: "plain.Library.doHasOnLibraries", ID: id, Library: l.id})
+	defer func() { done(err) }()
+
 	for _, lib := range l.libs {
-		ok, loc, err := lib.doHasOnLocations(id)
+		ok, loc, err := lib.doHasOnLocations(t, id)
 		if ok || err != nil {
 			return ok, lib, loc, err
 		}
 
-		ok, lib, loc, err := lib.doHasOnLibraries(id)
+		ok, lib, loc, err := lib.doHasOnLibraries(t, id)
 		if ok || err != nil {
 			return ok, lib, loc, err
 		}
@@ -89,7 +156,15 @@ func (l *Library) doHasOnLibraries(id borges.RepositoryID) (bool, *Library, *Loc
 // library locations until this repository is found. If a repository with the
 // given RepositoryID can't be found the ErrRepositoryNotExists is returned.
 func (l *Library) Get(id borges.RepositoryID, m borges.Mode) (borges.Repository, error) {
-	r, err := l.doGetOnLocations(id, m)
+	return l.get(trace{}, id, m)
+}
+
+func (l *Library) get(t trace, id borges.RepositoryID, m borges.Mode) (r borges.Repository, err error) {
+	t, done := t.start(Operation{Name: "plain.Library.Get", ID: id, Library: l.id})
+	defer func() { done(err) }()
+	defer func() { l.logGet(id, r, err) }()
+
+	r, err = l.doGetOnLocations(t, id, m)
 	if r != nil && err == nil {
 		return r, nil
 	}
@@ -98,38 +173,61 @@ func (l *Library) Get(id borges.RepositoryID, m borges.Mode) (borges.Repository,
 		return r, err
 	}
 
-	return l.doGetOnLibraries(id, m)
+	return l.doGetOnLibraries(t, id, m)
+}
+
+func (l *Library) logGet(id borges.RepositoryID, r borges.Repository, err error) {
+	fields := borges.Fields{"library": l.id, "id": id}
+	switch {
+	case borges.ErrRepositoryNotExists.Is(err):
+		l.opts.Logger.Debug("repository not found", fields)
+	case err != nil:
+		l.opts.Logger.Error("can't get repository", err, fields)
+	default:
+		fields["location"] = r.LocationID()
+		l.opts.Logger.Debug("repository found", fields)
+	}
 }
 
-func (l *Library) doGetOnLocations(id borges.RepositoryID, m borges.Mode) (borges.Repository, error) {
+func (l *Library) doGetOnLocations(t trace, id borges.RepositoryID, m borges.Mode) (
+	_ borges.Repository, err error) {
+
+	t, done := t.start(Operation{Name: "plain.Library.doGetOnLocations", ID: id, Library: l.id})
+	defer func() { done(err) }()
+
 	for _, loc := range l.locs {
-		ok, err := loc.Has(id)
+		ok, err := loc.has(t, id)
 		if err != nil {
 			return nil, err
 		}
 
 		if ok {
-			return openRepository(loc, id, m)
+			return loc.open(t, id, m)
 		}
 	}
 
 	return nil, borges.ErrRepositoryNotExists.New(id)
 }
 
-func (l *Library) doGetOnLibraries(id borges.RepositoryID, m borges.Mode) (borges.Repository, error) {
+func (l *Library) doGetOnLibraries(t trace, id borges.RepositoryID, m borges.Mode) (
+	_ borges.Repository, err error) {
+
+	t, done := t.start(Operation{Name: "plain.Library.doGetOnLibraries", ID: id, Library: l.id})
+	defer func() { done(err) }()
+
 	for _, lib := range l.libs {
-		ok, loc, err := lib.doHasOnLocations(id)
+		ok, loc, err := lib.doHasOnLocations(t, id)
 		if ok && err == nil {
-			return openRepository(loc, id, m)
+			return loc.open(t, id, m)
 		}
 
 		if err != nil {
 			return nil, err
 		}
 
-		ok, _, loc, err = lib.doHasOnLibraries(id)
+		ok, _, loc, err = lib.doHasOnLibraries(t, id)
 		if ok && err == nil {
-			return openRepository(loc, id, m)
+			return loc.open(t, id, m)
 		}
 
 		if err != nil {
@@ -154,6 +154,35 @@ func TestLibrary_Get_NotFound(t *testing.T) {
 	require.Nil(r)
 }
 
+func TestLibrary_Logger(t *testing.T) {
+	require := require.New(t)
+
+	logger := &testLogger{}
+	l, err := NewLibraryWithOptions("foo", &LibraryOptions{Logger: logger})
+	require.NoError(err)
+
+	lfoo, _ := NewLocation("foo", memfs.New(), nil)
+	l.AddLocation(lfoo)
+
+	_, err = lfoo.Init("http://github.com/foo/bar")
+	require.NoError(err)
+
+	_, err = l.Get("http://github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	_, err = l.Get("http://github.com/foo/qux", borges.ReadOnlyMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+
+	require.Equal([]string{
+		"debug: repository found",
+		"debug: repository not found",
+	}, logger.messages())
+
+	found := logger.entries[0]
+	require.Equal(borges.LibraryID("foo"), found.fields["library"])
+	require.Equal(borges.LocationID("foo"), found.fields["location"])
+}
+
 func TestLibrary_Locations(t *testing.T) {
 	require := require.New(t)
 
@@ -3,15 +3,41 @@ package plain
 import (
 	"io"
 	"os"
+	"runtime"
+	"strings"
+	"time"
 
 	"github.com/src-d/go-borges"
 	"github.com/src-d/go-borges/util"
 
 	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-billy.v4/memfs"
+	butil "gopkg.in/src-d/go-billy.v4/util"
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 )
 
+// ErrNoOrigin is returned by OriginID when the repository has no origin
+// remote.
+var ErrNoOrigin = errors.NewKind("repository has no origin remote")
+
+// StorerFactory returns the storage.Storer for the repository with the given
+// RepositoryID, fs is the filesystem containing the repository. The returned
+// storer is wrapped by the read-only or transactional storers when needed. If
+// it implements io.Closer it's closed by Repository.Close.
+type StorerFactory func(
+	fs billy.Filesystem,
+	id borges.RepositoryID,
+	mode borges.Mode,
+) (storage.Storer, error)
+
 // LocationOptions contains configuration options for a plain.Location.
+// The storage options are the ones supported by go-git filesystem.Storage,
+// it doesn't support loading static packfile indexes, a StorerFactory can be
+// used to provide storers with other loading strategies.
 type LocationOptions struct {
 	// Base defines if the location handle Bare git repositories or not.
 	Bare bool
@@ -22,6 +48,75 @@ type LocationOptions struct {
 	// like transactional operation files. If empty and Transactional is true
 	// a new memfs filesystem will be used.
 	TemporalFilesystem billy.Filesystem
+	// Cache defines the object cache shared by all the repositories opened
+	// from this location. If empty every repository gets its own
+	// cache.ObjectLRU of CacheSize.
+	Cache cache.Object
+	// CacheSize defines the maximum size of the object cache created for
+	// every repository when Cache is empty. If zero cache.DefaultMaxSize is
+	// used.
+	CacheSize cache.FileSize
+	// KeepDescriptors makes the storers reuse the packfile descriptors
+	// instead of opening them on every read, they are closed with
+	// Repository.Close.
+	KeepDescriptors bool
+	// ExclusiveAccess means that the filesystem is not modified externally
+	// while the repositories are open.
+	ExclusiveAccess bool
+	// MaxOpenDescriptors is the maximum number of packfile descriptors kept
+	// open by every storer, they are closed with Repository.Close. If
+	// KeepDescriptors is true all the descriptors are kept open.
+	MaxOpenDescriptors int
+	// StorerFactory defines how the storers of the repositories are built.
+	// If empty a filesystem.Storage is used.
+	StorerFactory StorerFactory
+	// IDMapper defines the layout of the repositories in the filesystem. If
+	// empty IdentityMapper is used. The repositories found by the
+	// LocationIterator at paths not following the layout are skipped.
+	IDMapper IDMapper
+	// IDFromOrigin makes the LocationIterator build the RepositoryIDs from
+	// the remote.origin.url of the repositories with borges.NewRepositoryID,
+	// instead of using the IDMapper. The IDMapper is still used when the
+	// repository has no origin or its URL can't be parsed. It's meant to scan
+	// existing trees of clones, these IDs can't be used to Get the
+	// repositories unless they match the paths.
+	IDFromOrigin bool
+	// InitOptions are the InitOptions used by Init and GetOrInit. If empty
+	// the default InitOptions are used.
+	InitOptions *InitOptions
+	// Validators are run by Repository.Commit, after checking the
+	// connectivity of the changed references, in transactional mode.
+	Validators []Validator
+	// Logger receives the scan decisions, the opened repositories, the
+	// commit outcomes and the errors not returned. If empty
+	// borges.NoopLogger is used.
+	Logger borges.Logger
+	// StatsWorkers is the number of repositories read in parallel by
+	// Location.Stats. If zero runtime.NumCPU is used.
+	StatsWorkers int
+	// StatsTTL is the time the result of Location.Stats is cached. If zero
+	// the statistics are computed on every call.
+	StatsTTL time.Duration
+	// LargestRepositories is the number of repositories reported in
+	// Stats.Largest. If zero DefaultLargestRepositories is used.
+	LargestRepositories int
+	// MaxBytes is the maximum size of the files of the location. When it's
+	// reached Init fails, and the writes and commits of transactional
+	// repositories going beyond it fail with ErrQuotaExceeded. If zero the
+	// size is unlimited. The pending writes of every open transaction are
+	// counted together and the commits are serialized to check it.
+	//
+	// Only transactional repositories are enforced, the writes of
+	// non-transactional ones go directly to the filesystem and bypass the
+	// quota, although they are accounted once the repository is closed. The
+	// size is computed on first use and updated by the operations of the
+	// Location, changes made to the filesystem by other means aren't seen.
+	MaxBytes int64
+	// MaxRepositoryBytes is the maximum size of every repository, the
+	// writes and commits of transactional repositories going beyond it fail
+	// with ErrQuotaExceeded. If zero the size is unlimited. As with MaxBytes,
+	// the writes of non-transactional repositories bypass it.
+	MaxRepositoryBytes int64
 }
 
 // Validate validates the fields and sets the default values.
@@ -30,15 +125,81 @@ func (o *LocationOptions) Validate() error {
 		o.TemporalFilesystem = memfs.New()
 	}
 
+	if o.CacheSize == 0 {
+		o.CacheSize = cache.DefaultMaxSize
+	}
+
+	if o.IDMapper == nil {
+		o.IDMapper = IdentityMapper{}
+	}
+
+	if o.InitOptions == nil {
+		o.InitOptions = &InitOptions{}
+	}
+
+	if o.Logger == nil {
+		o.Logger = borges.NoopLogger{}
+	}
+
+	if o.StatsWorkers <= 0 {
+		o.StatsWorkers = runtime.NumCPU()
+	}
+
+	if o.LargestRepositories <= 0 {
+		o.LargestRepositories = DefaultLargestRepositories
+	}
+
+	if err := o.InitOptions.Validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
+func (o *LocationOptions) cache() cache.Object {
+	if o.Cache != nil {
+		return o.Cache
+	}
+
+	return cache.NewObjectLRU(o.CacheSize)
+}
+
+func (o *LocationOptions) storageOptions() filesystem.Options {
+	return filesystem.Options{
+		ExclusiveAccess:    o.ExclusiveAccess,
+		KeepDescriptors:    o.KeepDescriptors,
+		MaxOpenDescriptors: o.MaxOpenDescriptors,
+	}
+}
+
+// storer returns the storage.Storer for the repository at the given
+// filesystem, built by the StorerFactory if any.
+func (o *LocationOptions) storer(
+	fs billy.Filesystem,
+	id borges.RepositoryID,
+	mode borges.Mode,
+) (storage.Storer, error) {
+	if o.StorerFactory != nil {
+		return o.StorerFactory(fs, id, mode)
+	}
+
+	return o.newStorage(fs), nil
+}
+
+// newStorage returns a new filesystem.Storage based on the given filesystem
+// configured with the cache and storage options of this location.
+func (o *LocationOptions) newStorage(fs billy.Filesystem) *filesystem.Storage {
+	return filesystem.NewStorageWithOptions(fs, o.cache(), o.storageOptions())
+}
+
 // Location implements borges.Location for plain repositories stored in a
 // billy.Filesystem.
 type Location struct {
-	id   borges.LocationID
-	fs   billy.Filesystem
-	opts *LocationOptions
+	id    borges.LocationID
+	fs    billy.Filesystem
+	opts  *LocationOptions
+	stats statsCache
+	usage usageCache
 }
 
 // NewLocation returns a new Location based on the given ID and Filesystem with
@@ -63,6 +224,16 @@ func (l *Location) ID() borges.LocationID {
 // GetOrInit get the requested repository based on the given id, or inits a
 // new repository. If the repository is opened this will be done in RWMode.
 func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	return l.GetOrInitWithOptions(id, nil)
+}
+
+// GetOrInitWithOptions behaves like GetOrInit but the repository is
+// initialized with the given InitOptions instead of the ones configured in
+// the LocationOptions.
+func (l *Location) GetOrInitWithOptions(
+	id borges.RepositoryID,
+	opts *InitOptions,
+) (borges.Repository, error) {
 	has, err := l.Has(id)
 	if err != nil {
 		return nil, err
@@ -72,11 +243,31 @@ func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error)
 		return l.Get(id, borges.RWMode)
 	}
 
-	return l.Init(id)
+	return l.InitWithOptions(id, opts)
 }
 
-// Init initializes a new Repository at this Location.
+// Init initializes a new Repository at this Location. If the given
+// RepositoryID is not valid ErrInvalidRepositoryID is returned and if the
+// Location reached LocationOptions.MaxBytes ErrQuotaExceeded.
 func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return l.InitWithOptions(id, nil)
+}
+
+// InitWithOptions initializes a new Repository at this Location with the
+// given InitOptions. If opts is nil the InitOptions of the LocationOptions
+// are used.
+func (l *Location) InitWithOptions(
+	id borges.RepositoryID,
+	opts *InitOptions,
+) (borges.Repository, error) {
+	if opts == nil {
+		opts = l.opts.InitOptions
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
 	has, err := l.Has(id)
 	if err != nil {
 		return nil, err
@@ -86,13 +277,30 @@ func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
 		return nil, borges.ErrRepositoryExists.New(id)
 	}
 
-	return initRepository(l, id)
+	if err := l.checkInitQuota(); err != nil {
+		return nil, err
+	}
+
+	return initRepository(l, id, opts)
 }
 
 // Has returns true if the given RepositoryID matches any repository at this
-// location.
+// location. If the given RepositoryID is not valid, any element of its path
+// is longer than MaxNameLength or the path is inside the git directory of
+// another repository, ErrInvalidRepositoryID is returned.
 func (l *Location) Has(id borges.RepositoryID) (bool, error) {
-	_, err := l.fs.Stat(l.RepositoryPath(id))
+	return l.has(trace{}, id)
+}
+
+func (l *Location) has(t trace, id borges.RepositoryID) (_ bool, err error) {
+	_, done := t.start(Operation{Name: "plain.Location.Has", ID: id, Location: l.id})
+	defer func() { done(err) }()
+
+	if err := l.validateID(id); err != nil {
+		return false, err
+	}
+
+	_, err = l.fs.Stat(l.RepositoryPath(id))
 	if err == nil {
 		return true, nil
 	}
@@ -104,11 +312,53 @@ func (l *Location) Has(id borges.RepositoryID) (bool, error) {
 	return false, err
 }
 
+// validateID returns ErrInvalidRepositoryID if the repository with the given
+// RepositoryID can't be stored in this Location. The IDs with git directory
+// entry names are rejected and, as in bare locations the git directory is
+// the repository directory, so are the paths nested in a bare repository.
+func (l *Location) validateID(id borges.RepositoryID) error {
+	if err := ValidateRepositoryID(id); err != nil {
+		return err
+	}
+
+	path := l.opts.IDMapper.Path(id)
+	if err := validatePath(id, path); err != nil {
+		return err
+	}
+
+	if err := validateNestedID(id); err != nil {
+		return err
+	}
+
+	if !l.opts.Bare {
+		return nil
+	}
+
+	parts := strings.Split(path, "/")
+	for i := 1; i < len(parts); i++ {
+		parent := strings.Join(parts[:i], "/")
+		ok, err := IsRepository(l.fs, parent, true)
+		if err != nil {
+			return err
+		}
+
+		if ok {
+			return borges.ErrInvalidRepositoryID.New(id, "inside repository "+parent)
+		}
+	}
+
+	return nil
+}
+
 // Get open a repository with the given RepositoryID, this operation doesn't
 // perform any read operation. If a repository with the given RepositoryID
 // already exists ErrRepositoryExists is returned.
 func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
-	has, err := l.Has(id)
+	return l.get(trace{}, id, mode)
+}
+
+func (l *Location) get(t trace, id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	has, err := l.has(t, id)
 	if err != nil {
 		return nil, err
 	}
@@ -117,16 +367,72 @@ func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Reposit
 		return nil, borges.ErrRepositoryNotExists.New(id)
 	}
 
+	return l.open(t, id, mode)
+}
+
+// open opens the repository notifying it to the Hook of the trace.
+func (l *Location) open(t trace, id borges.RepositoryID, mode borges.Mode) (_ *Repository, err error) {
+	_, done := t.start(Operation{Name: "plain.openRepository", ID: id, Location: l.id})
+	defer func() { done(err) }()
+
 	return openRepository(l, id, mode)
 }
 
-// RepositoryPath returns the location in the filesystem for a given RepositoryID.
+// Delete removes the repository with the given RepositoryID from this
+// Location. If the repository doesn't exist ErrRepositoryNotExists is
+// returned. Only the git directory is removed, the working tree of non-bare
+// repositories is kept unless it's empty. In bare locations repositories
+// can't be nested, but any directory nested in the deleted one, like
+// foo/bar in foo with IdentityMapper, is removed too.
+func (l *Location) Delete(id borges.RepositoryID) error {
+	has, err := l.Has(id)
+	if err != nil {
+		return err
+	}
+
+	if !has {
+		return borges.ErrRepositoryNotExists.New(id)
+	}
+
+	return l.remove(id)
+}
+
+// remove removes the git directory of the repository, and its working tree
+// if it's empty.
+func (l *Location) remove(id borges.RepositoryID) error {
+	defer l.usage.invalidate()
+	if err := butil.RemoveAll(l.fs, l.RepositoryPath(id)); err != nil {
+		return err
+	}
+
+	if l.opts.Bare {
+		return nil
+	}
+
+	path := l.opts.IDMapper.Path(id)
+	entries, err := l.fs.ReadDir(path)
+	if err != nil || len(entries) != 0 {
+		return err
+	}
+
+	return l.fs.Remove(path)
+}
+
+// RepositoryPath returns the location in the filesystem for a given
+// RepositoryID, based on the configured IDMapper. The RepositoryID should be
+// validated with ValidateRepositoryID before being used.
 func (l *Location) RepositoryPath(id borges.RepositoryID) string {
+	return l.gitDir(l.opts.IDMapper.Path(id))
+}
+
+// gitDir returns the path of the git directory for a repository at the given
+// path.
+func (l *Location) gitDir(path string) string {
 	if l.opts.Bare {
-		return id.String()
+		return path
 	}
 
-	return l.fs.Join(id.String(), ".git")
+	return l.fs.Join(path, ".git")
 }
 
 // Repositories returns a RepositoryIterator that iterates through all the
@@ -179,20 +485,29 @@ func (iter *LocationIterator) nextRepositoryPath() (string, error) {
 		if len(dir.entries) == 0 {
 			iter.queue = iter.queue[1:]
 		}
+		path := iter.l.fs.Join(dir.path, fi.Name())
 		if !fi.IsDir() {
+			iter.l.opts.Logger.Debug("scan: skipped, not a directory", iter.l.fields(borges.Fields{
+				"path": path,
+			}))
 			continue
 		}
 
-		path := iter.l.fs.Join(dir.path, fi.Name())
 		is, err := IsRepository(iter.l.fs, path, iter.l.opts.Bare)
 		if err != nil {
 			return path, err
 		}
 
 		if is {
+			iter.l.opts.Logger.Debug("scan: repository found", iter.l.fields(borges.Fields{
+				"path": path,
+			}))
 			return path, nil
 		}
 
+		iter.l.opts.Logger.Debug("scan: not a repository, descending", iter.l.fields(borges.Fields{
+			"path": path,
+		}))
 		if err = iter.addDir(path); err != nil {
 			return path, err
 		}
@@ -204,18 +519,83 @@ func (iter *LocationIterator) nextRepositoryPath() (string, error) {
 // Next returns the next repository from the iterator. If the iterator has
 // reached the end it will return io.EOF as an error.
 func (iter *LocationIterator) Next() (borges.Repository, error) {
-	path, err := iter.nextRepositoryPath()
+	for {
+		path, err := iter.nextRepositoryPath()
+		if err != nil {
+			return nil, err
+		}
+
+		id, err := iter.l.opts.IDMapper.ID(path)
+		if ErrUnexpectedPath.Is(err) {
+			iter.l.opts.Logger.Warn("scan: path doesn't follow the layout, skipped", err,
+				iter.l.fields(borges.Fields{"path": path}))
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		r, err := openRepositoryAt(iter.l, id, iter.l.gitDir(path), iter.m)
+		if err != nil {
+			return nil, err
+		}
+
+		if iter.l.opts.IDFromOrigin {
+			r.id = originID(r, id)
+		}
+
+		return r, nil
+	}
+}
+
+// originID returns the RepositoryID based on the origin remote URL, if the
+// repository doesn't have one or it isn't valid the fallback is returned.
+func originID(r *Repository, fallback borges.RepositoryID) borges.RepositoryID {
+	logger := r.l.opts.Logger
+	fields := r.l.fields(borges.Fields{"id": fallback})
+
+	cfg, err := r.Storer.Config()
 	if err != nil {
-		return nil, err
+		logger.Warn("scan: can't read config, using path id", err, fields)
+		return fallback
+	}
+
+	id, err := OriginID(cfg)
+	if ErrNoOrigin.Is(err) {
+		logger.Debug("scan: no origin, using path id", fields)
+		return fallback
 	}
 
-	id := borges.RepositoryID(path)
 	if err != nil {
-		return nil, err
+		fields["url"] = cfg.Remotes["origin"].URLs[0]
+		logger.Warn("scan: invalid origin url, using path id", err, fields)
+		return fallback
+	}
+
+	return id
+}
+
+// OriginID returns the RepositoryID built with borges.NewRepositoryID from
+// the first URL of the origin remote in the given config. If there is no
+// origin remote ErrNoOrigin is returned.
+func OriginID(cfg *config.Config) (borges.RepositoryID, error) {
+	origin, ok := cfg.Remotes["origin"]
+	if !ok || len(origin.URLs) == 0 {
+		return "", ErrNoOrigin.New()
 	}
 
-	return openRepository(iter.l, id, iter.m)
+	return borges.NewRepositoryID(origin.URLs[0])
+}
+
+// fields returns the given fields with the LocationID of this Location.
+func (l *Location) fields(fields borges.Fields) borges.Fields {
+	if fields == nil {
+		fields = borges.Fields{}
+	}
 
+	fields["location"] = l.id
+	return fields
 }
 
 // ForEach call the function for each object contained on this iter until an
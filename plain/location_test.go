@@ -1,7 +1,9 @@
 package plain
 
 import (
+	"fmt"
 	"io/ioutil"
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -9,10 +11,15 @@ import (
 	"github.com/src-d/go-borges/util"
 
 	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4"
 	"gopkg.in/src-d/go-billy.v4/memfs"
 	"gopkg.in/src-d/go-billy.v4/osfs"
 	"gopkg.in/src-d/go-git-fixtures.v3"
+	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 )
 
 func TestLocation(t *testing.T) {
@@ -161,7 +168,11 @@ func TestLocation_Get_Transactional(t *testing.T) {
 	require.NoError(err)
 	require.NotNil(r)
 
-	h := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	obj := r.R().Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	h, err := r.R().Storer.SetEncodedObject(obj)
+	require.NoError(err)
+
 	err = r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/foo", h))
 	require.NoError(err)
 
@@ -245,6 +256,54 @@ func TestLocationIterator_Next_Fixture(t *testing.T) {
 	})
 }
 
+func TestLocationIterator_Next_IDFromOrigin(t *testing.T) {
+	require := require.New(t)
+
+	fs := memfs.New()
+	location, err := NewLocation("foo", fs, &LocationOptions{
+		IDFromOrigin: true,
+	})
+	require.NoError(err)
+
+	r, err := location.Init("clones/bar")
+	require.NoError(err)
+
+	_, err = r.R().CreateRemote(&config.RemoteConfig{
+		Name: "upstream",
+		URLs: []string{"git@github.com:Upstream/Bar"},
+	})
+	require.NoError(err)
+	require.NoError(r.R().DeleteRemote("origin"))
+	_, err = r.R().CreateRemote(&config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"git@github.com:Foo/Bar"},
+	})
+	require.NoError(err)
+
+	_, err = location.Init("clones/baz")
+	require.NoError(err)
+
+	r, err = location.Init("clones/qux")
+	require.NoError(err)
+	require.NoError(r.R().DeleteRemote("origin"))
+
+	iter, err := location.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return nil
+	})
+
+	require.NoError(err)
+	require.ElementsMatch(ids, []borges.RepositoryID{
+		"github.com/Foo/Bar.git",
+		"clones/baz.git",
+		"clones/qux",
+	})
+}
+
 func TestLocationIterator_NextBare(t *testing.T) {
 	require := require.New(t)
 
@@ -322,3 +381,423 @@ func TestLocationIterator_Next_Deep(t *testing.T) {
 		"foo/qux", "foo/bar", "qux/bar",
 	})
 }
+
+func TestLocationOptions_Validate(t *testing.T) {
+	require := require.New(t)
+
+	opts := &LocationOptions{}
+	require.NoError(opts.Validate())
+	require.Equal(cache.DefaultMaxSize, opts.CacheSize)
+	require.Nil(opts.TemporalFilesystem)
+
+	opts = &LocationOptions{Transactional: true, CacheSize: cache.MiByte}
+	require.NoError(opts.Validate())
+	require.Equal(cache.MiByte, opts.CacheSize)
+	require.NotNil(opts.TemporalFilesystem)
+}
+
+func TestLocation_Get_SharedCache(t *testing.T) {
+	require := require.New(t)
+
+	c := cache.NewObjectLRUDefault()
+	location := newLocationWithFixtures(require, &LocationOptions{
+		Cache:           c,
+		KeepDescriptors: true,
+		ExclusiveAccess: true,
+	})
+
+	r, err := location.Get("basic.git", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	h := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	_, err = r.R().CommitObject(h)
+	require.NoError(err)
+
+	_, ok := c.Get(h)
+	require.True(ok)
+
+	require.NoError(r.Close())
+}
+
+func TestLocation_StorerFactory(t *testing.T) {
+	require := require.New(t)
+
+	var ids []borges.RepositoryID
+	var modes []borges.Mode
+	factory := func(
+		fs billy.Filesystem,
+		id borges.RepositoryID,
+		mode borges.Mode,
+	) (storage.Storer, error) {
+		ids = append(ids, id)
+		modes = append(modes, mode)
+		return filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil
+	}
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		StorerFactory: factory,
+	})
+	require.NoError(err)
+
+	_, err = location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	r, err := location.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	err = r.R().Storer.SetReference(plumbing.NewHashReference("foo", plumbing.ZeroHash))
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	require.Equal([]borges.RepositoryID{
+		"github.com/foo/bar", "github.com/foo/bar",
+	}, ids)
+	require.Equal([]borges.Mode{borges.RWMode, borges.ReadOnlyMode}, modes)
+}
+
+func TestLocation_StorerFactory_Transactional(t *testing.T) {
+	require := require.New(t)
+
+	var calls int
+	factory := func(
+		fs billy.Filesystem,
+		id borges.RepositoryID,
+		mode borges.Mode,
+	) (storage.Storer, error) {
+		calls++
+		return filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil
+	}
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional: true,
+		StorerFactory: factory,
+	})
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	has, err := location.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(has)
+	require.Equal(1, calls)
+}
+
+// closerStorer is a storage.Storer recording if it was closed.
+type closerStorer struct {
+	storage.Storer
+	closed bool
+}
+
+func (s *closerStorer) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestLocation_StorerFactory_Close(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		require := require.New(t)
+
+		var storers []*closerStorer
+		factory := func(
+			fs billy.Filesystem,
+			id borges.RepositoryID,
+			mode borges.Mode,
+		) (storage.Storer, error) {
+			s := &closerStorer{
+				Storer: filesystem.NewStorage(fs, cache.NewObjectLRUDefault()),
+			}
+			storers = append(storers, s)
+			return s, nil
+		}
+
+		location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+			Transactional: transactional,
+			StorerFactory: factory,
+		})
+		require.NoError(err)
+
+		r, err := location.Init("github.com/foo/bar")
+		require.NoError(err)
+		if transactional {
+			require.NoError(r.Commit())
+		} else {
+			require.NoError(r.Close())
+		}
+
+		for _, mode := range []borges.Mode{borges.ReadOnlyMode, borges.RWMode} {
+			r, err = location.Get("github.com/foo/bar", mode)
+			require.NoError(err)
+			require.NoError(r.Close())
+		}
+
+		require.Len(storers, 3)
+		for _, s := range storers {
+			require.True(s.closed)
+		}
+	}
+}
+
+func TestLocation_StorerFactory_Error(t *testing.T) {
+	require := require.New(t)
+
+	expected := fmt.Errorf("foo")
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		StorerFactory: func(
+			billy.Filesystem,
+			borges.RepositoryID,
+			borges.Mode,
+		) (storage.Storer, error) {
+			return nil, expected
+		},
+	})
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.Equal(expected, err)
+	require.Nil(r)
+}
+
+func TestLocation_Delete(t *testing.T) {
+	for _, bare := range []bool{true, false} {
+		require := require.New(t)
+
+		fs := memfs.New()
+		location, err := NewLocation("foo", fs, &LocationOptions{Bare: bare})
+		require.NoError(err)
+
+		// bare repositories can't be nested.
+		ids := []borges.RepositoryID{"foo", "foo/bar", "baz"}
+		if bare {
+			ids = []borges.RepositoryID{"foo", "baz"}
+		}
+
+		for _, id := range ids {
+			_, err = location.Init(id)
+			require.NoError(err)
+		}
+
+		require.NoError(location.Delete("foo"))
+		require.NoError(location.Delete("baz"))
+
+		for id, expected := range map[borges.RepositoryID]bool{
+			"foo":     false,
+			"foo/bar": !bare,
+			"baz":     false,
+		} {
+			has, err := location.Has(id)
+			require.NoError(err)
+			require.Equal(expected, has, id)
+		}
+
+		_, err = fs.Stat("baz")
+		require.True(os.IsNotExist(err))
+
+		err = location.Delete("foo")
+		require.True(borges.ErrRepositoryNotExists.Is(err))
+	}
+}
+
+// entry is a log entry recorded by testLogger.
+type entry struct {
+	level  string
+	msg    string
+	err    error
+	fields borges.Fields
+}
+
+// testLogger is a borges.Logger keeping the entries in memory.
+type testLogger struct {
+	entries []entry
+}
+
+func (l *testLogger) Debug(msg string, fields borges.Fields) {
+	l.entries = append(l.entries, entry{"debug", msg, nil, fields})
+}
+
+func (l *testLogger) Info(msg string, fields borges.Fields) {
+	l.entries = append(l.entries, entry{"info", msg, nil, fields})
+}
+
+func (l *testLogger) Warn(msg string, err error, fields borges.Fields) {
+	l.entries = append(l.entries, entry{"warn", msg, err, fields})
+}
+
+func (l *testLogger) Error(msg string, err error, fields borges.Fields) {
+	l.entries = append(l.entries, entry{"error", msg, err, fields})
+}
+
+func (l *testLogger) messages() []string {
+	var msgs []string
+	for _, e := range l.entries {
+		msgs = append(msgs, e.level+": "+e.msg)
+	}
+
+	return msgs
+}
+
+// failingRemoveFS is a filesystem where files can't be removed.
+type failingRemoveFS struct {
+	billy.Filesystem
+}
+
+func (fs *failingRemoveFS) Remove(string) error {
+	return fmt.Errorf("read-only filesystem")
+}
+
+func TestLocation_Logger(t *testing.T) {
+	require := require.New(t)
+
+	logger := &testLogger{}
+	fs := memfs.New()
+	loc, err := NewLocation("foo", fs, &LocationOptions{
+		Transactional:      true,
+		TemporalFilesystem: &failingRemoveFS{memfs.New()},
+		Logger:             logger,
+	})
+	require.NoError(err)
+
+	r, err := loc.Init("foo")
+	require.NoError(err)
+	require.Error(r.Commit())
+
+	require.Equal([]string{
+		"debug: repository initialized",
+		"warn: can't remove temporal directory",
+		"error: commit failed",
+	}, logger.messages())
+
+	warn := logger.entries[1]
+	require.EqualError(warn.err, "read-only filesystem")
+	require.Equal(borges.LocationID("foo"), warn.fields["location"])
+	require.Equal(borges.RepositoryID("foo"), warn.fields["id"])
+	require.Equal("rw", warn.fields["mode"])
+	require.NotEmpty(warn.fields["temporal_path"])
+
+	logger.entries = nil
+	f, err := fs.Create("README")
+	require.NoError(err)
+	require.NoError(f.Close())
+	require.NoError(fs.MkdirAll("bar/baz", 0755))
+
+	iter, err := loc.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(iter.ForEach(func(r borges.Repository) error {
+		return nil
+	}))
+
+	require.ElementsMatch([]string{
+		"debug: scan: skipped, not a directory",
+		"debug: scan: not a repository, descending",
+		"debug: scan: not a repository, descending",
+		"debug: scan: repository found",
+		"debug: repository opened",
+	}, logger.messages())
+}
+
+func TestLocation_Logger_CommitFailed(t *testing.T) {
+	require := require.New(t)
+
+	logger := &testLogger{}
+	loc, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional: true,
+		Logger:        logger,
+	})
+	require.NoError(err)
+
+	r, err := loc.Init("foo")
+	require.NoError(err)
+
+	h := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	require.NoError(r.R().Storer.SetReference(plumbing.NewHashReference("refs/heads/foo", h)))
+	require.True(ErrValidation.Is(r.Commit()))
+
+	last := logger.entries[len(logger.entries)-1]
+	require.Equal("error", last.level)
+	require.Equal("commit failed", last.msg)
+	require.True(ErrValidation.Is(last.err))
+}
+
+func TestLocation_Logger_OriginID(t *testing.T) {
+	require := require.New(t)
+
+	logger := &testLogger{}
+	loc, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		IDFromOrigin: true,
+		Logger:       logger,
+	})
+	require.NoError(err)
+
+	_, err = loc.InitWithOptions("foo", &InitOptions{URLs: []string{"http://[::1"}})
+	require.NoError(err)
+
+	iter, err := loc.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(iter.ForEach(func(r borges.Repository) error {
+		require.Equal(borges.RepositoryID("foo"), r.ID())
+		return nil
+	}))
+
+	last := logger.entries[len(logger.entries)-1]
+	require.Equal("warn: scan: invalid origin url, using path id", last.level+": "+last.msg)
+	require.Error(last.err)
+	require.Equal("http://[::1", last.fields["url"])
+}
+
+func TestLocation_NestedInGitDirectory(t *testing.T) {
+	for _, bare := range []bool{true, false} {
+		for _, mapper := range []IDMapper{IdentityMapper{}, EscapedMapper{}} {
+			require := require.New(t)
+
+			location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+				Bare:     bare,
+				IDMapper: mapper,
+			})
+			require.NoError(err)
+
+			r, err := location.Init("foo")
+			require.NoError(err)
+			require.NoError(r.Close())
+
+			ids := []borges.RepositoryID{
+				"foo/.git/refs",
+				"foo/.git",
+				"foo/objects",
+				"foo/refs",
+				"foo/HEAD",
+				"foo/config",
+				"bar/objects/baz",
+			}
+
+			if bare {
+				ids = append(ids, "foo/bar", "foo/bar/baz")
+			}
+
+			for _, id := range ids {
+				_, err := location.Has(id)
+				require.True(borges.ErrInvalidRepositoryID.Is(err), id)
+
+				_, err = location.Init(id)
+				require.True(borges.ErrInvalidRepositoryID.Is(err), id)
+
+				_, err = location.Get(id, borges.ReadOnlyMode)
+				require.True(borges.ErrInvalidRepositoryID.Is(err), id)
+			}
+
+			ok, err := location.Has("foo")
+			require.NoError(err)
+			require.True(ok)
+
+			// the git directory of foo isn't modified.
+			r, err = location.Get("foo", borges.ReadOnlyMode)
+			require.NoError(err)
+			_, err = r.R().Head()
+			require.Equal(plumbing.ErrReferenceNotFound, err)
+			require.NoError(r.Close())
+
+			r, err = location.Init("objects")
+			require.NoError(err)
+			require.NoError(r.Close())
+		}
+	}
+}
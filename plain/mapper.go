@@ -0,0 +1,134 @@
+package plain
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// ErrUnexpectedPath is returned by an IDMapper when a path doesn't follow its
+// layout.
+var ErrUnexpectedPath = errors.NewKind("path %s doesn't follow the location layout")
+
+// IDMapper defines the layout of the repositories in a Location, mapping the
+// RepositoryIDs to paths and back. The path returned for an ID is the
+// directory of the repository, the .git directory is added for non-bare
+// locations.
+type IDMapper interface {
+	// Path returns the path relative to the Location root where the
+	// repository with the given RepositoryID is stored.
+	Path(borges.RepositoryID) string
+	// ID returns the RepositoryID of the repository stored at the given
+	// path. If the path doesn't follow the layout ErrUnexpectedPath is
+	// returned.
+	ID(path string) (borges.RepositoryID, error)
+}
+
+// IdentityMapper stores the repositories at a path equal to its
+// RepositoryID, this is the layout of the locations created before the
+// IDMappers were introduced. Only IDs that are clean relative paths can be
+// recovered from the filesystem, EscapedMapper should be used for IDs like
+// URLs or case-sensitive IDs in case-insensitive filesystems.
+// Eg.: github.com/src-d/go-borges is stored at github.com/src-d/go-borges.
+type IdentityMapper struct{}
+
+// Path honors the IDMapper interface.
+func (IdentityMapper) Path(id borges.RepositoryID) string {
+	return id.String()
+}
+
+// ID honors the IDMapper interface.
+func (IdentityMapper) ID(path string) (borges.RepositoryID, error) {
+	id := borges.RepositoryID(path)
+	if err := ValidateRepositoryID(id); err != nil {
+		return "", ErrUnexpectedPath.New(path)
+	}
+
+	return id, nil
+}
+
+// EscapedMapper stores the repositories at a path equal to its RepositoryID
+// escaped with EscapeRepositoryID, any valid RepositoryID can be recovered
+// from its path.
+// Eg.: github.com/Src-d/go-borges is stored at github.com/%53rc-d/go-borges.
+type EscapedMapper struct{}
+
+// Path honors the IDMapper interface.
+func (EscapedMapper) Path(id borges.RepositoryID) string {
+	return EscapeRepositoryID(id)
+}
+
+// ID honors the IDMapper interface. Paths not produced by Path, like
+// github.com/Foo or foo%61, return ErrUnexpectedPath.
+func (m EscapedMapper) ID(path string) (borges.RepositoryID, error) {
+	id := UnescapeRepositoryID(path)
+	if m.Path(id) != path {
+		return "", ErrUnexpectedPath.New(path)
+	}
+
+	return id, nil
+}
+
+// FlatMapper stores all the repositories at the root of the Location, the
+// RepositoryID is escaped including the path separators. The escaped ID is a
+// single path element, IDs producing elements longer than MaxNameLength are
+// rejected by the Location with ErrInvalidRepositoryID.
+// Eg.: github.com/src-d/go-borges is stored at github.com%2Fsrc-d%2Fgo-borges.
+type FlatMapper struct{}
+
+// Path honors the IDMapper interface.
+func (FlatMapper) Path(id borges.RepositoryID) string {
+	return strings.Replace(EscapeRepositoryID(id), "/", "%2F", -1)
+}
+
+// ID honors the IDMapper interface.
+func (m FlatMapper) ID(path string) (borges.RepositoryID, error) {
+	id := UnescapeRepositoryID(path)
+	if m.Path(id) != path {
+		return "", ErrUnexpectedPath.New(path)
+	}
+
+	return id, nil
+}
+
+// ShardedMapper spreads the repositories in two levels of directories based
+// on the SHA-1 of the RepositoryID, the repository directory is named as
+// FlatMapper does, so the RepositoryID can be recovered from the path. It's
+// subject to the same MaxNameLength limit as FlatMapper.
+// Eg.: github.com/src-d/go-borges is stored at
+// 9c/3a/github.com%2Fsrc-d%2Fgo-borges.
+type ShardedMapper struct{}
+
+// Path honors the IDMapper interface.
+func (ShardedMapper) Path(id borges.RepositoryID) string {
+	shard := shardOf(id)
+	return strings.Join([]string{shard[:2], shard[2:], FlatMapper{}.Path(id)}, "/")
+}
+
+// ID honors the IDMapper interface.
+func (ShardedMapper) ID(path string) (borges.RepositoryID, error) {
+	parts := strings.Split(path, "/")
+	if len(parts) != 3 {
+		return "", ErrUnexpectedPath.New(path)
+	}
+
+	id, err := FlatMapper{}.ID(parts[2])
+	if err != nil {
+		return "", err
+	}
+
+	if shardOf(id) != parts[0]+parts[1] {
+		return "", ErrUnexpectedPath.New(path)
+	}
+
+	return id, nil
+}
+
+func shardOf(id borges.RepositoryID) string {
+	sum := sha1.Sum([]byte(id))
+	return hex.EncodeToString(sum[:2])
+}
@@ -0,0 +1,206 @@
+package plain
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+var mapperIDs = []borges.RepositoryID{
+	"github.com/foo/bar.git",
+	"github.com/Foo/Bar",
+	"foo",
+	"foo/.bar",
+	"foo%2Fbar",
+	"http://github.com/foo/bar",
+}
+
+func TestIdentityMapper(t *testing.T) {
+	require := require.New(t)
+
+	m := IdentityMapper{}
+	for _, id := range []borges.RepositoryID{
+		"github.com/foo/bar.git",
+		"github.com/Foo/Bar",
+		"foo/.bar",
+		"foo%2Fbar",
+	} {
+		require.Equal(id.String(), m.Path(id))
+
+		found, err := m.ID(m.Path(id))
+		require.NoError(err, id)
+		require.Equal(id, found)
+	}
+
+	_, err := m.ID("foo/../bar")
+	require.True(ErrUnexpectedPath.Is(err))
+}
+
+func TestEscapedMapper(t *testing.T) {
+	require := require.New(t)
+
+	m := EscapedMapper{}
+	require.Equal("github.com/%46oo/%42ar", m.Path("github.com/Foo/Bar"))
+	testMapperRoundTrip(require, m)
+
+	for _, path := range []string{
+		"github.com/Foo/Bar",
+		"foo%61",
+		"http:",
+	} {
+		_, err := m.ID(path)
+		require.True(ErrUnexpectedPath.Is(err), path)
+	}
+}
+
+func TestFlatMapper(t *testing.T) {
+	require := require.New(t)
+
+	m := FlatMapper{}
+	require.Equal("github.com%2Ffoo%2Fbar.git", m.Path("github.com/foo/bar.git"))
+	testMapperRoundTrip(require, m)
+
+	for _, path := range []string{"github.com/foo", "Foo", "foo%61"} {
+		_, err := m.ID(path)
+		require.True(ErrUnexpectedPath.Is(err), path)
+	}
+}
+
+func TestShardedMapper(t *testing.T) {
+	require := require.New(t)
+
+	m := ShardedMapper{}
+	path := m.Path("github.com/foo/bar.git")
+	require.Regexp(`^[0-9a-f]{2}/[0-9a-f]{2}/github\.com%2Ffoo%2Fbar\.git$`, path)
+	testMapperRoundTrip(require, m)
+
+	for _, path := range []string{
+		"github.com%2Ffoo%2Fbar.git",
+		"00/00/github.com%2Ffoo%2Fbar.git",
+		"00/00/00/github.com%2Ffoo%2Fbar.git",
+	} {
+		_, err := m.ID(path)
+		require.True(ErrUnexpectedPath.Is(err), path)
+	}
+}
+
+func testMapperRoundTrip(require *require.Assertions, m IDMapper) {
+	for _, id := range mapperIDs {
+		found, err := m.ID(m.Path(id))
+		require.NoError(err, id)
+		require.Equal(id, found)
+	}
+}
+
+func TestLocation_IDMapper(t *testing.T) {
+	// the identity layout can't recover the IDs that aren't clean paths,
+	// like http://github.com/foo/bar, so they aren't included.
+	escaped := []borges.RepositoryID{
+		"github.com/foo/bar.git",
+		"github.com/Foo/Bar",
+		"foo",
+		"foo%2Fbar",
+		"http://github.com/foo/bar",
+	}
+
+	for _, c := range []struct {
+		mapper IDMapper
+		ids    []borges.RepositoryID
+	}{
+		{IdentityMapper{}, escaped[:4]},
+		{EscapedMapper{}, escaped},
+		{FlatMapper{}, escaped},
+		{ShardedMapper{}, escaped},
+	} {
+		require := require.New(t)
+
+		m := c.mapper
+		location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+			IDMapper: m,
+		})
+		require.NoError(err)
+
+		for _, id := range c.ids {
+			r, err := location.Init(id)
+			require.NoError(err, id)
+			require.NoError(r.Close())
+			require.Equal(m.Path(id)+"/.git", location.RepositoryPath(id))
+		}
+
+		iter, err := location.Repositories(borges.ReadOnlyMode)
+		require.NoError(err)
+
+		var ids []borges.RepositoryID
+		err = iter.ForEach(func(r borges.Repository) error {
+			ids = append(ids, r.ID())
+			return nil
+		})
+
+		require.NoError(err)
+		require.ElementsMatch(c.ids, ids)
+	}
+}
+
+func TestLocationIterator_Next_UnexpectedPath(t *testing.T) {
+	require := require.New(t)
+
+	fs := memfs.New()
+	createValidDotGit(require, fs, "foo/bar/.git")
+	createValidDotGit(require, fs, "baz/.git")
+
+	logger := &testLogger{}
+	location, err := NewLocation("foo", fs, &LocationOptions{
+		IDMapper: FlatMapper{},
+		Logger:   logger,
+	})
+	require.NoError(err)
+
+	iter, err := location.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	r, err := iter.Next()
+	require.NoError(err)
+	require.Equal(borges.RepositoryID("baz"), r.ID())
+
+	_, err = iter.Next()
+	require.Equal(io.EOF, err)
+
+	var skipped []interface{}
+	for _, e := range logger.entries {
+		if e.msg == "scan: path doesn't follow the layout, skipped" {
+			require.True(ErrUnexpectedPath.Is(e.err))
+			skipped = append(skipped, e.fields["path"])
+		}
+	}
+
+	require.Equal([]interface{}{"foo/bar"}, skipped)
+}
+
+func TestLocation_IDMapper_NameTooLong(t *testing.T) {
+	require := require.New(t)
+
+	long := borges.RepositoryID(strings.Repeat("foo/", 60) + "bar")
+	for m, valid := range map[IDMapper]bool{
+		IdentityMapper{}: true,
+		EscapedMapper{}:  true,
+		FlatMapper{}:     false,
+		ShardedMapper{}:  false,
+	} {
+		location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+			IDMapper: m,
+		})
+		require.NoError(err)
+
+		_, err = location.Init(long)
+		if valid {
+			require.NoError(err)
+		} else {
+			require.True(borges.ErrInvalidRepositoryID.Is(err))
+		}
+	}
+}
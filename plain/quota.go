@@ -0,0 +1,345 @@
+package plain
+
+import (
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// ErrQuotaExceeded is returned when an operation makes a Location or a
+// Repository use more bytes than allowed by LocationOptions.MaxBytes or
+// LocationOptions.MaxRepositoryBytes.
+var ErrQuotaExceeded = errors.NewKind("quota of %s %s exceeded: %d bytes used, limit is %d")
+
+// Usage is the disk usage of a Location and its repositories.
+type Usage struct {
+	// Location is the LocationID of the Location.
+	Location borges.LocationID
+	// Bytes is the size of the files of the Location.
+	Bytes int64
+	// MaxBytes is the quota of the Location, zero if unlimited.
+	MaxBytes int64
+	// Repositories is the usage of every repository, in descending order of
+	// Bytes.
+	Repositories []*RepositoryUsage
+}
+
+// RepositoryUsage is the disk usage of a Repository.
+type RepositoryUsage struct {
+	// ID is the RepositoryID of the repository.
+	ID borges.RepositoryID
+	// Bytes is the size of the git directory.
+	Bytes int64
+	// Pending is the size of the changes of a transactional Repository
+	// pending to be committed.
+	Pending int64
+	// MaxBytes is the quota of the repository, zero if unlimited.
+	MaxBytes int64
+}
+
+// Usage returns the disk usage of this Location and all its repositories.
+func (l *Location) Usage() (*Usage, error) {
+	bytes, err := dirSize(l.fs, "")
+	if err != nil {
+		return nil, err
+	}
+
+	u := &Usage{Location: l.id, Bytes: bytes, MaxBytes: l.opts.MaxBytes}
+
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	if err != nil {
+		return nil, err
+	}
+
+	err = iter.ForEach(func(r borges.Repository) error {
+		ru, err := r.(*Repository).Usage()
+		if err != nil {
+			return err
+		}
+
+		u.Repositories = append(u.Repositories, ru)
+		return r.Close()
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(u.Repositories, func(i, j int) bool {
+		return u.Repositories[i].Bytes > u.Repositories[j].Bytes
+	})
+
+	return u, nil
+}
+
+// Usage returns the disk usage of this Repository.
+func (r *Repository) Usage() (*RepositoryUsage, error) {
+	bytes, err := dirSize(r.l.fs, r.path)
+	if err != nil {
+		return nil, err
+	}
+
+	u := &RepositoryUsage{ID: r.id, Bytes: bytes, MaxBytes: r.l.opts.MaxRepositoryBytes}
+	if r.temporalPath == "" {
+		return u, nil
+	}
+
+	if u.Pending, err = dirSize(r.l.opts.TemporalFilesystem, r.temporalPath); err != nil {
+		return nil, err
+	}
+
+	return u, nil
+}
+
+// hasQuota returns true if any quota is configured.
+func (o *LocationOptions) hasQuota() bool {
+	return o.MaxBytes > 0 || o.MaxRepositoryBytes > 0
+}
+
+// checkInitQuota returns ErrQuotaExceeded if the Location already reached
+// its quota.
+func (l *Location) checkInitQuota() error {
+	if l.opts.MaxBytes <= 0 {
+		return nil
+	}
+
+	bytes, err := l.usage.get(l.fs)
+	if err != nil {
+		return err
+	}
+
+	if bytes >= l.opts.MaxBytes {
+		return ErrQuotaExceeded.New("location", l.id, bytes, l.opts.MaxBytes)
+	}
+
+	return nil
+}
+
+// usageCache keeps the size of the files of a Location, it's computed on
+// first use and updated by the commits so the whole Location isn't walked
+// on every open and commit. The operations writing directly to the
+// filesystem invalidate it. The bytes written by the open transactions are
+// reserved so the concurrent writes are checked against each other.
+type usageCache struct {
+	sync.Mutex
+	valid   bool
+	bytes   int64
+	pending int64
+	// commit serializes the quota checks of the commits with the update
+	// of the size once they are done.
+	commit sync.Mutex
+}
+
+// get returns the size of the files, without the reserved bytes, computing
+// it if needed.
+func (c *usageCache) get(fs billy.Filesystem) (int64, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.compute(fs); err != nil {
+		return 0, err
+	}
+
+	return c.bytes, nil
+}
+
+// reserve adds the bytes written by a transaction to the pending ones and
+// returns the resulting size, including the reserved bytes.
+func (c *usageCache) reserve(fs billy.Filesystem, bytes int64) (int64, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if err := c.compute(fs); err != nil {
+		return 0, err
+	}
+
+	c.pending += bytes
+	return c.bytes + c.pending, nil
+}
+
+func (c *usageCache) compute(fs billy.Filesystem) error {
+	if c.valid {
+		return nil
+	}
+
+	bytes, err := dirSize(fs, "")
+	if err != nil {
+		return err
+	}
+
+	c.bytes, c.valid = bytes, true
+	return nil
+}
+
+// release removes bytes no longer pending from the reserved ones.
+func (c *usageCache) release(bytes int64) {
+	c.Lock()
+	defer c.Unlock()
+	c.pending -= bytes
+}
+
+// add updates the cached size, if any, with the bytes added or removed.
+func (c *usageCache) add(bytes int64) {
+	c.Lock()
+	defer c.Unlock()
+	c.bytes += bytes
+}
+
+// invalidate forces the size to be computed again on the next get.
+func (c *usageCache) invalidate() {
+	c.Lock()
+	defer c.Unlock()
+	c.valid = false
+}
+
+// quota tracks the bytes written into the temporal storer of a Repository,
+// they are reserved in the usage of the Location until the Repository is
+// committed or closed. The usage of the Repository is read when it's opened
+// to not walk it on every write.
+type quota struct {
+	l          *Location
+	id         borges.RepositoryID
+	fs         billy.Filesystem
+	repository int64
+
+	mu      sync.Mutex
+	written int64
+	// committed is the size of the Repository checked by commit.
+	committed int64
+}
+
+func newQuota(l *Location, id borges.RepositoryID, path string, temporal billy.Filesystem) (*quota, error) {
+	q := &quota{l: l, id: id, fs: temporal}
+
+	var err error
+	if l.opts.MaxRepositoryBytes > 0 {
+		if q.repository, err = dirSize(l.fs, path); err != nil {
+			return nil, err
+		}
+	}
+
+	return q, nil
+}
+
+// add accounts the loose object written into the temporal storer.
+func (q *quota) add(obj plumbing.EncodedObject, h plumbing.Hash) error {
+	size := obj.Size()
+	hex := h.String()
+	if fi, err := q.fs.Stat(q.fs.Join("objects", hex[:2], hex[2:])); err == nil {
+		size = fi.Size()
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.written += size
+	location, err := q.l.usage.reserve(q.l.fs, size)
+	if err != nil {
+		return err
+	}
+
+	return q.check(location, q.repository+q.written)
+}
+
+// release removes the reservation of the bytes written, it's called once
+// they are committed or discarded.
+func (q *quota) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.l.usage.release(q.written)
+	q.written = 0
+}
+
+// commit checks the quotas with the actual size of the pending changes,
+// the Location and the Repository. On success the commits of the Location
+// are locked until update is called, so they can't exceed the quotas
+// together.
+func (q *quota) commit(path string) (err error) {
+	q.l.usage.commit.Lock()
+	defer func() {
+		if err != nil {
+			q.l.usage.commit.Unlock()
+		}
+	}()
+
+	pending, err := dirSize(q.fs, "")
+	if err != nil {
+		return err
+	}
+
+	var location int64
+	if q.l.opts.MaxBytes > 0 {
+		if location, err = q.l.usage.reserve(q.l.fs, 0); err != nil {
+			return err
+		}
+
+		// the estimation reserved by the writes is replaced by the
+		// actual size.
+		q.mu.Lock()
+		location += pending - q.written
+		q.mu.Unlock()
+	}
+
+	if q.committed, err = dirSize(q.l.fs, path); err != nil {
+		return err
+	}
+
+	return q.check(location, q.committed+pending)
+}
+
+// update adds the bytes written by the commit to the usage of the Location,
+// if the commit failed the files written are unknown and it's invalidated.
+// It unlocks the commits locked by commit.
+func (q *quota) update(path string, err *error) {
+	defer q.l.usage.commit.Unlock()
+	q.release()
+
+	if *err != nil {
+		q.l.usage.invalidate()
+		return
+	}
+
+	bytes, serr := dirSize(q.l.fs, path)
+	if serr != nil {
+		q.l.usage.invalidate()
+		return
+	}
+
+	q.l.usage.add(bytes - q.committed)
+}
+
+func (q *quota) check(location, repository int64) error {
+	max := q.l.opts.MaxRepositoryBytes
+	if max > 0 && repository > max {
+		return ErrQuotaExceeded.New("repository", q.id, repository, max)
+	}
+
+	max = q.l.opts.MaxBytes
+	if max > 0 && location > max {
+		return ErrQuotaExceeded.New("location", q.l.id, location, max)
+	}
+
+	return nil
+}
+
+// dirSize returns the size of the files under the given path, zero if the
+// path doesn't exist.
+func dirSize(fs billy.Filesystem, path string) (int64, error) {
+	var size int64
+	err := walk(fs, path, func(_ string, fi os.FileInfo) error {
+		size += fi.Size()
+		return nil
+	})
+
+	if os.IsNotExist(err) {
+		return size, nil
+	}
+
+	return size, err
+}
@@ -0,0 +1,268 @@
+package plain
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage"
+)
+
+// writeRandomBlob stores a blob with the given number of random bytes, that
+// can't be compressed.
+func writeRandomBlob(require *require.Assertions, s storage.Storer, size int) (plumbing.Hash, error) {
+	content := make([]byte, size)
+	_, err := rand.New(rand.NewSource(int64(size))).Read(content)
+	require.NoError(err)
+
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	require.NoError(err)
+	_, err = w.Write(content)
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	return s.SetEncodedObject(obj)
+}
+
+func TestLocation_Quota_Init(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		MaxBytes: 100,
+	})
+	require.NoError(err)
+
+	r, err := location.Init("foo")
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	_, err = location.Init("bar")
+	require.True(ErrQuotaExceeded.Is(err))
+
+	has, err := location.Has("bar")
+	require.NoError(err)
+	require.False(has)
+}
+
+func TestRepository_Quota_Write(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional:      true,
+		MaxRepositoryBytes: 1024,
+	})
+	require.NoError(err)
+
+	r, err := location.Init("foo")
+	require.NoError(err)
+
+	_, err = writeRandomBlob(require, r.R().Storer, 512)
+	require.NoError(err)
+
+	_, err = writeRandomBlob(require, r.R().Storer, 768)
+	require.True(ErrQuotaExceeded.Is(err))
+	require.NoError(r.Close())
+}
+
+func TestRepository_Quota_Commit(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional: true,
+		MaxBytes:      3000,
+	})
+	require.NoError(err)
+
+	var repos []borges.Repository
+	for _, id := range []borges.RepositoryID{"foo", "bar"} {
+		r, err := location.Init(id)
+		require.NoError(err)
+		repos = append(repos, r)
+	}
+
+	// the pending writes of both transactions are counted together.
+	h, err := writeRandomBlob(require, repos[0].R().Storer, 1500)
+	require.NoError(err)
+	ref := plumbing.NewHashReference("refs/heads/master", h)
+	require.NoError(repos[0].R().Storer.SetReference(ref))
+
+	_, err = writeRandomBlob(require, repos[1].R().Storer, 1500)
+	require.True(ErrQuotaExceeded.Is(err))
+	require.NoError(repos[1].Close())
+	require.NoError(repos[0].Commit())
+
+	// the commit is checked with the committed ones.
+	r, err := location.Init("bar")
+	require.NoError(err)
+	h, err = writeRandomBlob(require, r.R().Storer, 1500)
+	require.True(ErrQuotaExceeded.Is(err))
+	ref = plumbing.NewHashReference("refs/heads/master", h)
+	require.NoError(r.R().Storer.SetReference(ref))
+	require.True(ErrQuotaExceeded.Is(r.Commit()))
+
+	has, err := location.Has("bar")
+	require.NoError(err)
+	require.False(has)
+	require.Zero(location.usage.pending)
+}
+
+func TestRepository_Quota_ConcurrentCommit(t *testing.T) {
+	require := require.New(t)
+
+	dir, err := ioutil.TempDir("", "location")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	location, err := NewLocation("foo", osfs.New(dir), &LocationOptions{
+		Transactional:      true,
+		TemporalFilesystem: osfs.New(filepath.Join(dir, ".tmp")),
+		MaxBytes:           5000,
+	})
+	require.NoError(err)
+
+	const repositories = 8
+	var repos []borges.Repository
+	for i := 0; i < repositories; i++ {
+		r, err := location.Init(borges.RepositoryID(fmt.Sprintf("foo%d", i)))
+		require.NoError(err)
+
+		// the quota is exceeded by the pending writes of the other
+		// transactions, the commits are checked again once they are
+		// discarded.
+		h, err := writeRandomBlob(require, r.R().Storer, 1500)
+		require.True(err == nil || ErrQuotaExceeded.Is(err))
+		ref := plumbing.NewHashReference("refs/heads/master", h)
+		require.NoError(r.R().Storer.SetReference(ref))
+
+		repos = append(repos, r)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, repositories)
+	for i, r := range repos {
+		wg.Add(1)
+		go func(i int, r borges.Repository) {
+			defer wg.Done()
+			errs[i] = r.Commit()
+		}(i, r)
+	}
+	wg.Wait()
+
+	var committed int
+	for _, err := range errs {
+		if err == nil {
+			committed++
+			continue
+		}
+
+		require.True(ErrQuotaExceeded.Is(err), err)
+	}
+
+	bytes, err := dirSize(location.fs, "")
+	require.NoError(err)
+	require.True(bytes <= 5000, bytes)
+	require.True(committed > 0 && committed < repositories, committed)
+	require.Zero(location.usage.pending)
+}
+
+func TestLocation_Quota_UsageCache(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional: true,
+		MaxBytes:      3000,
+	})
+	require.NoError(err)
+
+	requireCached := func() {
+		bytes, err := dirSize(location.fs, "")
+		require.NoError(err)
+		require.True(location.usage.valid)
+		require.Equal(bytes, location.usage.bytes)
+	}
+
+	for _, id := range []borges.RepositoryID{"foo", "bar"} {
+		r, err := location.Init(id)
+		require.NoError(err)
+
+		h, err := writeRandomBlob(require, r.R().Storer, 1000)
+		require.NoError(err)
+		ref := plumbing.NewHashReference("refs/heads/master", h)
+		require.NoError(r.R().Storer.SetReference(ref))
+		require.NoError(r.Commit())
+		requireCached()
+	}
+
+	// the commit exceeding the quota doesn't change the usage.
+	r, err := location.Init("baz")
+	require.NoError(err)
+	_, err = writeRandomBlob(require, r.R().Storer, 1000)
+	require.True(ErrQuotaExceeded.Is(err))
+	require.True(ErrQuotaExceeded.Is(r.Commit()))
+	requireCached()
+
+	require.NoError(location.Delete("foo"))
+	require.False(location.usage.valid)
+
+	r, err = location.Init("baz")
+	require.NoError(err)
+	requireCached()
+	require.NoError(r.Commit())
+	requireCached()
+}
+
+func TestLocation_Usage(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional:      true,
+		MaxBytes:           1 << 20,
+		MaxRepositoryBytes: 1 << 10,
+	})
+	require.NoError(err)
+
+	for id, size := range map[borges.RepositoryID]int{"foo": 100, "bar": 500} {
+		r, err := location.Init(id)
+		require.NoError(err)
+		h, err := writeRandomBlob(require, r.R().Storer, size)
+		require.NoError(err)
+		ref := plumbing.NewHashReference("refs/heads/master", h)
+		require.NoError(r.R().Storer.SetReference(ref))
+		require.NoError(r.Commit())
+	}
+
+	u, err := location.Usage()
+	require.NoError(err)
+
+	require.Equal(borges.LocationID("foo"), u.Location)
+	require.Equal(int64(1<<20), u.MaxBytes)
+	require.Len(u.Repositories, 2)
+	require.Equal(borges.RepositoryID("bar"), u.Repositories[0].ID)
+	require.Equal(borges.RepositoryID("foo"), u.Repositories[1].ID)
+	require.Equal(u.Bytes, u.Repositories[0].Bytes+u.Repositories[1].Bytes)
+	require.Equal(int64(1<<10), u.Repositories[0].MaxBytes)
+	require.Zero(u.Repositories[0].Pending)
+
+	r, err := location.Get("foo", borges.RWMode)
+	require.NoError(err)
+	_, err = writeRandomBlob(require, r.R().Storer, 200)
+	require.NoError(err)
+
+	ru, err := r.(*Repository).Usage()
+	require.NoError(err)
+	require.Equal(u.Repositories[1].Bytes, ru.Bytes)
+	require.True(ru.Pending > 200)
+	require.NoError(r.Close())
+}
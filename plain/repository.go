@@ -1,15 +1,16 @@
 package plain
 
 import (
+	"io"
+	"strings"
+
 	"github.com/src-d/go-borges"
 	"github.com/src-d/go-borges/util"
 
 	billy "gopkg.in/src-d/go-billy.v4/util"
 	"gopkg.in/src-d/go-git.v4"
-	"gopkg.in/src-d/go-git.v4/config"
-	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/storage"
-	"gopkg.in/src-d/go-git.v4/storage/filesystem"
 	"gopkg.in/src-d/go-git.v4/storage/transactional"
 	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
@@ -18,103 +19,216 @@ import (
 type Repository struct {
 	id           borges.RepositoryID
 	l            *Location
+	path         string
 	mode         borges.Mode
 	temporalPath string
+	closers      []io.Closer
+	// init contains the InitOptions pending to be applied on Commit.
+	init *InitOptions
 
 	*git.Repository
 }
 
-func initRepository(l *Location, id borges.RepositoryID) (*Repository, error) {
-	s, tempPath, err := repositoryStorer(l, id, borges.RWMode)
+func initRepository(l *Location, id borges.RepositoryID, opts *InitOptions) (*Repository, error) {
+	path := l.RepositoryPath(id)
+	s, tempPath, closers, err := repositoryStorer(l, id, path, borges.RWMode)
 	if err != nil {
 		return nil, err
 	}
 
 	r, err := git.Init(s, nil)
-	if err != nil {
-		return nil, err
+	if err == nil {
+		err = opts.apply(r, id)
 	}
 
-	_, err = r.CreateRemote(&config.RemoteConfig{
-		Name: "origin",
-		URLs: []string{id.String()},
-	})
-
 	if err != nil {
+		releaseStorer(l, tempPath, closers)
+		discardInit(l, id)
 		return nil, err
 	}
 
-	return &Repository{
+	repo := &Repository{
 		id:           id,
 		l:            l,
+		path:         path,
 		mode:         borges.RWMode,
 		temporalPath: tempPath,
+		closers:      closers,
 		Repository:   r,
-	}, nil
+	}
+
+	l.opts.Logger.Debug("repository initialized", repo.fields(borges.Fields{"path": path}))
+	if l.opts.Transactional {
+		repo.init = opts
+		return repo, nil
+	}
+
+	if err := opts.writeTemplates(l.fs, path); err != nil {
+		releaseStorer(l, "", closers)
+		discardInit(l, id)
+		return nil, err
+	}
+
+	return repo, nil
+}
+
+// discardInit removes the files written by a failed initialization, so it
+// can be retried. Transactional locations write nothing until the commit.
+func discardInit(l *Location, id borges.RepositoryID) {
+	if l.opts.Transactional {
+		return
+	}
+
+	if err := l.remove(id); err != nil {
+		l.opts.Logger.Warn("can't remove failed repository", err, l.fields(borges.Fields{
+			"id": id,
+		}))
+	}
 }
 
 // openRepository, is the basic operation of open a repository without any checking.
 func openRepository(l *Location, id borges.RepositoryID, mode borges.Mode) (*Repository, error) {
-	s, tempPath, err := repositoryStorer(l, id, mode)
+	return openRepositoryAt(l, id, l.RepositoryPath(id), mode)
+}
+
+// openRepositoryAt opens the repository stored at the given path, used when
+// the path is already known, like in the LocationIterator.
+func openRepositoryAt(
+	l *Location,
+	id borges.RepositoryID,
+	path string,
+	mode borges.Mode,
+) (*Repository, error) {
+	s, tempPath, closers, err := repositoryStorer(l, id, path, mode)
 	if err != nil {
 		return nil, err
 	}
 
 	r, err := git.Open(s, nil)
 	if err != nil {
+		releaseStorer(l, tempPath, closers)
 		return nil, err
 	}
 
-	return &Repository{
+	repo := &Repository{
 		id:           id,
 		l:            l,
+		path:         path,
 		mode:         mode,
 		temporalPath: tempPath,
+		closers:      closers,
 		Repository:   r,
-	}, nil
+	}
+
+	l.opts.Logger.Debug("repository opened", repo.fields(borges.Fields{"path": path}))
+	return repo, nil
 }
 
-func repositoryStorer(l *Location, id borges.RepositoryID, mode borges.Mode) (
-	s storage.Storer, tempPath string, err error) {
+func repositoryStorer(l *Location, id borges.RepositoryID, path string, mode borges.Mode) (
+	s storage.Storer, tempPath string, closers []io.Closer, err error) {
 
-	fs, err := l.fs.Chroot(l.RepositoryPath(id))
+	fs, err := l.fs.Chroot(path)
 	if err != nil {
-		return nil, "", err
+		return nil, "", nil, err
 	}
 
-	s = filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
-
 	switch mode {
-	case borges.ReadOnlyMode:
-		return &util.ReadOnlyStorer{s}, "", nil
-	case borges.RWMode:
-		if l.opts.Transactional {
-			return repositoryTemporalStorer(l, id, s)
-		}
-
-		return s, "", nil
+	case borges.ReadOnlyMode, borges.RWMode:
 	default:
-		return nil, "", borges.ErrModeNotSupported.New(mode)
+		return nil, "", nil, borges.ErrModeNotSupported.New(mode)
 	}
+
+	s, err = l.opts.storer(fs, id, mode)
+	if err != nil {
+		return nil, "", nil, err
+	}
+
+	// the storers built by the StorerFactory may hold other resources, like
+	// database handles, so they are always closed with the repository.
+	if c, ok := s.(io.Closer); ok {
+		closers = []io.Closer{c}
+	}
+
+	if mode == borges.ReadOnlyMode {
+		return &util.ReadOnlyStorer{s}, "", closers, nil
+	}
+
+	if l.opts.Transactional {
+		return repositoryTemporalStorer(l, id, path, s, closers)
+	}
+
+	return s, "", closers, nil
 }
 
-func repositoryTemporalStorer(l *Location, id borges.RepositoryID, parent storage.Storer) (
-	s storage.Storer, tempPath string, err error) {
+func repositoryTemporalStorer(
+	l *Location,
+	id borges.RepositoryID,
+	path string,
+	parent storage.Storer,
+	closers []io.Closer,
+) (storage.Storer, string, []io.Closer, error) {
 
-	tempPath, err = billy.TempDir(l.opts.TemporalFilesystem, "transactions", "")
+	tempPath, err := billy.TempDir(l.opts.TemporalFilesystem, "transactions", "")
 	if err != nil {
-		return nil, "", err
+		releaseStorer(l, "", closers)
+		return nil, "", nil, err
 	}
 
 	fs, err := l.opts.TemporalFilesystem.Chroot(tempPath)
 	if err != nil {
-		return nil, "", err
+		releaseStorer(l, tempPath, closers)
+		return nil, "", nil, err
 	}
 
-	ts := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
-	s = transactional.NewStorage(parent, ts)
+	ts := l.opts.newStorage(fs)
+	s := &transactionalStorer{
+		Storage:  transactional.NewStorage(parent, ts),
+		parent:   parent,
+		temporal: ts,
+	}
 
-	return
+	if l.opts.hasQuota() {
+		if s.quota, err = newQuota(l, id, path, fs); err != nil {
+			releaseStorer(l, tempPath, append(closers, ts))
+			return nil, "", nil, err
+		}
+	}
+
+	return s, tempPath, append(closers, ts), nil
+}
+
+// releaseStorer closes the given closers and removes the temporal path, if
+// any, ignoring the errors. It's used to release the storers of a repository
+// that couldn't be opened.
+func releaseStorer(l *Location, tempPath string, closers []io.Closer) {
+	for _, c := range closers {
+		_ = c.Close()
+	}
+
+	if tempPath != "" {
+		_ = billy.RemoveAll(l.opts.TemporalFilesystem, tempPath)
+	}
+}
+
+// transactionalStorer keeps the storers composing a transactional.Storage,
+// they are used to validate the changes before committing them.
+type transactionalStorer struct {
+	*transactional.Storage
+	parent   storage.Storer
+	temporal storage.Storer
+	// quota is nil if the Location has no quotas.
+	quota *quota
+}
+
+// SetEncodedObject honors the storer.EncodedObjectStorer interface, it
+// returns ErrQuotaExceeded if the written object exceeds any quota.
+func (s *transactionalStorer) SetEncodedObject(obj plumbing.EncodedObject) (plumbing.Hash, error) {
+	h, err := s.Storage.SetEncodedObject(obj)
+	if err != nil || s.quota == nil {
+		return h, err
+	}
+
+	return h, s.quota.add(obj, h)
 }
 
 // R returns the git.Repository.
@@ -138,33 +252,153 @@ func (r *Repository) Mode() borges.Mode {
 }
 
 // Close closes the repository, if the repository was opened in transactional
-// Mode, will delete any write operation pending to be written.
+// Mode, will delete any write operation pending to be written. All the
+// storers are closed and the temporal files removed even if any of them
+// fails, the errors are returned together.
 func (r *Repository) Close() error {
-	if !r.l.opts.Transactional {
-		return nil
+	errs := r.closeStorers()
+	if r.l.opts.Transactional {
+		if ts, ok := r.Storer.(*transactionalStorer); ok && ts.quota != nil {
+			ts.quota.release()
+		}
+
+		if err := r.cleanupTemporal(); err != nil {
+			errs = append(errs, err)
+		}
+	} else if r.mode == borges.RWMode {
+		// the writes went directly to the filesystem.
+		r.l.usage.invalidate()
+	}
+
+	return joinErrors(errs)
+}
+
+// closeStorers closes the storers implementing io.Closer, releasing the
+// file descriptors kept open by them, and returns the errors found.
+func (r *Repository) closeStorers() []error {
+	var errs []error
+	for _, c := range r.closers {
+		if err := c.Close(); err != nil {
+			errs = append(errs, err)
+		}
 	}
 
-	return r.cleanupTemporal()
+	return errs
 }
 
 func (r *Repository) cleanupTemporal() error {
-	return billy.RemoveAll(r.l.opts.TemporalFilesystem, r.temporalPath)
+	err := billy.RemoveAll(r.l.opts.TemporalFilesystem, r.temporalPath)
+	if err != nil {
+		r.l.opts.Logger.Warn("can't remove temporal directory", err, r.fields(borges.Fields{
+			"temporal_path": r.temporalPath,
+		}))
+	}
+
+	return err
 }
 
 // Commit persists all the write operations done since was open, if the
 // repository wasn't opened in a Location with Transactions enable returns
-// ErrNonTransactional.
+// ErrNonTransactional. The changed references must point to objects fully
+// connected and the changes must pass LocationOptions.Validators, otherwise
+// ErrValidation is returned and the changes are discarded. If the changes
+// exceed any quota ErrQuotaExceeded is returned and they are discarded too.
 func (r *Repository) Commit() (err error) {
 	if !r.l.opts.Transactional {
 		return borges.ErrNonTransactional.New()
 	}
 
+	// registered first to log the error of the deferred Close too.
+	defer r.logCommit(&err)
 	defer ioutil.CheckClose(r, &err)
-	ts, ok := r.Storer.(*transactional.Storage)
+	ts, ok := r.Storer.(*transactionalStorer)
 	if !ok {
 		panic("unreachable code")
 	}
 
-	err = ts.Commit()
+	if err = r.validate(ts); err != nil {
+		return
+	}
+
+	if ts.quota != nil {
+		if err = ts.quota.commit(r.path); err != nil {
+			return
+		}
+
+		defer ts.quota.update(r.path, &err)
+	}
+
+	if err = ts.Commit(); err != nil {
+		return
+	}
+
+	if err = r.createRequiredPaths(); err != nil {
+		return
+	}
+
+	if r.init != nil {
+		err = r.init.writeTemplates(r.l.fs, r.path)
+	}
+
 	return
 }
+
+func (r *Repository) logCommit(err *error) {
+	if *err != nil {
+		r.l.opts.Logger.Error("commit failed", *err, r.fields(nil))
+		return
+	}
+
+	r.l.opts.Logger.Info("repository committed", r.fields(nil))
+}
+
+// fields returns the given fields with the RepositoryID, LocationID and Mode
+// of this Repository.
+func (r *Repository) fields(fields borges.Fields) borges.Fields {
+	fields = r.l.fields(fields)
+	fields["id"] = r.id
+	fields["mode"] = "read-only"
+	if r.mode == borges.RWMode {
+		fields["mode"] = "rw"
+	}
+
+	return fields
+}
+
+// createRequiredPaths creates the directories checked by IsRepository, they
+// aren't created by the parent storer when a repository is initialized in
+// transactional mode.
+func (r *Repository) createRequiredPaths() error {
+	for _, p := range []string{"objects", "refs/heads"} {
+		if err := r.l.fs.MkdirAll(r.l.fs.Join(r.path, p), 0755); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// errorList is an error composed of several errors.
+type errorList []error
+
+func (e errorList) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// joinErrors returns nil if errs is empty, the error itself if there is
+// only one and an errorList otherwise.
+func joinErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	default:
+		return errorList(errs)
+	}
+}
@@ -1,6 +1,8 @@
 package plain
 
 import (
+	"fmt"
+	"io"
 	"os"
 	"testing"
 
@@ -18,7 +20,7 @@ func TestInitRepository(t *testing.T) {
 	location, err := NewLocation("foo", memory, nil)
 	require.NoError(err)
 
-	r, err := initRepository(location, "github.com/foo/bar")
+	r, err := initRepository(location, "github.com/foo/bar", location.opts.InitOptions)
 	require.NoError(err)
 	require.NotNil(r)
 
@@ -43,7 +45,7 @@ func TestInitRepository_Transactional(t *testing.T) {
 	})
 	require.NoError(err)
 
-	r, err := initRepository(location, "github.com/foo/bar")
+	r, err := initRepository(location, "github.com/foo/bar", location.opts.InitOptions)
 	require.NoError(err)
 	require.NotNil(r)
 
@@ -108,3 +110,35 @@ func TestRepository_Close(t *testing.T) {
 	_, err = tmp.Stat(tmp.Join(r.(*Repository).temporalPath, "refs/heads/foo"))
 	require.True(os.IsNotExist(err))
 }
+
+type failingCloser struct{ closed bool }
+
+func (c *failingCloser) Close() error {
+	c.closed = true
+	return fmt.Errorf("close failed")
+}
+
+func TestRepository_Close_Errors(t *testing.T) {
+	require := require.New(t)
+	tmp := memfs.New()
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional:      true,
+		TemporalFilesystem: tmp,
+	})
+	require.NoError(err)
+
+	r, err := location.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	first, second := &failingCloser{}, &failingCloser{}
+	repo := r.(*Repository)
+	repo.closers = append([]io.Closer{first, second}, repo.closers...)
+
+	require.EqualError(r.Close(), "close failed; close failed")
+	require.True(first.closed)
+	require.True(second.closed)
+
+	_, err = tmp.Stat(repo.temporalPath)
+	require.True(os.IsNotExist(err))
+}
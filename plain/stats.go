@@ -0,0 +1,380 @@
+package plain
+
+import (
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/src-d/go-borges"
+
+	"gopkg.in/src-d/go-billy.v4"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
+)
+
+// DefaultLargestRepositories is the default number of repositories reported
+// in Stats.Largest.
+const DefaultLargestRepositories = 10
+
+// RepositoryStats are the statistics of a Repository. The sizes and the
+// objects are the ones stored on disk, the changes of a transactional
+// Repository are not counted until they are committed.
+type RepositoryStats struct {
+	// ID is the RepositoryID of the repository.
+	ID borges.RepositoryID
+	// Location is the LocationID of the Location containing the repository.
+	Location borges.LocationID
+	// Bytes is the size of the git directory.
+	Bytes int64
+	// Objects is the number of objects, loose and packed. Objects stored in
+	// several packfiles are counted once per packfile.
+	Objects int64
+	// LooseObjects is the number of loose objects.
+	LooseObjects int
+	// PackSizes are the sizes of the packfiles.
+	PackSizes []int64
+	// References is the number of references, HEAD excluded.
+	References int
+	// LastCommit is the newest committer time of the commits pointed by the
+	// references, zero if none of them points to a commit.
+	LastCommit time.Time
+}
+
+// Stats are the aggregated statistics of the repositories of a Location or
+// a Library.
+type Stats struct {
+	// Repositories is the number of repositories.
+	Repositories int
+	// Bytes is the size of the git directories.
+	Bytes int64
+	// LooseObjects is the number of loose objects.
+	LooseObjects int
+	// Packfiles is the number of packfiles.
+	Packfiles int
+	// References is the number of references, HEAD excluded.
+	References int
+	// Largest are the biggest repositories by Bytes, in descending order.
+	Largest []*RepositoryStats
+}
+
+// add aggregates the statistics of a repository keeping up to n largest
+// repositories.
+func (s *Stats) add(rs *RepositoryStats, n int) {
+	s.Repositories++
+	s.Bytes += rs.Bytes
+	s.LooseObjects += rs.LooseObjects
+	s.Packfiles += len(rs.PackSizes)
+	s.References += rs.References
+	s.Largest = largest(append(s.Largest, rs), n)
+}
+
+// merge aggregates the statistics of another Location or Library keeping up
+// to n largest repositories.
+func (s *Stats) merge(o *Stats, n int) {
+	s.Repositories += o.Repositories
+	s.Bytes += o.Bytes
+	s.LooseObjects += o.LooseObjects
+	s.Packfiles += o.Packfiles
+	s.References += o.References
+	s.Largest = largest(append(s.Largest, o.Largest...), n)
+}
+
+// copy returns a deep copy of the Stats, so the cached ones can't be
+// modified by the callers.
+func (s *Stats) copy() *Stats {
+	c := *s
+	c.Largest = make([]*RepositoryStats, len(s.Largest))
+	for i, rs := range s.Largest {
+		r := *rs
+		r.PackSizes = append([]int64(nil), rs.PackSizes...)
+		c.Largest[i] = &r
+	}
+
+	return &c
+}
+
+func largest(rs []*RepositoryStats, n int) []*RepositoryStats {
+	sort.SliceStable(rs, func(i, j int) bool { return rs[i].Bytes > rs[j].Bytes })
+	if len(rs) > n {
+		rs = rs[:n]
+	}
+
+	return rs
+}
+
+// statsCache keeps the last computed Stats for a TTL.
+type statsCache struct {
+	sync.Mutex
+	stats   *Stats
+	expires time.Time
+}
+
+// get returns a copy of the cached Stats or computes them with the given
+// function, the Stats are only cached if ttl is greater than zero.
+func (c *statsCache) get(ttl time.Duration, compute func() (*Stats, error)) (*Stats, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	if c.stats != nil && time.Now().Before(c.expires) {
+		return c.stats.copy(), nil
+	}
+
+	stats, err := compute()
+	if err != nil || ttl <= 0 {
+		return stats, err
+	}
+
+	c.stats, c.expires = stats, time.Now().Add(ttl)
+	return stats.copy(), nil
+}
+
+// Stats returns the statistics of this Repository.
+func (r *Repository) Stats() (*RepositoryStats, error) {
+	rs := &RepositoryStats{ID: r.id, Location: r.l.id}
+	if err := diskStats(r.l.fs, r.path, rs); err != nil {
+		return nil, err
+	}
+
+	if err := refStats(r, rs); err != nil {
+		return nil, err
+	}
+
+	return rs, nil
+}
+
+// diskStats walks the git directory at the given path computing its size
+// and counting the loose objects and the packfiles.
+func diskStats(fs billy.Filesystem, path string, rs *RepositoryStats) error {
+	objects := fs.Join(path, "objects")
+	pack := fs.Join(objects, "pack")
+
+	return walk(fs, path, func(dir string, fi os.FileInfo) error {
+		rs.Bytes += fi.Size()
+
+		switch {
+		case dir == pack && strings.HasSuffix(fi.Name(), ".pack"):
+			rs.PackSizes = append(rs.PackSizes, fi.Size())
+		case dir == pack && strings.HasSuffix(fi.Name(), ".idx"):
+			n, err := idxObjects(fs, fs.Join(dir, fi.Name()))
+			if err != nil {
+				return err
+			}
+
+			rs.Objects += n
+		case filepath.Dir(dir) == objects && isLooseObjectDir(filepath.Base(dir)):
+			rs.LooseObjects++
+			rs.Objects++
+		}
+
+		return nil
+	})
+}
+
+// isLooseObjectDir returns true if the name is the one of the directories
+// containing the loose objects, the first two hex digits of the hash.
+func isLooseObjectDir(name string) bool {
+	return len(name) == 2 && strings.Trim(name, "0123456789abcdef") == ""
+}
+
+// walk calls fn for every file under the given path with the directory
+// containing it.
+func walk(fs billy.Filesystem, path string, fn func(string, os.FileInfo) error) error {
+	entries, err := fs.ReadDir(path)
+	if err != nil {
+		return err
+	}
+
+	for _, fi := range entries {
+		if fi.IsDir() {
+			err = walk(fs, fs.Join(path, fi.Name()), fn)
+		} else {
+			err = fn(path, fi)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// idxObjects returns the number of objects of a packfile index, read from
+// the last entry of its fanout table.
+func idxObjects(fs billy.Filesystem, path string) (n int64, err error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return 0, err
+	}
+
+	defer ioutil.CheckClose(f, &err)
+
+	// magic number, version and the first 255 entries of the fanout table.
+	if _, err := f.Seek(8+255*4, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	var count uint32
+	if err := binary.Read(f, binary.BigEndian, &count); err != nil {
+		return 0, err
+	}
+
+	return int64(count), nil
+}
+
+// refStats counts the references of the repository and finds the newest
+// commit pointed by them.
+func refStats(r *Repository, rs *RepositoryStats) error {
+	iter, err := r.Storer.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	return iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Name() == plumbing.HEAD {
+			return nil
+		}
+
+		rs.References++
+		if ref.Type() != plumbing.HashReference {
+			return nil
+		}
+
+		c, err := r.CommitObject(ref.Hash())
+		if err == plumbing.ErrObjectNotFound || err == plumbing.ErrInvalidType {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if c.Committer.When.After(rs.LastCommit) {
+			rs.LastCommit = c.Committer.When
+		}
+
+		return nil
+	})
+}
+
+// Stats returns the statistics of the repositories of this Location. The
+// repositories are read in parallel by LocationOptions.StatsWorkers and,
+// if LocationOptions.StatsTTL is set, the result is cached.
+func (l *Location) Stats() (*Stats, error) {
+	return l.stats.get(l.opts.StatsTTL, l.computeStats)
+}
+
+func (l *Location) computeStats() (*Stats, error) {
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		stats   = &Stats{}
+		errs    = make(chan error, l.opts.StatsWorkers)
+		repos   = make(chan borges.Repository)
+		done    = make(chan struct{})
+		closing sync.Once
+	)
+
+	fail := func(err error) {
+		select {
+		case errs <- err:
+		default:
+		}
+
+		closing.Do(func() { close(done) })
+	}
+
+	for i := 0; i < l.opts.StatsWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for r := range repos {
+				rs, err := r.(*Repository).Stats()
+				if cerr := r.Close(); err == nil {
+					err = cerr
+				}
+
+				if err != nil {
+					fail(err)
+					continue
+				}
+
+				mu.Lock()
+				stats.add(rs, l.opts.LargestRepositories)
+				mu.Unlock()
+			}
+		}()
+	}
+
+	err = iter.ForEach(func(r borges.Repository) error {
+		select {
+		case repos <- r:
+			return nil
+		case <-done:
+			r.Close()
+			return borges.ErrStop
+		}
+	})
+
+	close(repos)
+	wg.Wait()
+
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case err := <-errs:
+		return nil, err
+	default:
+	}
+
+	return stats, nil
+}
+
+// Stats returns the aggregated statistics of the locations of this Library
+// and of all its sub-libraries, unlike Repositories that only covers the
+// locations of this Library. A Location added to several of them is counted
+// once. The statistics of every Location are cached following its own
+// LocationOptions, the ones of the Library following
+// LibraryOptions.StatsTTL.
+func (l *Library) Stats() (*Stats, error) {
+	return l.stats.get(l.opts.StatsTTL, func() (*Stats, error) {
+		locs := make(map[borges.LocationID]*Location)
+		l.allLocations(locs)
+
+		stats := &Stats{}
+		for _, loc := range locs {
+			s, err := loc.Stats()
+			if err != nil {
+				return nil, err
+			}
+
+			stats.merge(s, l.opts.LargestRepositories)
+		}
+
+		return stats, nil
+	})
+}
+
+// allLocations adds the locations of this Library and its sub-libraries to
+// locs, by LocationID.
+func (l *Library) allLocations(locs map[borges.LocationID]*Location) {
+	for id, loc := range l.locs {
+		locs[id] = loc
+	}
+
+	for _, lib := range l.libs {
+		lib.allLocations(locs)
+	}
+}
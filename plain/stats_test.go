@@ -0,0 +1,187 @@
+package plain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+// initWithBlobs initializes a repository with a reference pointing to the
+// last of the given number of blobs, stored as loose objects.
+func initWithBlobs(require *require.Assertions, l *Location, id borges.RepositoryID, blobs int) {
+	r, err := l.Init(id)
+	require.NoError(err)
+
+	var h plumbing.Hash
+	for i := 0; i < blobs; i++ {
+		obj := r.R().Storer.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, err := obj.Writer()
+		require.NoError(err)
+		_, err = w.Write([]byte(string(id) + string(rune('a'+i))))
+		require.NoError(err)
+		require.NoError(w.Close())
+
+		h, err = r.R().Storer.SetEncodedObject(obj)
+		require.NoError(err)
+	}
+
+	ref := plumbing.NewHashReference("refs/heads/master", h)
+	require.NoError(r.R().Storer.SetReference(ref))
+	require.NoError(r.Close())
+}
+
+func TestRepository_Stats(t *testing.T) {
+	require := require.New(t)
+
+	location := newLocationWithFixtures(require, nil)
+
+	r, err := location.Get("basic.git", borges.ReadOnlyMode)
+	require.NoError(err)
+	defer r.Close()
+
+	rs, err := r.(*Repository).Stats()
+	require.NoError(err)
+
+	require.Equal(borges.RepositoryID("basic.git"), rs.ID)
+	require.Equal(borges.LocationID("foo"), rs.Location)
+	require.Equal(int64(31), rs.Objects)
+	require.Equal(0, rs.LooseObjects)
+	require.Equal([]int64{84794}, rs.PackSizes)
+	require.Equal(6, rs.References)
+	require.Equal(int64(1428269447), rs.LastCommit.Unix())
+	require.True(rs.Bytes > rs.PackSizes[0])
+}
+
+func TestRepository_Stats_Loose(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), nil)
+	require.NoError(err)
+	initWithBlobs(require, location, "foo", 3)
+
+	r, err := location.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	rs, err := r.(*Repository).Stats()
+	require.NoError(err)
+
+	require.Equal(int64(3), rs.Objects)
+	require.Equal(3, rs.LooseObjects)
+	require.Empty(rs.PackSizes)
+	require.Equal(1, rs.References)
+	require.True(rs.LastCommit.IsZero())
+}
+
+func TestLocation_Stats(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		StatsWorkers:        2,
+		LargestRepositories: 2,
+	})
+	require.NoError(err)
+
+	for i, id := range []borges.RepositoryID{"a", "b", "c", "d"} {
+		initWithBlobs(require, location, id, i+1)
+	}
+
+	stats, err := location.Stats()
+	require.NoError(err)
+
+	require.Equal(4, stats.Repositories)
+	require.Equal(10, stats.LooseObjects)
+	require.Equal(0, stats.Packfiles)
+	require.Equal(4, stats.References)
+	require.Len(stats.Largest, 2)
+	require.Equal(borges.RepositoryID("d"), stats.Largest[0].ID)
+	require.Equal(borges.RepositoryID("c"), stats.Largest[1].ID)
+
+	var bytes int64
+	iter, err := location.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(iter.ForEach(func(r borges.Repository) error {
+		rs, err := r.(*Repository).Stats()
+		require.NoError(err)
+		bytes += rs.Bytes
+		return r.Close()
+	}))
+	require.Equal(bytes, stats.Bytes)
+}
+
+func TestLocation_Stats_Cache(t *testing.T) {
+	require := require.New(t)
+
+	location, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		StatsTTL: time.Hour,
+	})
+	require.NoError(err)
+	initWithBlobs(require, location, "foo", 1)
+
+	stats, err := location.Stats()
+	require.NoError(err)
+	require.Equal(1, stats.Repositories)
+
+	initWithBlobs(require, location, "bar", 1)
+
+	stats, err = location.Stats()
+	require.NoError(err)
+	require.Equal(1, stats.Repositories)
+
+	location.stats.expires = time.Now()
+
+	stats, err = location.Stats()
+	require.NoError(err)
+	require.Equal(2, stats.Repositories)
+}
+
+func TestLibrary_Stats(t *testing.T) {
+	require := require.New(t)
+
+	lfoo, err := NewLocation("foo", memfs.New(), nil)
+	require.NoError(err)
+	initWithBlobs(require, lfoo, "a", 1)
+
+	lbar, err := NewLocation("bar", memfs.New(), nil)
+	require.NoError(err)
+	initWithBlobs(require, lbar, "b", 3)
+	initWithBlobs(require, lbar, "c", 2)
+
+	// the locations added to several libraries are counted once.
+	nested := NewLibrary("nested")
+	nested.AddLocation(lbar)
+	nested.AddLocation(lfoo)
+
+	lib, err := NewLibraryWithOptions("foo", &LibraryOptions{
+		LargestRepositories: 2,
+		StatsTTL:            time.Hour,
+	})
+	require.NoError(err)
+	lib.AddLocation(lfoo)
+	lib.AddLibrary(nested)
+
+	stats, err := lib.Stats()
+	require.NoError(err)
+
+	require.Equal(3, stats.Repositories)
+	require.Equal(6, stats.LooseObjects)
+	require.Equal(3, stats.References)
+	require.Len(stats.Largest, 2)
+	require.Equal(borges.RepositoryID("b"), stats.Largest[0].ID)
+	require.Equal(borges.LocationID("bar"), stats.Largest[0].Location)
+	require.Equal(borges.RepositoryID("c"), stats.Largest[1].ID)
+
+	// the cached statistics can't be modified.
+	stats.Repositories = 0
+	stats.Largest[0].Bytes = 0
+
+	stats, err = lib.Stats()
+	require.NoError(err)
+	require.Equal(3, stats.Repositories)
+	require.NotZero(stats.Largest[0].Bytes)
+}
@@ -0,0 +1,58 @@
+package plain_test
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+func TestLocationSuite(t *testing.T) {
+	for _, opts := range []plain.LocationOptions{
+		{},
+		{Bare: true},
+		{Transactional: true},
+		{Bare: true, Transactional: true},
+		{IDMapper: plain.ShardedMapper{}},
+		{IDMapper: plain.FlatMapper{}, Transactional: true},
+	} {
+		opts := opts
+		suite.Run(t, &borgestest.LocationSuite{
+			NewLocation: func() (borges.Location, error) {
+				o := opts
+				return plain.NewLocation("foo", memfs.New(), &o)
+			},
+			Transactional: opts.Transactional,
+		})
+	}
+}
+
+func TestLibrarySuite(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LibrarySuite{
+			NewLibrary: func() (borges.Library, error) {
+				opts := &plain.LocationOptions{Transactional: transactional}
+				lfoo, err := plain.NewLocation("foo", memfs.New(), opts)
+				if err != nil {
+					return nil, err
+				}
+
+				lbar, err := plain.NewLocation("bar", memfs.New(), opts)
+				if err != nil {
+					return nil, err
+				}
+
+				l := plain.NewLibrary("foo")
+				l.AddLocation(lfoo)
+				l.AddLocation(lbar)
+				return l, nil
+			},
+			Transactional: transactional,
+		})
+	}
+}
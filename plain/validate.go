@@ -0,0 +1,240 @@
+package plain
+
+import (
+	"fmt"
+	"path"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/storage/transactional"
+)
+
+// ErrValidation is returned by Repository.Commit when the changes are
+// rejected by the connectivity check or by a Validator.
+var ErrValidation = errors.NewKind("commit of repository %s rejected: %s")
+
+// Changes are the changes of a transactional Repository pending to be
+// committed.
+type Changes struct {
+	// ID is the RepositoryID of the repository.
+	ID borges.RepositoryID
+	// Refs are the references created or updated since the repository was
+	// opened, removed references aren't included.
+	Refs []util.RefChange
+	// Temporal is the storer containing only the changes.
+	Temporal storage.Storer
+	// Parent is the storer of the repository without the changes.
+	Parent storage.Storer
+}
+
+// NewObjects calls the function for every object written since the
+// repository was opened.
+func (c *Changes) NewObjects(fn func(plumbing.EncodedObject) error) error {
+	iter, err := c.Temporal.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return err
+	}
+
+	return iter.ForEach(fn)
+}
+
+// Validator checks the changes of a Repository before they are committed,
+// returning an error rejects the commit.
+type Validator func(*Changes) error
+
+// MaxSize returns a Validator rejecting the commits writing more than the
+// given number of bytes of uncompressed objects.
+func MaxSize(bytes int64) Validator {
+	return func(c *Changes) error {
+		var size int64
+		return c.NewObjects(func(obj plumbing.EncodedObject) error {
+			size += obj.Size()
+			if size > bytes {
+				return fmt.Errorf("new objects exceed %d bytes", bytes)
+			}
+
+			return nil
+		})
+	}
+}
+
+// ForbiddenPaths returns a Validator rejecting the commits adding files
+// whose path or base name matches any of the patterns, as in path.Match.
+// Only the trees written since the repository was opened are checked.
+func ForbiddenPaths(patterns ...string) Validator {
+	return func(c *Changes) error {
+		s := transactional.NewStorage(c.Parent, c.Temporal)
+		return c.NewObjects(func(obj plumbing.EncodedObject) error {
+			if obj.Type() != plumbing.CommitObject {
+				return nil
+			}
+
+			commit, err := object.DecodeCommit(s, obj)
+			if err != nil {
+				return err
+			}
+
+			return checkPaths(s, c.Parent, commit.TreeHash, "", patterns)
+		})
+	}
+}
+
+func checkPaths(
+	s storer.EncodedObjectStorer,
+	parent storer.EncodedObjectStorer,
+	h plumbing.Hash,
+	prefix string,
+	patterns []string,
+) error {
+	// trees already in the parent were accepted before.
+	if parent.HasEncodedObject(h) == nil {
+		return nil
+	}
+
+	tree, err := object.GetTree(s, h)
+	if err != nil {
+		return err
+	}
+
+	for _, e := range tree.Entries {
+		name := path.Join(prefix, e.Name)
+		for _, p := range patterns {
+			full, _ := path.Match(p, name)
+			base, _ := path.Match(p, e.Name)
+			if full || base {
+				return fmt.Errorf("forbidden path %s", name)
+			}
+		}
+
+		if e.Mode != filemode.Dir {
+			continue
+		}
+
+		if err := checkPaths(s, parent, e.Hash, name, patterns); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// validate checks the connectivity of the changed references and runs the
+// validators configured in the Location.
+func (r *Repository) validate(ts *transactionalStorer) error {
+	changes, err := newChanges(r.id, ts)
+	if err != nil {
+		return err
+	}
+
+	for _, ref := range changes.Refs {
+		if err := checkConnectivity(changes, ref.New); err != nil {
+			return ErrValidation.New(r.id, fmt.Sprintf("%s: %s", ref.Name, err))
+		}
+	}
+
+	for _, v := range r.l.opts.Validators {
+		if err := v(changes); err != nil {
+			return ErrValidation.Wrap(err, r.id, err.Error())
+		}
+	}
+
+	return nil
+}
+
+func newChanges(id borges.RepositoryID, ts *transactionalStorer) (*Changes, error) {
+	refs, err := util.References(ts.temporal)
+	if err != nil {
+		return nil, err
+	}
+
+	parent := make(map[plumbing.ReferenceName]plumbing.Hash)
+	for name := range refs {
+		ref, err := ts.parent.Reference(name)
+		if err == plumbing.ErrReferenceNotFound {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		parent[name] = ref.Hash()
+	}
+
+	return &Changes{
+		ID:       id,
+		Refs:     util.DiffReferences(parent, refs),
+		Temporal: ts.temporal,
+		Parent:   ts.parent,
+	}, nil
+}
+
+// checkConnectivity verifies that every object reachable from h exists. The
+// objects found in the parent storer are considered connected, so only the
+// new objects are walked.
+func checkConnectivity(c *Changes, h plumbing.Hash) error {
+	seen := make(map[plumbing.Hash]struct{})
+	pending := []plumbing.Hash{h}
+	for len(pending) > 0 {
+		h, pending = pending[len(pending)-1], pending[:len(pending)-1]
+		if _, ok := seen[h]; ok {
+			continue
+		}
+
+		seen[h] = struct{}{}
+		if c.Parent.HasEncodedObject(h) == nil {
+			continue
+		}
+
+		obj, err := c.Temporal.EncodedObject(plumbing.AnyObject, h)
+		if err == plumbing.ErrObjectNotFound {
+			return fmt.Errorf("missing object %s", h)
+		}
+
+		if err != nil {
+			return err
+		}
+
+		next, err := references(c.Temporal, obj)
+		if err != nil {
+			return err
+		}
+
+		pending = append(pending, next...)
+	}
+
+	return nil
+}
+
+// references returns the hashes of the objects referenced by obj.
+func references(s storer.EncodedObjectStorer, obj plumbing.EncodedObject) ([]plumbing.Hash, error) {
+	o, err := object.DecodeObject(s, obj)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o := o.(type) {
+	case *object.Commit:
+		return append([]plumbing.Hash{o.TreeHash}, o.ParentHashes...), nil
+	case *object.Tree:
+		var hashes []plumbing.Hash
+		for _, e := range o.Entries {
+			if e.Mode != filemode.Submodule {
+				hashes = append(hashes, e.Hash)
+			}
+		}
+
+		return hashes, nil
+	case *object.Tag:
+		return []plumbing.Hash{o.Target}, nil
+	default:
+		return nil, nil
+	}
+}
@@ -0,0 +1,190 @@
+package plain
+
+import (
+	"testing"
+	"time"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/filemode"
+	"gopkg.in/src-d/go-git.v4/plumbing/object"
+	"gopkg.in/src-d/go-git.v4/storage"
+)
+
+func setObject(require *require.Assertions, s storage.Storer, o interface {
+	Encode(plumbing.EncodedObject) error
+}) plumbing.Hash {
+	obj := s.NewEncodedObject()
+	require.NoError(o.Encode(obj))
+	h, err := s.SetEncodedObject(obj)
+	require.NoError(err)
+	return h
+}
+
+func setBlob(require *require.Assertions, s storage.Storer, content string) plumbing.Hash {
+	obj := s.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	require.NoError(err)
+	_, err = w.Write([]byte(content))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	h, err := s.SetEncodedObject(obj)
+	require.NoError(err)
+	return h
+}
+
+// setCommit writes a commit with a single file with the given name and
+// content.
+func setCommit(
+	require *require.Assertions,
+	s storage.Storer,
+	name, content string,
+	parents ...plumbing.Hash,
+) plumbing.Hash {
+	blob := setBlob(require, s, content)
+	tree := setObject(require, s, &object.Tree{Entries: []object.TreeEntry{
+		{Name: name, Mode: filemode.Regular, Hash: blob},
+	}})
+
+	return setCommitTree(require, s, tree, parents...)
+}
+
+func setCommitTree(
+	require *require.Assertions,
+	s storage.Storer,
+	tree plumbing.Hash,
+	parents ...plumbing.Hash,
+) plumbing.Hash {
+	sig := object.Signature{Name: "foo", Email: "foo@foo.com", When: time.Now()}
+	return setObject(require, s, &object.Commit{
+		Author:       sig,
+		Committer:    sig,
+		Message:      "foo",
+		TreeHash:     tree,
+		ParentHashes: parents,
+	})
+}
+
+func newValidatedLocation(require *require.Assertions, validators ...Validator) *Location {
+	loc, err := NewLocation("foo", memfs.New(), &LocationOptions{
+		Transactional: true,
+		Validators:    validators,
+	})
+	require.NoError(err)
+	return loc
+}
+
+func requireRef(require *require.Assertions, loc *Location, name plumbing.ReferenceName, h plumbing.Hash) {
+	r, err := loc.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	defer r.Close()
+
+	ref, err := r.R().Reference(name, false)
+	if h.IsZero() {
+		require.Equal(plumbing.ErrReferenceNotFound, err)
+		return
+	}
+
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+}
+
+func TestRepository_Commit_Connectivity(t *testing.T) {
+	require := require.New(t)
+
+	loc := newValidatedLocation(require)
+
+	r, err := loc.Init("foo")
+	require.NoError(err)
+	s := r.R().Storer
+
+	first := setCommit(require, s, "README", "foo")
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", first)))
+	require.NoError(r.Commit())
+	requireRef(require, loc, "refs/heads/master", first)
+
+	// the parent commit is only in the parent storer.
+	r, err = loc.Get("foo", borges.RWMode)
+	require.NoError(err)
+	s = r.R().Storer
+
+	second := setCommit(require, s, "README", "bar", first)
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", second)))
+	require.NoError(r.Commit())
+	requireRef(require, loc, "refs/heads/master", second)
+
+	// dangling reference.
+	r, err = loc.Get("foo", borges.RWMode)
+	require.NoError(err)
+
+	missing := plumbing.NewHash("434611b74cb54538088c6aeed4ed27d3044064fa")
+	ref := plumbing.NewHashReference("refs/heads/dangling", missing)
+	require.NoError(r.R().Storer.SetReference(ref))
+
+	err = r.Commit()
+	require.True(ErrValidation.Is(err))
+	require.Contains(err.Error(), missing.String())
+	requireRef(require, loc, "refs/heads/dangling", plumbing.ZeroHash)
+
+	// commit pointing to a missing tree.
+	r, err = loc.Get("foo", borges.RWMode)
+	require.NoError(err)
+	s = r.R().Storer
+
+	broken := setCommitTree(require, s, missing, second)
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", broken)))
+
+	err = r.Commit()
+	require.True(ErrValidation.Is(err))
+	requireRef(require, loc, "refs/heads/master", second)
+}
+
+func TestRepository_Commit_Validators(t *testing.T) {
+	require := require.New(t)
+
+	var changes *Changes
+	loc := newValidatedLocation(require, func(c *Changes) error {
+		changes = c
+		return nil
+	}, ForbiddenPaths("*.key", "vendor"), MaxSize(1024))
+
+	r, err := loc.Init("foo")
+	require.NoError(err)
+	s := r.R().Storer
+
+	h := setCommit(require, s, "README", "foo")
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", h)))
+	require.NoError(r.Commit())
+
+	require.Equal(borges.RepositoryID("foo"), changes.ID)
+	require.Len(changes.Refs, 1)
+	require.Equal(plumbing.ReferenceName("refs/heads/master"), changes.Refs[0].Name)
+	require.Equal(h, changes.Refs[0].New)
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{"id_rsa.key", "secret"},
+		{"vendor", "foo"},
+		{"big", string(make([]byte, 2048))},
+	}
+
+	for _, test := range tests {
+		r, err = loc.Get("foo", borges.RWMode)
+		require.NoError(err)
+		s = r.R().Storer
+
+		c := setCommit(require, s, test.name, test.content, h)
+		require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", c)))
+
+		err = r.Commit()
+		require.True(ErrValidation.Is(err), test.name)
+		requireRef(require, loc, "refs/heads/master", h)
+	}
+}
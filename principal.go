@@ -0,0 +1,26 @@
+package borges
+
+import "context"
+
+// Principal is the identity performing the operations, it's carried by a
+// context to be checked or recorded by the Library wrappers.
+type Principal struct {
+	// Name of the principal, like the user name.
+	Name string
+	// Groups are the groups the principal belongs to.
+	Groups []string
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a new context carrying the given Principal.
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the Principal carried by the context, if the
+// context doesn't have any an anonymous Principal is returned.
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
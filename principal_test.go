@@ -0,0 +1,20 @@
+package borges
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrincipalFromContext(t *testing.T) {
+	require := require.New(t)
+
+	_, ok := PrincipalFromContext(context.Background())
+	require.False(ok)
+
+	ctx := WithPrincipal(context.Background(), Principal{Name: "alice"})
+	p, ok := PrincipalFromContext(ctx)
+	require.True(ok)
+	require.Equal("alice", p.Name)
+}
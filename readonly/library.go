@@ -0,0 +1,153 @@
+// Package readonly provides borges.Library and borges.Location wrappers that
+// never allow write operations. Every library, location and repository
+// returned by them is wrapped too.
+package readonly
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// ErrReadOnly is returned when a write operation is requested.
+var ErrReadOnly = errors.NewKind("read-only: %s is not allowed")
+
+// Options contains configuration options for the read-only wrappers.
+type Options struct {
+	// Strict rejects the requests in RWMode with ErrReadOnly instead of
+	// downgrading them to ReadOnlyMode.
+	Strict bool
+}
+
+// mode returns the mode to be used for a request with the given mode.
+func (o *Options) mode(op string, m borges.Mode) (borges.Mode, error) {
+	if m == borges.ReadOnlyMode {
+		return m, nil
+	}
+
+	if o.Strict {
+		return m, ErrReadOnly.New(op + " in RWMode")
+	}
+
+	return borges.ReadOnlyMode, nil
+}
+
+// Library wraps a borges.Library, Init and GetOrInit return ErrReadOnly and
+// the repositories are always opened in ReadOnlyMode.
+type Library struct {
+	lib  borges.Library
+	opts *Options
+}
+
+var _ borges.Library = (*Library)(nil)
+
+// NewLibrary returns a new read-only Library wrapping the given one.
+func NewLibrary(lib borges.Library, opts *Options) *Library {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	return &Library{lib: lib, opts: opts}
+}
+
+// ID returns the borges.LibraryID of the wrapped Library.
+func (l *Library) ID() borges.LibraryID {
+	return l.lib.ID()
+}
+
+// Init returns ErrReadOnly.
+func (l *Library) Init(borges.RepositoryID) (borges.Repository, error) {
+	return nil, ErrReadOnly.New("Init")
+}
+
+// GetOrInit returns ErrReadOnly.
+func (l *Library) GetOrInit(borges.RepositoryID) (borges.Repository, error) {
+	return nil, ErrReadOnly.New("GetOrInit")
+}
+
+// Get open a repository with the given RepositoryID in ReadOnlyMode. If the
+// RWMode is requested and Options.Strict is set ErrReadOnly is returned.
+func (l *Library) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	mode, err := l.opts.mode("Get", mode)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := l.lib.Get(id, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRepository(r)
+}
+
+// Has returns true, the LibraryID and the LocationID if the given
+// RepositoryID matches any repository of the wrapped Library.
+func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	return l.lib.Has(id)
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Library in ReadOnlyMode.
+func (l *Library) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	mode, err := l.opts.mode("Repositories", mode)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := l.lib.Repositories(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapRepositoryIterator(iter, newRepository), nil
+}
+
+// Location returns the Location with the given LocationID wrapped as a
+// read-only Location.
+func (l *Library) Location(id borges.LocationID) (borges.Location, error) {
+	loc, err := l.lib.Location(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLocation(loc, l.opts), nil
+}
+
+// Locations returns a LocationIterator that iterates through all the
+// locations of the wrapped Library wrapped as read-only locations.
+func (l *Library) Locations() (borges.LocationIterator, error) {
+	iter, err := l.lib.Locations()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLocationIterator(iter, func(loc borges.Location) (borges.Location, error) {
+		return NewLocation(loc, l.opts), nil
+	}), nil
+}
+
+// Library returns the Library with the given LibraryID wrapped as a
+// read-only Library.
+func (l *Library) Library(id borges.LibraryID) (borges.Library, error) {
+	lib, err := l.lib.Library(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewLibrary(lib, l.opts), nil
+}
+
+// Libraries returns a LibraryIterator that iterates through all the
+// libraries of the wrapped Library wrapped as read-only libraries.
+func (l *Library) Libraries() (borges.LibraryIterator, error) {
+	iter, err := l.lib.Libraries()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLibraryIterator(iter, func(lib borges.Library) (borges.Library, error) {
+		return NewLibrary(lib, l.opts), nil
+	}), nil
+}
@@ -0,0 +1,111 @@
+package readonly
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLibrary_Init(t *testing.T) {
+	require := require.New(t)
+
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), nil)
+
+	_, err := lib.Init("github.com/foo/qux")
+	require.True(ErrReadOnly.Is(err))
+
+	_, err = lib.GetOrInit("github.com/foo/bar")
+	require.True(ErrReadOnly.Is(err))
+}
+
+func TestLibrary_Get(t *testing.T) {
+	require := require.New(t)
+
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), nil)
+
+	r, err := lib.Get("github.com/foo/bar", borges.RWMode)
+	require.NoError(err)
+	requireReadOnly(require, r)
+
+	lib = NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), &Options{Strict: true})
+
+	_, err = lib.Get("github.com/foo/bar", borges.RWMode)
+	require.True(ErrReadOnly.Is(err))
+
+	r, err = lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	requireReadOnly(require, r)
+
+	ok, libID, locID, err := lib.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(ok)
+	require.Equal(borges.LibraryID("foo"), libID)
+	require.Equal(borges.LocationID("foo"), locID)
+}
+
+func TestLibrary_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), nil)
+
+	iter, err := lib.Repositories(borges.RWMode)
+	require.NoError(err)
+
+	var count int
+	err = iter.ForEach(func(r borges.Repository) error {
+		requireReadOnly(require, r)
+		count++
+		return nil
+	})
+
+	require.NoError(err)
+	require.Equal(1, count)
+
+	lib = NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), &Options{Strict: true})
+	_, err = lib.Repositories(borges.RWMode)
+	require.True(ErrReadOnly.Is(err))
+}
+
+func TestLibrary_Recursive(t *testing.T) {
+	require := require.New(t)
+
+	lib := NewLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"), nil)
+
+	loc, err := lib.Location("foo")
+	require.NoError(err)
+	require.IsType(&Location{}, loc)
+
+	_, err = loc.Init("github.com/foo/qux")
+	require.True(ErrReadOnly.Is(err))
+
+	locs, err := lib.Locations()
+	require.NoError(err)
+
+	err = locs.ForEach(func(loc borges.Location) error {
+		require.IsType(&Location{}, loc)
+		return nil
+	})
+	require.NoError(err)
+
+	sub, err := lib.Library("sub")
+	require.NoError(err)
+	require.IsType(&Library{}, sub)
+
+	_, err = sub.Init("github.com/foo/qux")
+	require.True(ErrReadOnly.Is(err))
+
+	libs, err := lib.Libraries()
+	require.NoError(err)
+
+	err = libs.ForEach(func(l borges.Library) error {
+		require.IsType(&Library{}, l)
+		return nil
+	})
+	require.NoError(err)
+
+	_, err = lib.Location("bar")
+	require.True(borges.ErrLocationNotExists.Is(err))
+}
@@ -0,0 +1,77 @@
+package readonly
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+)
+
+// Location wraps a borges.Location, Init and GetOrInit return ErrReadOnly and
+// the repositories are always opened in ReadOnlyMode.
+type Location struct {
+	loc  borges.Location
+	opts *Options
+}
+
+var _ borges.Location = (*Location)(nil)
+
+// NewLocation returns a new read-only Location wrapping the given one.
+func NewLocation(loc borges.Location, opts *Options) *Location {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	return &Location{loc: loc, opts: opts}
+}
+
+// ID returns the ID of the wrapped Location.
+func (l *Location) ID() borges.LocationID {
+	return l.loc.ID()
+}
+
+// Init returns ErrReadOnly.
+func (l *Location) Init(borges.RepositoryID) (borges.Repository, error) {
+	return nil, ErrReadOnly.New("Init")
+}
+
+// GetOrInit returns ErrReadOnly.
+func (l *Location) GetOrInit(borges.RepositoryID) (borges.Repository, error) {
+	return nil, ErrReadOnly.New("GetOrInit")
+}
+
+// Has returns true if the given RepositoryID matches any repository of the
+// wrapped Location.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	return l.loc.Has(id)
+}
+
+// Get open a repository with the given RepositoryID in ReadOnlyMode. If the
+// RWMode is requested and Options.Strict is set ErrReadOnly is returned.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	mode, err := l.opts.mode("Get", mode)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := l.loc.Get(id, mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRepository(r)
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Location in ReadOnlyMode.
+func (l *Location) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	mode, err := l.opts.mode("Repositories", mode)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := l.loc.Repositories(mode)
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapRepositoryIterator(iter, newRepository), nil
+}
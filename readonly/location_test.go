@@ -0,0 +1,65 @@
+package readonly
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/plain"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+func newLocation(require *require.Assertions, opts *Options) *Location {
+	loc, err := plain.NewLocation("foo", memfs.New(), nil)
+	require.NoError(err)
+
+	_, err = loc.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	return NewLocation(loc, opts)
+}
+
+func TestLocation(t *testing.T) {
+	require := require.New(t)
+
+	loc := newLocation(require, nil)
+	require.Equal(borges.LocationID("foo"), loc.ID())
+
+	_, err := loc.Init("github.com/foo/qux")
+	require.True(ErrReadOnly.Is(err))
+
+	_, err = loc.GetOrInit("github.com/foo/qux")
+	require.True(ErrReadOnly.Is(err))
+
+	has, err := loc.Has("github.com/foo/qux")
+	require.NoError(err)
+	require.False(has)
+
+	r, err := loc.Get("github.com/foo/bar", borges.RWMode)
+	require.NoError(err)
+	requireReadOnly(require, r)
+
+	iter, err := loc.Repositories(borges.RWMode)
+	require.NoError(err)
+
+	r, err = iter.Next()
+	require.NoError(err)
+	requireReadOnly(require, r)
+}
+
+func TestLocation_Strict(t *testing.T) {
+	require := require.New(t)
+
+	loc := newLocation(require, &Options{Strict: true})
+
+	_, err := loc.Get("github.com/foo/bar", borges.RWMode)
+	require.True(ErrReadOnly.Is(err))
+
+	_, err = loc.Repositories(borges.RWMode)
+	require.True(ErrReadOnly.Is(err))
+
+	r, err := loc.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	requireReadOnly(require, r)
+}
@@ -0,0 +1,57 @@
+package readonly
+
+import (
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-git.v4"
+)
+
+// Repository wraps a borges.Repository opened in ReadOnlyMode, its storer is
+// wrapped by a util.ReadOnlyStorer even if the wrapped one already is.
+type Repository struct {
+	r borges.Repository
+	*git.Repository
+}
+
+var _ borges.Repository = (*Repository)(nil)
+
+func newRepository(r borges.Repository) (borges.Repository, error) {
+	repo, err := git.Open(&util.ReadOnlyStorer{r.R().Storer}, nil)
+	if err != nil {
+		r.Close()
+		return nil, err
+	}
+
+	return &Repository{r: r, Repository: repo}, nil
+}
+
+// ID returns the RepositoryID.
+func (r *Repository) ID() borges.RepositoryID {
+	return r.r.ID()
+}
+
+// LocationID returns the LocationID from the Location where it was retrieved.
+func (r *Repository) LocationID() borges.LocationID {
+	return r.r.LocationID()
+}
+
+// Mode returns ReadOnlyMode.
+func (r *Repository) Mode() borges.Mode {
+	return borges.ReadOnlyMode
+}
+
+// Commit returns ErrReadOnly.
+func (r *Repository) Commit() error {
+	return ErrReadOnly.New("Commit")
+}
+
+// Close closes the wrapped repository.
+func (r *Repository) Close() error {
+	return r.r.Close()
+}
+
+// R returns the git.Repository.
+func (r *Repository) R() *git.Repository {
+	return r.Repository
+}
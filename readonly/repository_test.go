@@ -0,0 +1,44 @@
+package readonly
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/memory"
+	"github.com/src-d/go-borges/util"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func requireReadOnly(require *require.Assertions, r borges.Repository) {
+	require.IsType(&Repository{}, r)
+	require.Equal(borges.ReadOnlyMode, r.Mode())
+
+	ref := plumbing.NewHashReference("refs/heads/foo", plumbing.ZeroHash)
+	err := r.R().Storer.SetReference(ref)
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	err = r.R().Storer.RemoveReference("refs/heads/master")
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	require.True(ErrReadOnly.Is(r.Commit()))
+	require.NoError(r.Close())
+}
+
+func TestRepository(t *testing.T) {
+	require := require.New(t)
+
+	// a repository opened in RWMode is read-only once wrapped.
+	loc, err := memory.NewLocation("foo", nil)
+	require.NoError(err)
+
+	r, err := loc.Init("github.com/foo/bar")
+	require.NoError(err)
+
+	wrapped, err := newRepository(r)
+	require.NoError(err)
+	require.Equal(borges.RepositoryID("github.com/foo/bar"), wrapped.ID())
+	require.Equal(borges.LocationID("foo"), wrapped.LocationID())
+	requireReadOnly(require, wrapped)
+}
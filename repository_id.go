@@ -0,0 +1,215 @@
+package borges
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"gopkg.in/src-d/go-errors.v1"
+	"gopkg.in/src-d/go-git.v4/plumbing/transport"
+)
+
+var (
+	// ErrURLTemplateNotFound is returned when a clone URL is requested for a
+	// protocol without template.
+	ErrURLTemplateNotFound = errors.NewKind("URL template not found for protocol %q at host %q")
+	// ErrRepositoryIDWithoutHost is returned when a clone URL is requested
+	// for a RepositoryID not containing a host.
+	ErrRepositoryIDWithoutHost = errors.NewKind("repository %s has no host")
+)
+
+// RepositoryID represents a Repository identifier, these IDs regularly are
+// based on a http or git remore URL, but can be based on any other concept.
+type RepositoryID string
+
+// NewRepositoryID returns a new RepositoryID based on a given endpoint, the
+// host and the path of the endpoint are kept as they are, only the .git
+// suffix is appended when is missing.
+// Eg.: git@github.com:src-d/go-borges becomes github.com/src-d/go-borges.git
+func NewRepositoryID(endpoint string) (RepositoryID, error) {
+	return ParseRepositoryID(endpoint, nil)
+}
+
+// IDRules defines the canonicalization rules applied by ParseRepositoryID.
+// The user info, the scheme and the port of the endpoints are always
+// discarded, the zero value keeps the host and the path as they are, like
+// NewRepositoryID.
+type IDRules struct {
+	// Lowercase lowercases the host and the path of the repository.
+	Lowercase bool
+	// CleanPath removes the trailing slashes and the /.git suffix from the
+	// path.
+	CleanPath bool
+	// KeepPort keeps the port as part of the host when it isn't the default
+	// port of the protocol.
+	KeepPort bool
+	// TrimGitSuffix removes the .git suffix from the path instead of
+	// appending it when is missing.
+	TrimGitSuffix bool
+	// HostAliases maps lowercased host names to their canonical form, eg.:
+	// www.github.com to github.com.
+	HostAliases map[string]string
+}
+
+// CanonicalIDRules returns new IDRules making the different endpoints of the
+// same repository result in the same RepositoryID, the case, the trailing
+// slashes and the form of the .git suffix are ignored.
+// Eg.: https://GitHub.com/Src-d/Go-Borges/ becomes github.com/src-d/go-borges.git
+func CanonicalIDRules() *IDRules {
+	return &IDRules{
+		Lowercase: true,
+		CleanPath: true,
+	}
+}
+
+var defaultPorts = map[string]int{
+	"http":  80,
+	"https": 443,
+	"ssh":   22,
+	"git":   9418,
+}
+
+const gitSuffix = ".git"
+
+// ParseRepositoryID returns a new RepositoryID based on a given endpoint,
+// canonicalized following the given IDRules. If rules is nil the endpoint
+// is parsed like NewRepositoryID does.
+func ParseRepositoryID(endpoint string, rules *IDRules) (RepositoryID, error) {
+	if rules == nil {
+		rules = &IDRules{}
+	}
+
+	e, err := transport.NewEndpoint(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	host := e.Host
+	if rules.Lowercase {
+		host = strings.ToLower(host)
+	}
+
+	if alias, ok := rules.HostAliases[strings.ToLower(host)]; ok {
+		host = alias
+	}
+
+	if rules.KeepPort && e.Port != 0 && e.Port != defaultPorts[e.Protocol] {
+		host += ":" + strconv.Itoa(e.Port)
+	}
+
+	p := e.Path
+	if rules.CleanPath {
+		p = strings.Trim(path.Clean("/"+p), "/")
+		p = strings.TrimSuffix(p, "/"+gitSuffix)
+	}
+
+	if rules.Lowercase {
+		p = strings.ToLower(p)
+	}
+
+	if rules.TrimGitSuffix {
+		p = strings.TrimSuffix(p, gitSuffix)
+	} else if !strings.HasSuffix(p, gitSuffix) {
+		p += gitSuffix
+	}
+
+	return RepositoryID(path.Join(host, p)), nil
+}
+
+func (id RepositoryID) String() string {
+	return string(id)
+}
+
+// split returns the host and the path of the RepositoryID, the first element
+// is considered the host only if the ID contains more than one element.
+func (id RepositoryID) split() (host, p string) {
+	parts := strings.SplitN(string(id), "/", 2)
+	if len(parts) == 1 {
+		return "", parts[0]
+	}
+
+	return parts[0], parts[1]
+}
+
+// Host returns the host of the RepositoryID, eg.: github.com for
+// github.com/src-d/go-borges.git.
+func (id RepositoryID) Host() string {
+	host, _ := id.split()
+	return host
+}
+
+// Path returns the path of the RepositoryID without the host, eg.:
+// src-d/go-borges.git for github.com/src-d/go-borges.git.
+func (id RepositoryID) Path() string {
+	_, p := id.split()
+	return p
+}
+
+// Owner returns the owner of the repository, this is the path without the
+// repository name, eg.: src-d for github.com/src-d/go-borges.git.
+func (id RepositoryID) Owner() string {
+	owner := path.Dir(id.Path())
+	if owner == "." {
+		return ""
+	}
+
+	return owner
+}
+
+// Name returns the name of the repository without the .git suffix, eg.:
+// go-borges for github.com/src-d/go-borges.git.
+func (id RepositoryID) Name() string {
+	return strings.TrimSuffix(path.Base(id.Path()), gitSuffix)
+}
+
+// URL returns the clone URL for the given protocol based on the
+// DefaultURLTemplates.
+func (id RepositoryID) URL(protocol string) (string, error) {
+	return DefaultURLTemplates.URL(id, protocol)
+}
+
+// URLTemplates defines the templates used to build the clone URLs of the
+// repositories. The templates can contain the placeholders {host}, {path},
+// {owner} and {name}, replaced by the values returned by the RepositoryID
+// methods with the same name.
+type URLTemplates struct {
+	// Protocols contains the templates by protocol name.
+	Protocols map[string]string
+	// Hosts contains templates by host and protocol name, they take
+	// precedence over the ones in Protocols.
+	Hosts map[string]map[string]string
+}
+
+// DefaultURLTemplates are the templates used by RepositoryID.URL.
+var DefaultURLTemplates = &URLTemplates{
+	Protocols: map[string]string{
+		"https": "https://{host}/{path}",
+		"ssh":   "git@{host}:{path}",
+		"git":   "git://{host}/{path}",
+	},
+}
+
+// URL returns the clone URL of the given RepositoryID for the given
+// protocol. If no template is found ErrURLTemplateNotFound is returned.
+func (t *URLTemplates) URL(id RepositoryID, protocol string) (string, error) {
+	host := id.Host()
+	if host == "" {
+		return "", ErrRepositoryIDWithoutHost.New(id)
+	}
+
+	tmpl, ok := t.Hosts[host][protocol]
+	if !ok {
+		tmpl, ok = t.Protocols[protocol]
+	}
+
+	if !ok {
+		return "", ErrURLTemplateNotFound.New(protocol, host)
+	}
+
+	return strings.NewReplacer(
+		"{host}", host,
+		"{path}", id.Path(),
+		"{owner}", id.Owner(),
+		"{name}", id.Name(),
+	).Replace(tmpl), nil
+}
@@ -0,0 +1,804 @@
+// Package tiered implements a borges.Library keeping the recently used
+// repositories in a hot tier of locations and the rest in a cold tier.
+package tiered
+
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/events"
+	"github.com/src-d/go-borges/lazy"
+	"github.com/src-d/go-borges/util"
+
+	"gopkg.in/src-d/go-errors.v1"
+)
+
+// ErrDeleteNotSupported is returned when a repository can't be moved to
+// another tier because its Location doesn't implement Deleter.
+var ErrDeleteNotSupported = errors.NewKind("location %s doesn't support delete")
+
+// Deleter is implemented by the Locations able to remove repositories, like
+// plain.Location or memory.Location.
+type Deleter interface {
+	// Delete removes the repository with the given RepositoryID.
+	Delete(borges.RepositoryID) error
+}
+
+// Options contains configuration options for a Library.
+type Options struct {
+	// Promote moves the repositories read from the cold tier to the hot
+	// tier. The repository is only removed from the cold tier if its
+	// location implements Deleter.
+	Promote bool
+	// DemoteAfter is the period of time after which a repository not
+	// accessed is moved to the cold tier, zero disables the demotion.
+	DemoteAfter time.Duration
+	// DemoteInterval is the interval between two runs of the background
+	// demotion started by Start, by default DemoteAfter.
+	DemoteInterval time.Duration
+	// Placement chooses the hot Location for the new or promoted
+	// repositories and the cold one for the demoted, by default
+	// lazy.HashPlacement.
+	Placement lazy.Placement
+	// ErrorHandler is called with the errors of the background demotion.
+	ErrorHandler func(error)
+	// Events receives a Move event for each promoted or demoted
+	// repository, the synchronous handlers can veto the move.
+	Events *events.Bus
+}
+
+// Validate validates the fields and sets the default values.
+func (o *Options) Validate() error {
+	if o.DemoteInterval == 0 {
+		o.DemoteInterval = o.DemoteAfter
+	}
+
+	if o.Placement == nil {
+		o.Placement = lazy.HashPlacement
+	}
+
+	if o.ErrorHandler == nil {
+		o.ErrorHandler = func(error) {}
+	}
+
+	return nil
+}
+
+// Library is a borges.Library with two tiers of locations. The repositories
+// are always initialized in the hot tier, if a repository is in both tiers
+// the hot one is used.
+//
+// The access time of each repository is tracked in memory by Get, the
+// repositories not accessed since the Library was created are considered
+// accessed at that time. A repository is never moved while it's open
+// through the Library, and Get and Init wait for any move in progress of the
+// requested repository. The same applies to the repositories opened through
+// the iterators and locations returned by the Library.
+type Library struct {
+	id   borges.LibraryID
+	hot  []borges.Location
+	cold []borges.Location
+	opts *Options
+
+	now     func() time.Time
+	started time.Time
+
+	// m guards the access times, the open repositories and the moves in
+	// progress.
+	m      sync.Mutex
+	access map[borges.RepositoryID]time.Time
+	open   map[borges.RepositoryID]int
+	moving map[borges.RepositoryID]chan struct{}
+
+	// move serializes the promotions and demotions.
+	move sync.Mutex
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewLibrary returns a new Library with the given hot and cold locations.
+func NewLibrary(
+	id borges.LibraryID,
+	hot, cold []borges.Location,
+	opts *Options,
+) (*Library, error) {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &Library{
+		id:      id,
+		hot:     hot,
+		cold:    cold,
+		opts:    opts,
+		now:     time.Now,
+		started: time.Now(),
+		access:  make(map[borges.RepositoryID]time.Time),
+		open:    make(map[borges.RepositoryID]int),
+		moving:  make(map[borges.RepositoryID]chan struct{}),
+	}, nil
+}
+
+// ID returns the borges.LibraryID for this Library.
+func (l *Library) ID() borges.LibraryID {
+	return l.id
+}
+
+// Init initializes a new Repository in the hot tier. If the repository
+// already exists in any tier ErrRepositoryExists is returned.
+func (l *Library) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return l.acquired(id, func() (borges.Repository, error) {
+		return l.init(id)
+	})
+}
+
+func (l *Library) init(id borges.RepositoryID) (borges.Repository, error) {
+	ok, _, _, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return nil, borges.ErrRepositoryExists.New(id)
+	}
+
+	loc, err := l.opts.Placement(id, l.hot)
+	if err != nil {
+		return nil, err
+	}
+
+	return loc.Init(id)
+}
+
+// GetOrInit get the requested repository based on the given id, or inits a
+// new repository in the hot tier. If the repository is opened this will be
+// done in RWMode.
+func (l *Library) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	ok, _, _, err := l.Has(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ok {
+		return l.Get(id, borges.RWMode)
+	}
+
+	return l.Init(id)
+}
+
+// Has returns true, the LibraryID and the LocationID if the given
+// RepositoryID matches any repository at any tier. It doesn't count as an
+// access.
+func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	loc, err := l.find(id)
+	if borges.ErrRepositoryNotExists.Is(err) {
+		return false, "", "", nil
+	}
+
+	if err != nil {
+		return false, "", "", err
+	}
+
+	return true, l.id, loc.ID(), nil
+}
+
+// Get open a repository with the given RepositoryID from the hot tier or the
+// cold one, promoting it if Options.Promote is set. The promotion is skipped
+// if the repository is already open. If the repository can't be found
+// ErrRepositoryNotExists is returned.
+func (l *Library) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	r, err := l.acquired(id, func() (borges.Repository, error) {
+		return l.get(id, mode)
+	})
+
+	if borges.ErrRepositoryNotExists.Is(err) {
+		l.forget(id)
+	}
+
+	return r, err
+}
+
+func (l *Library) get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	loc, cold, err := l.locate(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if cold && l.opts.Promote {
+		// the caller counts as an opener of the repository.
+		if loc, err = l.promote(id, loc, 1); err != nil {
+			return nil, err
+		}
+	}
+
+	return loc.Get(id, mode)
+}
+
+// Promote moves the repository with the given RepositoryID to the hot tier,
+// it does nothing if it's already there or if it's open.
+func (l *Library) Promote(id borges.RepositoryID) error {
+	loc, cold, err := l.locate(id)
+	if err != nil || !cold {
+		return err
+	}
+
+	_, err = l.promote(id, loc, 0)
+	return err
+}
+
+// promote moves the repository to the hot tier unless it's open more than
+// opened times, returning the location where it must be read from.
+func (l *Library) promote(
+	id borges.RepositoryID,
+	from borges.Location,
+	opened int,
+) (borges.Location, error) {
+	l.move.Lock()
+	defer l.move.Unlock()
+
+	// it could be promoted while waiting for the lock.
+	if loc, err := l.findIn(id, l.hot); err == nil {
+		return loc, nil
+	}
+
+	if !l.beginMove(id, opened, time.Time{}) {
+		return from, nil
+	}
+
+	defer l.endMove(id)
+
+	to, err := l.opts.Placement(id, l.hot)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := l.moveRepository(id, from, to, false); err != nil {
+		return nil, err
+	}
+
+	return to, nil
+}
+
+// Demote moves to the cold tier all the repositories of the hot tier not
+// accessed during Options.DemoteAfter, the open repositories are skipped.
+// The hot locations must implement Deleter.
+func (l *Library) Demote() error {
+	if l.opts.DemoteAfter <= 0 {
+		return nil
+	}
+
+	limit := l.now().Add(-l.opts.DemoteAfter)
+	for _, loc := range l.hot {
+		ids, err := repositoryIDs(loc)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range ids {
+			// checked again under the lock, this skips the recent ones
+			// without waiting for the running moves.
+			if l.accessed(id).After(limit) {
+				continue
+			}
+
+			if err := l.demote(id, loc, limit); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (l *Library) demote(id borges.RepositoryID, from borges.Location, limit time.Time) error {
+	l.move.Lock()
+	defer l.move.Unlock()
+
+	if !l.beginMove(id, 0, limit) {
+		return nil
+	}
+
+	defer l.endMove(id)
+
+	// it could be deleted before taking the lock.
+	ok, err := from.Has(id)
+	if err != nil || !ok {
+		return err
+	}
+
+	to, err := l.opts.Placement(id, l.cold)
+	if err != nil {
+		return err
+	}
+
+	if err := l.moveRepository(id, from, to, true); err != nil {
+		return err
+	}
+
+	// only the access times of the hot tier are needed.
+	l.forget(id)
+	return nil
+}
+
+// Start runs Demote in background every Options.DemoteInterval until Close
+// is called, the errors are sent to Options.ErrorHandler.
+func (l *Library) Start() {
+	if l.opts.DemoteInterval <= 0 || l.stop != nil {
+		return
+	}
+
+	l.stop = make(chan struct{})
+	l.done = make(chan struct{})
+
+	go func() {
+		defer close(l.done)
+
+		ticker := time.NewTicker(l.opts.DemoteInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-l.stop:
+				return
+			case <-ticker.C:
+				if err := l.Demote(); err != nil {
+					l.opts.ErrorHandler(err)
+				}
+			}
+		}
+	}()
+}
+
+// Close stops the background demotion if it was started.
+func (l *Library) Close() error {
+	if l.stop == nil {
+		return nil
+	}
+
+	close(l.stop)
+	<-l.done
+	l.stop = nil
+
+	return nil
+}
+
+// acquired opens the repository with fn counting it as open and accessed, so
+// it isn't moved until the returned Repository is closed.
+func (l *Library) acquired(
+	id borges.RepositoryID,
+	fn func() (borges.Repository, error),
+) (borges.Repository, error) {
+	l.acquire(id)
+	r, err := fn()
+	if err != nil {
+		l.release(id)
+		return nil, err
+	}
+
+	return l.wrap(r), nil
+}
+
+// acquire waits until the repository isn't being moved, then counts it as
+// open and updates its access time.
+func (l *Library) acquire(id borges.RepositoryID) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	for {
+		done, ok := l.moving[id]
+		if !ok {
+			break
+		}
+
+		l.m.Unlock()
+		<-done
+		l.m.Lock()
+	}
+
+	l.open[id]++
+	l.access[id] = l.now()
+}
+
+// release undoes acquire once the repository is closed.
+func (l *Library) release(id borges.RepositoryID) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.open[id]--; l.open[id] <= 0 {
+		delete(l.open, id)
+	}
+}
+
+// beginMove marks the repository as being moved, blocking acquire until
+// endMove is called. It returns false if the repository is open more than
+// opened times, it's already being moved or it was accessed after limit
+// when it isn't zero.
+func (l *Library) beginMove(id borges.RepositoryID, opened int, limit time.Time) bool {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if _, ok := l.moving[id]; ok || l.open[id] > opened {
+		return false
+	}
+
+	if !limit.IsZero() && l.accessedLocked(id).After(limit) {
+		return false
+	}
+
+	l.moving[id] = make(chan struct{})
+	return true
+}
+
+func (l *Library) endMove(id borges.RepositoryID) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	close(l.moving[id])
+	delete(l.moving, id)
+}
+
+// forget removes the access time of a repository deleted or moved to the
+// cold tier, unless it's open.
+func (l *Library) forget(id borges.RepositoryID) {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	if l.open[id] == 0 {
+		delete(l.access, id)
+	}
+}
+
+func (l *Library) accessed(id borges.RepositoryID) time.Time {
+	l.m.Lock()
+	defer l.m.Unlock()
+
+	return l.accessedLocked(id)
+}
+
+func (l *Library) accessedLocked(id borges.RepositoryID) time.Time {
+	if t, ok := l.access[id]; ok {
+		return t
+	}
+
+	return l.started
+}
+
+func (l *Library) wrap(r borges.Repository) borges.Repository {
+	id := r.ID()
+	return &repository{Repository: r, release: func() { l.release(id) }}
+}
+
+// repository wraps a borges.Repository returned by the Library to release
+// it once closed, a transactional Commit closes it too.
+type repository struct {
+	borges.Repository
+	once    sync.Once
+	release func()
+}
+
+// Commit persists the changes of the wrapped Repository.
+func (r *repository) Commit() error {
+	err := r.Repository.Commit()
+	if !borges.ErrNonTransactional.Is(err) {
+		r.once.Do(r.release)
+	}
+
+	return err
+}
+
+// Close closes the wrapped Repository.
+func (r *repository) Close() error {
+	err := r.Repository.Close()
+	r.once.Do(r.release)
+	return err
+}
+
+// locate returns the location of the repository and if it's in the cold
+// tier.
+func (l *Library) locate(id borges.RepositoryID) (borges.Location, bool, error) {
+	loc, err := l.findIn(id, l.hot)
+	if !borges.ErrRepositoryNotExists.Is(err) {
+		return loc, false, err
+	}
+
+	loc, err = l.findIn(id, l.cold)
+	return loc, true, err
+}
+
+func (l *Library) find(id borges.RepositoryID) (borges.Location, error) {
+	loc, _, err := l.locate(id)
+	return loc, err
+}
+
+func (l *Library) findIn(id borges.RepositoryID, locs []borges.Location) (borges.Location, error) {
+	for _, loc := range locs {
+		ok, err := loc.Has(id)
+		if err != nil {
+			return nil, err
+		}
+
+		if ok {
+			return loc, nil
+		}
+	}
+
+	return nil, borges.ErrRepositoryNotExists.New(id)
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of both tiers, a repository kept in both tiers is returned
+// twice. The repositories are opened as Get does, without promoting them.
+func (l *Library) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	return &repositoryIterator{l: l, locs: l.locations(), mode: mode}, nil
+}
+
+// Location returns the Location with the given ID from any tier, if it
+// doesn't exist ErrLocationNotExists is returned. The repositories opened
+// through it are tracked as the ones opened by the Library.
+func (l *Library) Location(id borges.LocationID) (borges.Location, error) {
+	for _, loc := range l.locations() {
+		if loc.ID() == id {
+			return &location{Location: loc, l: l}, nil
+		}
+	}
+
+	return nil, borges.ErrLocationNotExists.New(id)
+}
+
+// Locations returns a LocationIterator that iterates through the locations
+// of the hot tier followed by the ones of the cold tier, wrapped as the ones
+// returned by Location.
+func (l *Library) Locations() (borges.LocationIterator, error) {
+	iter := util.NewLocationIterator(l.locations())
+	return util.NewMapLocationIterator(iter, func(loc borges.Location) (borges.Location, error) {
+		return &location{Location: loc, l: l}, nil
+	}), nil
+}
+
+func (l *Library) locations() []borges.Location {
+	locs := make([]borges.Location, 0, len(l.hot)+len(l.cold))
+	return append(append(locs, l.hot...), l.cold...)
+}
+
+// location wraps a Location of a tier, the repositories opened through it
+// are counted as open and accessed, so they aren't moved while open.
+type location struct {
+	borges.Location
+	l *Library
+}
+
+// Init initializes a new Repository in the wrapped Location.
+func (loc *location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	return loc.l.acquired(id, func() (borges.Repository, error) {
+		return loc.Location.Init(id)
+	})
+}
+
+// GetOrInit opens or initializes the repository in the wrapped Location.
+func (loc *location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	return loc.l.acquired(id, func() (borges.Repository, error) {
+		return loc.Location.GetOrInit(id)
+	})
+}
+
+// Get opens the repository with the given RepositoryID from the wrapped
+// Location, it's never promoted.
+func (loc *location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	return loc.l.acquired(id, func() (borges.Repository, error) {
+		return loc.Location.Get(id, mode)
+	})
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Location, opened as Get does.
+func (loc *location) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	return &repositoryIterator{
+		l:    loc.l,
+		locs: []borges.Location{loc.Location},
+		mode: mode,
+	}, nil
+}
+
+// Delete removes the repository from the wrapped Location, waiting for the
+// moves in progress. If the Location doesn't implement Deleter
+// ErrDeleteNotSupported is returned.
+func (loc *location) Delete(id borges.RepositoryID) error {
+	d, ok := loc.Location.(Deleter)
+	if !ok {
+		return ErrDeleteNotSupported.New(loc.ID())
+	}
+
+	loc.l.move.Lock()
+	defer loc.l.move.Unlock()
+
+	if err := d.Delete(id); err != nil {
+		return err
+	}
+
+	loc.l.forget(id)
+	return nil
+}
+
+// repositoryIterator iterates through the repositories of the given
+// locations opening them as location.Get does, the repositories moved
+// during the iteration are skipped.
+type repositoryIterator struct {
+	l    *Library
+	locs []borges.Location
+	mode borges.Mode
+
+	loc borges.Location
+	ids []borges.RepositoryID
+}
+
+// Next returns the next repository from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *repositoryIterator) Next() (borges.Repository, error) {
+	for {
+		if len(iter.ids) == 0 {
+			if len(iter.locs) == 0 {
+				return nil, io.EOF
+			}
+
+			iter.loc, iter.locs = iter.locs[0], iter.locs[1:]
+			ids, err := repositoryIDs(iter.loc)
+			if err != nil {
+				return nil, err
+			}
+
+			iter.ids = ids
+			continue
+		}
+
+		id, loc := iter.ids[0], iter.loc
+		iter.ids = iter.ids[1:]
+
+		r, err := iter.l.acquired(id, func() (borges.Repository, error) {
+			return loc.Get(id, iter.mode)
+		})
+
+		if borges.ErrRepositoryNotExists.Is(err) {
+			continue
+		}
+
+		return r, err
+	}
+}
+
+// ForEach call the function for each object contained on this iter until an
+// error happens or the end of the iter is reached. If ErrStop is sent the
+// iteration is stop but no error is returned. The iterator is closed.
+func (iter *repositoryIterator) ForEach(cb func(borges.Repository) error) error {
+	return util.ForEachRepositoryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *repositoryIterator) Close() {
+	iter.locs, iter.ids = nil, nil
+}
+
+// Library returns ErrLibraryNotExists, a tiered Library doesn't contain
+// other libraries.
+func (l *Library) Library(id borges.LibraryID) (borges.Library, error) {
+	return nil, borges.ErrLibraryNotExists.New(id)
+}
+
+// Libraries returns an empty LibraryIterator.
+func (l *Library) Libraries() (borges.LibraryIterator, error) {
+	return util.NewLibraryIterator(nil), nil
+}
+
+// moveRepository moves the repository publishing a Move event if
+// Options.Events is set.
+func (l *Library) moveRepository(id borges.RepositoryID, from, to borges.Location, required bool) error {
+	if l.opts.Events == nil {
+		return moveRepository(id, from, to, required)
+	}
+
+	e := &events.Event{
+		Type:     events.Move,
+		Library:  l.id,
+		Location: from.ID(),
+		ID:       id,
+		Mode:     borges.RWMode,
+		To:       to.ID(),
+	}
+
+	if err := l.opts.Events.Before(e); err != nil {
+		return err
+	}
+
+	if err := moveRepository(id, from, to, required); err != nil {
+		return err
+	}
+
+	l.opts.Events.After(e)
+	return nil
+}
+
+// moveRepository copies the repository between two locations and deletes it
+// from the origin. If the origin doesn't implement Deleter the repository is
+// kept unless required is set, then ErrDeleteNotSupported is returned before
+// copying anything.
+func moveRepository(id borges.RepositoryID, from, to borges.Location, required bool) error {
+	d, deletable := from.(Deleter)
+	if !deletable && required {
+		return ErrDeleteNotSupported.New(from.ID())
+	}
+
+	if err := replaceRepository(id, to); err != nil {
+		return err
+	}
+
+	if err := copyRepository(id, from, to); err != nil {
+		return err
+	}
+
+	if !deletable {
+		return nil
+	}
+
+	return d.Delete(id)
+}
+
+// replaceRepository deletes a stale copy of the repository at loc if any.
+func replaceRepository(id borges.RepositoryID, loc borges.Location) error {
+	ok, err := loc.Has(id)
+	if err != nil || !ok {
+		return err
+	}
+
+	d, deletable := loc.(Deleter)
+	if !deletable {
+		return borges.ErrRepositoryExists.New(id)
+	}
+
+	return d.Delete(id)
+}
+
+func copyRepository(id borges.RepositoryID, from, to borges.Location) (err error) {
+	src, err := from.Get(id, borges.ReadOnlyMode)
+	if err != nil {
+		return err
+	}
+
+	defer src.Close()
+
+	dst, err := to.Init(id)
+	if err != nil {
+		return err
+	}
+
+	if err := util.CopyStorer(dst.R().Storer, src.R().Storer); err != nil {
+		dst.Close()
+		return err
+	}
+
+	err = dst.Commit()
+	if borges.ErrNonTransactional.Is(err) {
+		return dst.Close()
+	}
+
+	return err
+}
+
+func repositoryIDs(loc borges.Location) ([]borges.RepositoryID, error) {
+	iter, err := loc.Repositories(borges.ReadOnlyMode)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return r.Close()
+	})
+
+	return ids, err
+}
@@ -0,0 +1,404 @@
+package tiered
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/events"
+	"github.com/src-d/go-borges/memory"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+)
+
+func newLocation(require *require.Assertions, id borges.LocationID) *memory.Location {
+	loc, err := memory.NewLocation(id, &memory.LocationOptions{Transactional: true})
+	require.NoError(err)
+	return loc
+}
+
+// initRepository creates a repository with a reference at the given
+// location.
+func initRepository(require *require.Assertions, loc borges.Location, id borges.RepositoryID) {
+	r, err := loc.Init(id)
+	require.NoError(err)
+
+	obj := r.R().Storer.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	h, err := r.R().Storer.SetEncodedObject(obj)
+	require.NoError(err)
+
+	ref := plumbing.NewHashReference("refs/heads/master", h)
+	require.NoError(r.R().Storer.SetReference(ref))
+	require.NoError(r.Commit())
+}
+
+func requireLocation(require *require.Assertions, lib *Library, id borges.RepositoryID, loc borges.LocationID) {
+	ok, _, found, err := lib.Has(id)
+	require.NoError(err)
+	require.True(ok, id)
+	require.Equal(loc, found, id)
+
+	// the repository is read from the tier location to not count as an
+	// access.
+	var l borges.Location
+	for _, tl := range lib.locations() {
+		if tl.ID() == loc {
+			l = tl
+		}
+	}
+
+	require.NotNil(l, loc)
+	r, err := l.Get(id, borges.ReadOnlyMode)
+	require.NoError(err)
+	_, err = r.R().Reference("refs/heads/master", false)
+	require.NoError(err)
+	require.NoError(r.Close())
+}
+
+func TestLibrary_Get(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, cold, "foo")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, nil)
+	require.NoError(err)
+
+	requireLocation(require, lib, "foo", "cold")
+
+	_, err = lib.Get("bar", borges.ReadOnlyMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+
+	r, err := lib.Init("bar")
+	require.NoError(err)
+	require.Equal(borges.LocationID("hot"), r.LocationID())
+
+	_, err = lib.Init("foo")
+	require.True(borges.ErrRepositoryExists.Is(err))
+}
+
+func TestLibrary_Promote(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, cold, "foo")
+	initRepository(require, cold, "bar")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, &Options{
+		Promote: true,
+	})
+	require.NoError(err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// the repositories open by others are read from the cold tier.
+			r, err := lib.Get("foo", borges.ReadOnlyMode)
+			require.NoError(err)
+			require.NoError(r.Close())
+		}()
+	}
+
+	wg.Wait()
+	r, err := lib.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.Equal(borges.LocationID("hot"), r.LocationID())
+	require.NoError(r.Close())
+	requireLocation(require, lib, "foo", "hot")
+
+	has, err := cold.Has("foo")
+	require.NoError(err)
+	require.False(has)
+
+	lib.opts.Promote = false
+	requireLocation(require, lib, "bar", "cold")
+
+	require.NoError(lib.Promote("bar"))
+	requireLocation(require, lib, "bar", "hot")
+	require.NoError(lib.Promote("bar"))
+}
+
+func TestLibrary_Demote(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, hot, "foo")
+	initRepository(require, hot, "bar")
+	initRepository(require, cold, "bar")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, &Options{
+		DemoteAfter: time.Hour,
+	})
+	require.NoError(err)
+
+	now := time.Now()
+	lib.now = func() time.Time { return now }
+
+	now = now.Add(30 * time.Minute)
+	r, err := lib.Get("bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	require.NoError(lib.Demote())
+	requireLocation(require, lib, "foo", "hot")
+
+	now = now.Add(45 * time.Minute)
+	require.NoError(lib.Demote())
+	requireLocation(require, lib, "foo", "cold")
+	requireLocation(require, lib, "bar", "hot")
+
+	now = now.Add(2 * time.Hour)
+	require.NoError(lib.Demote())
+	requireLocation(require, lib, "bar", "cold")
+
+	has, err := hot.Has("bar")
+	require.NoError(err)
+	require.False(has)
+}
+
+func TestLibrary_Promote_Open(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, cold, "foo")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, nil)
+	require.NoError(err)
+
+	r, err := lib.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+
+	require.NoError(lib.Promote("foo"))
+	requireLocation(require, lib, "foo", "cold")
+
+	require.NoError(r.Close())
+	require.NoError(lib.Promote("foo"))
+	requireLocation(require, lib, "foo", "hot")
+}
+
+func TestLibrary_Demote_Open(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, hot, "foo")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, &Options{
+		DemoteAfter: time.Hour,
+	})
+	require.NoError(err)
+
+	now := time.Now()
+	lib.now = func() time.Time { return now }
+
+	r, err := lib.Get("foo", borges.RWMode)
+	require.NoError(err)
+
+	now = now.Add(2 * time.Hour)
+	require.NoError(lib.Demote())
+	requireLocation(require, lib, "foo", "hot")
+
+	require.NoError(r.Commit())
+	require.NoError(lib.Demote())
+	requireLocation(require, lib, "foo", "cold")
+}
+
+func TestLibrary_Demote_OpenThroughLocations(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, hot, "foo")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, &Options{
+		DemoteAfter: time.Hour,
+	})
+	require.NoError(err)
+
+	now := time.Now()
+	lib.now = func() time.Time { return now }
+
+	iter, err := lib.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+	r, err := iter.Next()
+	require.NoError(err)
+	iter.Close()
+
+	now = now.Add(2 * time.Hour)
+	require.NoError(lib.Demote())
+	requireLocation(require, lib, "foo", "hot")
+	require.NoError(r.Close())
+
+	// the repository is accessed when it's opened through the location.
+	loc, err := lib.Location("hot")
+	require.NoError(err)
+	r, err = loc.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	now = now.Add(30 * time.Minute)
+	require.NoError(lib.Demote())
+	requireLocation(require, lib, "foo", "hot")
+
+	now = now.Add(time.Hour)
+	require.NoError(lib.Demote())
+	requireLocation(require, lib, "foo", "cold")
+	require.NotContains(lib.access, borges.RepositoryID("foo"))
+}
+
+func TestLibrary_Location_Delete(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, hot, "foo")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, nil)
+	require.NoError(err)
+
+	r, err := lib.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+	require.Contains(lib.access, borges.RepositoryID("foo"))
+
+	loc, err := lib.Location("hot")
+	require.NoError(err)
+	require.NoError(loc.(Deleter).Delete("foo"))
+	require.NotContains(lib.access, borges.RepositoryID("foo"))
+
+	has, err := hot.Has("foo")
+	require.NoError(err)
+	require.False(has)
+}
+
+func TestLibrary_demote_Accessed(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, hot, "foo")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, &Options{
+		DemoteAfter: time.Hour,
+	})
+	require.NoError(err)
+
+	now := time.Now()
+	lib.now = func() time.Time { return now }
+	limit := now.Add(time.Minute)
+
+	// accessed after Demote checked the access time but before the move.
+	now = now.Add(2 * time.Minute)
+	r, err := lib.Get("foo", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	require.NoError(lib.demote("foo", hot, limit))
+	requireLocation(require, lib, "foo", "hot")
+}
+
+type readOnlyLocation struct {
+	borges.Location
+}
+
+func TestLibrary_Demote_NotDeletable(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, hot, "foo")
+
+	lib, err := NewLibrary(
+		"foo",
+		[]borges.Location{&readOnlyLocation{hot}},
+		[]borges.Location{cold},
+		&Options{DemoteAfter: time.Hour},
+	)
+	require.NoError(err)
+	lib.now = func() time.Time { return time.Now().Add(2 * time.Hour) }
+
+	err = lib.Demote()
+	require.True(ErrDeleteNotSupported.Is(err))
+
+	has, err := cold.Has("foo")
+	require.NoError(err)
+	require.False(has)
+}
+
+func TestLibrary_Start(t *testing.T) {
+	require := require.New(t)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, hot, "foo")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, &Options{
+		DemoteAfter:    time.Millisecond,
+		DemoteInterval: 5 * time.Millisecond,
+	})
+	require.NoError(err)
+
+	lib.Start()
+	time.Sleep(50 * time.Millisecond)
+	require.NoError(lib.Close())
+
+	requireLocation(require, lib, "foo", "cold")
+}
+
+func TestLibrary_Events(t *testing.T) {
+	require := require.New(t)
+
+	bus, err := events.NewBus(nil)
+	require.NoError(err)
+	defer bus.Close()
+
+	var moves []*events.Event
+	bus.Subscribe(func(e *events.Event) error {
+		if e.ID == "bar" {
+			return errors.New("pinned")
+		}
+
+		moves = append(moves, e)
+		return nil
+	}, events.Move)
+
+	hot, cold := newLocation(require, "hot"), newLocation(require, "cold")
+	initRepository(require, cold, "foo")
+	initRepository(require, cold, "bar")
+
+	lib, err := NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, &Options{
+		Events: bus,
+	})
+	require.NoError(err)
+
+	require.NoError(lib.Promote("foo"))
+	requireLocation(require, lib, "foo", "hot")
+
+	require.True(events.ErrVetoed.Is(lib.Promote("bar")))
+	requireLocation(require, lib, "bar", "cold")
+
+	require.Len(moves, 1)
+	require.Equal(borges.LocationID("cold"), moves[0].Location)
+	require.Equal(borges.LocationID("hot"), moves[0].To)
+}
+
+func TestLibrarySuite(t *testing.T) {
+	suite.Run(t, &borgestest.LibrarySuite{
+		NewLibrary: func() (borges.Library, error) {
+			hot, err := memory.NewLocation("hot", &memory.LocationOptions{Transactional: true})
+			if err != nil {
+				return nil, err
+			}
+
+			cold, err := memory.NewLocation("cold", &memory.LocationOptions{Transactional: true})
+			if err != nil {
+				return nil, err
+			}
+
+			return NewLibrary("foo", []borges.Location{hot}, []borges.Location{cold}, nil)
+		},
+		Transactional: true,
+	})
+}
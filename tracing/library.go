@@ -0,0 +1,228 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/plain"
+	"github.com/src-d/go-borges/util"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// tracer starts the spans of a wrapper, they are children of the span
+// carried by the context given to WithContext, if any.
+type tracer struct {
+	ctx    context.Context
+	tracer opentracing.Tracer
+	lib    borges.LibraryID
+}
+
+func newTracer(opts *Options, lib borges.LibraryID) *tracer {
+	return &tracer{ctx: context.Background(), tracer: opts.Tracer, lib: lib}
+}
+
+// withContext returns a copy of the tracer starting the spans as children
+// of the span carried by ctx.
+func (t *tracer) withContext(ctx context.Context) *tracer {
+	return &tracer{ctx: ctx, tracer: t.tracer, lib: t.lib}
+}
+
+func (t *tracer) start(op string, tags opentracing.Tags) opentracing.Span {
+	return startSpan(t.ctx, t.tracer, op, tags)
+}
+
+// Start honors the plain.Hook interface, the internal operations of the
+// wrapped implementations are traced as children of the span carried by
+// ctx.
+func (t *tracer) Start(ctx context.Context, op plain.Operation) (context.Context, func(error)) {
+	span := startSpan(ctx, t.tracer, op.Name, tags(op.ID, op.Location, op.Library))
+	return opentracing.ContextWithSpan(ctx, span), func(err error) {
+		finish(span, err)
+	}
+}
+
+// hooked returns the context notifying the internal operations as children
+// of the given span.
+func (t *tracer) hooked(span opentracing.Span) context.Context {
+	return plain.WithHook(opentracing.ContextWithSpan(t.ctx, span), t)
+}
+
+// contextLibrary is implemented by the libraries notifying their internal
+// operations to the plain.Hook carried by a context.
+type contextLibrary interface {
+	WithContext(context.Context) borges.Library
+}
+
+// contextLocation is implemented by the locations notifying their internal
+// operations to the plain.Hook carried by a context.
+type contextLocation interface {
+	WithContext(context.Context) borges.Location
+}
+
+// open traces an operation opening a repository, the returned Repository
+// traces its commit and close.
+func (t *tracer) open(
+	op string,
+	tags opentracing.Tags,
+	fn func(opentracing.Span) (borges.Repository, error),
+) (borges.Repository, error) {
+	span := t.start(op, tags)
+	r, err := fn(span)
+	if err == nil {
+		span.SetTag(LocationIDTag, string(r.LocationID()))
+	}
+
+	finish(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{Repository: r, t: t}, nil
+}
+
+// Library wraps a borges.Library starting a span for every call. Every
+// location, library, repository and iterator returned by it is wrapped too.
+type Library struct {
+	lib  borges.Library
+	opts *Options
+	t    *tracer
+}
+
+var _ borges.Library = (*Library)(nil)
+
+// NewLibrary returns a new Library wrapping the given one, the spans are
+// root spans until WithContext is used.
+func NewLibrary(lib borges.Library, opts *Options) *Library {
+	opts = validate(opts)
+	return &Library{lib: lib, opts: opts, t: newTracer(opts, lib.ID())}
+}
+
+// WithContext returns a copy of the Library starting the spans as children
+// of the span carried by ctx. The Library can be shared, WithContext should
+// be called on every request.
+func (l *Library) WithContext(ctx context.Context) *Library {
+	return &Library{lib: l.lib, opts: l.opts, t: l.t.withContext(ctx)}
+}
+
+// ID returns the borges.LibraryID of the wrapped Library.
+func (l *Library) ID() borges.LibraryID {
+	return l.lib.ID()
+}
+
+// Init initializes a new Repository in the wrapped Library.
+func (l *Library) Init(id borges.RepositoryID) (borges.Repository, error) {
+	tags := modeTags(id, "", l.lib.ID(), borges.RWMode)
+	return l.t.open("borges.Library.Init", tags, func(opentracing.Span) (borges.Repository, error) {
+		return l.lib.Init(id)
+	})
+}
+
+// GetOrInit opens or initializes the repository in the wrapped Library.
+func (l *Library) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	tags := modeTags(id, "", l.lib.ID(), borges.RWMode)
+	return l.t.open("borges.Library.GetOrInit", tags, func(opentracing.Span) (borges.Repository, error) {
+		return l.lib.GetOrInit(id)
+	})
+}
+
+// Get opens the repository with the given RepositoryID.
+func (l *Library) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	tags := modeTags(id, "", l.lib.ID(), mode)
+	return l.t.open("borges.Library.Get", tags, func(span opentracing.Span) (borges.Repository, error) {
+		return l.traced(span).Get(id, mode)
+	})
+}
+
+// Has returns true, the LibraryID and the LocationID if the given
+// RepositoryID matches any repository of the wrapped Library.
+func (l *Library) Has(id borges.RepositoryID) (bool, borges.LibraryID, borges.LocationID, error) {
+	span := l.t.start("borges.Library.Has", tags(id, "", l.lib.ID()))
+	ok, lib, loc, err := l.traced(span).Has(id)
+	if ok {
+		span.SetTag(LocationIDTag, string(loc))
+	}
+
+	finish(span, err)
+	return ok, lib, loc, err
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Library, every step is traced.
+func (l *Library) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	span := l.t.start("borges.Library.Repositories", modeTags("", "", l.lib.ID(), mode))
+	iter, err := l.lib.Repositories(mode)
+	finish(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RepositoryIterator{iter: iter, t: l.t}, nil
+}
+
+// Location returns the Location with the given LocationID wrapped to trace
+// its calls.
+func (l *Library) Location(id borges.LocationID) (borges.Location, error) {
+	loc, err := l.lib.Location(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.location(loc), nil
+}
+
+// Locations returns a LocationIterator that iterates through all the
+// locations of the wrapped Library wrapped to trace their calls.
+func (l *Library) Locations() (borges.LocationIterator, error) {
+	iter, err := l.lib.Locations()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLocationIterator(iter, func(loc borges.Location) (borges.Location, error) {
+		return l.location(loc), nil
+	}), nil
+}
+
+// Library returns the Library with the given LibraryID wrapped to trace its
+// calls with the same context.
+func (l *Library) Library(id borges.LibraryID) (borges.Library, error) {
+	lib, err := l.lib.Library(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.library(lib), nil
+}
+
+// Libraries returns a LibraryIterator that iterates through all the
+// libraries of the wrapped Library wrapped to trace their calls with the
+// same context.
+func (l *Library) Libraries() (borges.LibraryIterator, error) {
+	iter, err := l.lib.Libraries()
+	if err != nil {
+		return nil, err
+	}
+
+	return util.NewMapLibraryIterator(iter, func(lib borges.Library) (borges.Library, error) {
+		return l.library(lib), nil
+	}), nil
+}
+
+// traced returns the wrapped Library notifying its internal operations as
+// children of the given span, if supported.
+func (l *Library) traced(span opentracing.Span) borges.Library {
+	if cl, ok := l.lib.(contextLibrary); ok {
+		return cl.WithContext(l.t.hooked(span))
+	}
+
+	return l.lib
+}
+
+func (l *Library) library(lib borges.Library) *Library {
+	return NewLibrary(lib, l.opts).WithContext(l.t.ctx)
+}
+
+func (l *Library) location(loc borges.Location) *Location {
+	return &Location{loc: loc, t: l.t}
+}
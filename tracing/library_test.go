@@ -0,0 +1,215 @@
+package tracing_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/borgestest"
+	"github.com/src-d/go-borges/plain"
+	"github.com/src-d/go-borges/tracing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+func newLibrary(require *require.Assertions, opts *tracing.Options) *tracing.Library {
+	lib := borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar")
+	return tracing.NewLibrary(lib, opts)
+}
+
+// tree returns the finished spans as lines of operation names indented by
+// depth, children sorted by finish order.
+func tree(tracer *mocktracer.MockTracer) string {
+	spans := tracer.FinishedSpans()
+	children := make(map[int][]*mocktracer.MockSpan)
+	for _, s := range spans {
+		children[s.ParentID] = append(children[s.ParentID], s)
+	}
+
+	var b strings.Builder
+	var walk func(parent, depth int)
+	walk = func(parent, depth int) {
+		for _, s := range children[parent] {
+			b.WriteString(strings.Repeat("  ", depth) + s.OperationName + "\n")
+			walk(s.SpanContext.SpanID, depth+1)
+		}
+	}
+
+	walk(0, 0)
+	return b.String()
+}
+
+func TestLibrary_Get(t *testing.T) {
+	require := require.New(t)
+
+	// the repository is only found in the sub-library.
+	root := plain.NewLibrary("root")
+	root.AddLibrary(borgestest.RequirePlainLibrary(require, nil, "github.com/foo/bar"))
+
+	tracer := mocktracer.New()
+	lib := tracing.NewLibrary(root, &tracing.Options{Tracer: tracer})
+
+	r, err := lib.Get("github.com/foo/bar", borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(r.Close())
+
+	require.Equal(`borges.Library.Get
+  plain.Library.Get
+    plain.Library.doGetOnLocations
+    plain.Library.doGetOnLibraries
+      plain.Library.doHasOnLocations
+        plain.Location.Has
+      plain.openRepository
+borges.Repository.Close
+`, tree(tracer))
+
+	has := tracer.FinishedSpans()[1]
+	require.Equal("plain.Location.Has", has.OperationName)
+	require.Equal("github.com/foo/bar", has.Tag(tracing.RepositoryIDTag))
+	require.Equal("foo", has.Tag(tracing.LocationIDTag))
+
+	get := tracer.FinishedSpans()[len(tracer.FinishedSpans())-2]
+	require.Equal("borges.Library.Get", get.OperationName)
+	require.Equal("github.com/foo/bar", get.Tag(tracing.RepositoryIDTag))
+	require.Equal("foo", get.Tag(tracing.LocationIDTag))
+	require.Equal("read-only", get.Tag(tracing.ModeTag))
+}
+
+func TestLibrary_Has(t *testing.T) {
+	require := require.New(t)
+
+	tracer := mocktracer.New()
+	root := tracer.StartSpan("request")
+	ctx := opentracing.ContextWithSpan(context.Background(), root)
+
+	lib := newLibrary(require, nil).WithContext(ctx)
+
+	ok, _, _, err := lib.Has("github.com/foo/qux")
+	require.NoError(err)
+	require.False(ok)
+
+	sub, err := lib.Library("sub")
+	require.NoError(err)
+
+	loc, err := sub.Location("foo")
+	require.NoError(err)
+
+	ok, err = loc.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(ok)
+	root.Finish()
+
+	require.Equal(`request
+  borges.Library.Has
+    plain.Library.Has
+      plain.Library.doHasOnLocations
+        plain.Location.Has
+      plain.Library.doHasOnLibraries
+        plain.Library.doHasOnLocations
+          plain.Location.Has
+        plain.Library.doHasOnLibraries
+  borges.Location.Has
+    plain.Location.Has
+`, tree(tracer))
+}
+
+func TestLibrary_WithContext(t *testing.T) {
+	require := require.New(t)
+
+	tracer := mocktracer.New()
+	lib := newLibrary(require, &tracing.Options{Tracer: tracer})
+
+	for _, name := range []string{"first", "second"} {
+		root := tracer.StartSpan(name)
+		ctx := opentracing.ContextWithSpan(context.Background(), root)
+
+		r, err := lib.WithContext(ctx).Get("github.com/foo/bar", borges.ReadOnlyMode)
+		require.NoError(err)
+		require.NoError(r.Close())
+		root.Finish()
+	}
+
+	ok, _, _, err := lib.Has("github.com/foo/bar")
+	require.NoError(err)
+	require.True(ok)
+
+	// every request has its own parent, the shared Library starts root
+	// spans.
+	require.Equal(`first
+  borges.Library.Get
+    plain.Library.Get
+      plain.Library.doGetOnLocations
+        plain.Location.Has
+        plain.openRepository
+  borges.Repository.Close
+second
+  borges.Library.Get
+    plain.Library.Get
+      plain.Library.doGetOnLocations
+        plain.Location.Has
+        plain.openRepository
+  borges.Repository.Close
+borges.Library.Has
+  plain.Library.Has
+    plain.Library.doHasOnLocations
+      plain.Location.Has
+`, tree(tracer))
+}
+
+func TestLibrary_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	tracer := mocktracer.New()
+	lib := newLibrary(require, &tracing.Options{Tracer: tracer})
+
+	sub, err := lib.Library("sub")
+	require.NoError(err)
+
+	iter, err := sub.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = iter.ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return nil
+	})
+	require.NoError(err)
+	require.Equal([]borges.RepositoryID{"github.com/foo/bar"}, ids)
+
+	require.Equal(`borges.Library.Repositories
+borges.RepositoryIterator.Next
+borges.RepositoryIterator.Next
+`, tree(tracer))
+
+	next := tracer.FinishedSpans()[1]
+	require.Equal("github.com/foo/bar", next.Tag(tracing.RepositoryIDTag))
+	require.Equal("foo", next.Tag(tracing.LocationIDTag))
+	require.Equal("sub", next.Tag(tracing.LibraryIDTag))
+	require.Equal(true, tracer.FinishedSpans()[2].Tag("eof"))
+}
+
+func TestLibrarySuite(t *testing.T) {
+	for _, transactional := range []bool{false, true} {
+		transactional := transactional
+		suite.Run(t, &borgestest.LibrarySuite{
+			NewLibrary: func() (borges.Library, error) {
+				lib, err := borgestest.NewPlainLibrary(&plain.LocationOptions{
+					Transactional: transactional,
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				tracer := mocktracer.New()
+				root := tracer.StartSpan("request")
+				ctx := opentracing.ContextWithSpan(context.Background(), root)
+				return tracing.NewLibrary(lib, nil).WithContext(ctx), nil
+			},
+			Transactional: transactional,
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Location wraps a borges.Location starting a span for every call. Every
+// repository and iterator returned by it is wrapped too.
+type Location struct {
+	loc borges.Location
+	t   *tracer
+}
+
+var _ borges.Location = (*Location)(nil)
+
+// NewLocation returns a new Location wrapping the given one, the spans are
+// root spans until WithContext is used.
+func NewLocation(loc borges.Location, opts *Options) *Location {
+	return &Location{loc: loc, t: newTracer(validate(opts), "")}
+}
+
+// WithContext returns a copy of the Location starting the spans as children
+// of the span carried by ctx. The Location can be shared, WithContext should
+// be called on every request.
+func (l *Location) WithContext(ctx context.Context) *Location {
+	return &Location{loc: l.loc, t: l.t.withContext(ctx)}
+}
+
+// ID returns the ID of the wrapped Location.
+func (l *Location) ID() borges.LocationID {
+	return l.loc.ID()
+}
+
+// Init initializes a new Repository in the wrapped Location.
+func (l *Location) Init(id borges.RepositoryID) (borges.Repository, error) {
+	tags := modeTags(id, l.loc.ID(), l.t.lib, borges.RWMode)
+	return l.t.open("borges.Location.Init", tags, func(opentracing.Span) (borges.Repository, error) {
+		return l.loc.Init(id)
+	})
+}
+
+// GetOrInit opens or initializes the repository in the wrapped Location.
+func (l *Location) GetOrInit(id borges.RepositoryID) (borges.Repository, error) {
+	tags := modeTags(id, l.loc.ID(), l.t.lib, borges.RWMode)
+	return l.t.open("borges.Location.GetOrInit", tags, func(opentracing.Span) (borges.Repository, error) {
+		return l.loc.GetOrInit(id)
+	})
+}
+
+// Has returns true if the given RepositoryID matches any repository of the
+// wrapped Location.
+func (l *Location) Has(id borges.RepositoryID) (bool, error) {
+	span := l.t.start("borges.Location.Has", tags(id, l.loc.ID(), l.t.lib))
+	ok, err := l.traced(span).Has(id)
+	finish(span, err)
+	return ok, err
+}
+
+// Get opens the repository with the given RepositoryID.
+func (l *Location) Get(id borges.RepositoryID, mode borges.Mode) (borges.Repository, error) {
+	tags := modeTags(id, l.loc.ID(), l.t.lib, mode)
+	return l.t.open("borges.Location.Get", tags, func(span opentracing.Span) (borges.Repository, error) {
+		return l.traced(span).Get(id, mode)
+	})
+}
+
+// Repositories returns a RepositoryIterator that iterates through all the
+// repositories of the wrapped Location, every step is traced.
+func (l *Location) Repositories(mode borges.Mode) (borges.RepositoryIterator, error) {
+	span := l.t.start("borges.Location.Repositories", modeTags("", l.loc.ID(), l.t.lib, mode))
+	iter, err := l.loc.Repositories(mode)
+	finish(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RepositoryIterator{iter: iter, t: l.t}, nil
+}
+
+// traced returns the wrapped Location notifying its internal operations as
+// children of the given span, if supported.
+func (l *Location) traced(span opentracing.Span) borges.Location {
+	if cl, ok := l.loc.(contextLocation); ok {
+		return cl.WithContext(l.t.hooked(span))
+	}
+
+	return l.loc
+}
@@ -0,0 +1,81 @@
+package tracing_test
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/memory"
+	"github.com/src-d/go-borges/plain"
+	"github.com/src-d/go-borges/tracing"
+
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/memfs"
+)
+
+func TestLocation_Get(t *testing.T) {
+	require := require.New(t)
+
+	loc, err := plain.NewLocation("foo", memfs.New(), &plain.LocationOptions{
+		Transactional: true,
+	})
+	require.NoError(err)
+
+	tracer := mocktracer.New()
+	l := tracing.NewLocation(loc, &tracing.Options{Tracer: tracer})
+
+	r, err := l.Init("foo")
+	require.NoError(err)
+	require.NoError(r.Commit())
+
+	_, err = l.Get("bar", borges.RWMode)
+	require.True(borges.ErrRepositoryNotExists.Is(err))
+
+	r, err = l.Get("foo", borges.RWMode)
+	require.NoError(err)
+
+	require.Equal(`borges.Location.Init
+borges.Repository.Commit
+borges.Location.Get
+  plain.Location.Has
+borges.Location.Get
+  plain.Location.Has
+  plain.openRepository
+`, tree(tracer))
+
+	failed := tracer.FinishedSpans()[3]
+	require.Equal("bar", failed.Tag(tracing.RepositoryIDTag))
+	require.Equal(true, failed.Tag("error"))
+	require.NoError(r.Close())
+}
+
+func TestLocation_Repositories(t *testing.T) {
+	require := require.New(t)
+
+	mem, err := memory.NewLocation("mem", nil)
+	require.NoError(err)
+
+	tracer := mocktracer.New()
+	l := tracing.NewLocation(mem, &tracing.Options{Tracer: tracer})
+
+	_, err = l.Init("foo")
+	require.NoError(err)
+
+	ok, err := l.Has("foo")
+	require.NoError(err)
+	require.True(ok)
+
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+	require.NoError(iter.ForEach(func(r borges.Repository) error {
+		return r.Close()
+	}))
+
+	require.Equal(`borges.Location.Init
+borges.Location.Has
+borges.Location.Repositories
+borges.RepositoryIterator.Next
+borges.Repository.Close
+borges.RepositoryIterator.Next
+`, tree(tracer))
+}
@@ -0,0 +1,80 @@
+package tracing
+
+import (
+	"io"
+
+	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/util"
+
+	"github.com/opentracing/opentracing-go"
+)
+
+// Repository wraps a borges.Repository starting a span for its commit and
+// close.
+type Repository struct {
+	borges.Repository
+	t *tracer
+}
+
+// Commit persists the changes of the wrapped Repository.
+func (r *Repository) Commit() error {
+	span := r.t.start("borges.Repository.Commit", r.tags())
+	err := r.Repository.Commit()
+	finish(span, err)
+	return err
+}
+
+// Close closes the wrapped Repository.
+func (r *Repository) Close() error {
+	span := r.t.start("borges.Repository.Close", r.tags())
+	err := r.Repository.Close()
+	finish(span, err)
+	return err
+}
+
+func (r *Repository) tags() opentracing.Tags {
+	return modeTags(r.ID(), r.LocationID(), r.t.lib, r.Mode())
+}
+
+// RepositoryIterator wraps a borges.RepositoryIterator starting a span for
+// every step, the span of the last one is tagged with eof.
+type RepositoryIterator struct {
+	iter borges.RepositoryIterator
+	t    *tracer
+}
+
+// Next returns the next repository from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *RepositoryIterator) Next() (borges.Repository, error) {
+	span := iter.t.start("borges.RepositoryIterator.Next", tags("", "", iter.t.lib))
+	r, err := iter.iter.Next()
+	if err == io.EOF {
+		span.SetTag("eof", true)
+		span.Finish()
+		return nil, err
+	}
+
+	if err == nil {
+		span.SetTag(RepositoryIDTag, r.ID().String())
+		span.SetTag(LocationIDTag, string(r.LocationID()))
+	}
+
+	finish(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository{Repository: r, t: iter.t}, nil
+}
+
+// ForEach call the function for each object contained on this iter until an
+// error happens or the end of the iter is reached. If ErrStop is sent the
+// iteration is stop but no error is returned. The iterator is closed.
+func (iter *RepositoryIterator) ForEach(cb func(borges.Repository) error) error {
+	return util.ForEachRepositoryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *RepositoryIterator) Close() {
+	iter.iter.Close()
+}
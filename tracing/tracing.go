@@ -0,0 +1,117 @@
+// Package tracing provides OpenTracing spans for the library, location and
+// repository operations. The wrappers returned by NewLibrary and NewLocation
+// start a span for every call, WithContext binds them to the span carried by
+// the context of a request. The implementations notifying their internal
+// operations to a plain.Hook, like plain.Library and plain.Location, get
+// child spans for them, like the lookups in every location and sub-library.
+package tracing
+
+import (
+	"context"
+
+	"github.com/src-d/go-borges"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/ext"
+	"github.com/opentracing/opentracing-go/log"
+)
+
+// Tags set on the spans.
+const (
+	RepositoryIDTag = "borges.repository_id"
+	LocationIDTag   = "borges.location_id"
+	LibraryIDTag    = "borges.library_id"
+	ModeTag         = "borges.mode"
+)
+
+// startSpan starts a span child of the span carried by ctx, if any, using
+// its tracer, or a new root span with the given tracer.
+func startSpan(
+	ctx context.Context,
+	tracer opentracing.Tracer,
+	op string,
+	t opentracing.Tags,
+) opentracing.Span {
+	opts := []opentracing.StartSpanOption{t}
+	if parent := opentracing.SpanFromContext(ctx); parent != nil {
+		tracer = parent.Tracer()
+		opts = append(opts, opentracing.ChildOf(parent.Context()))
+	}
+
+	return tracer.StartSpan(op, opts...)
+}
+
+// finish finishes the span marking it as failed if err isn't nil.
+func finish(span opentracing.Span, err error) {
+	if err != nil {
+		ext.Error.Set(span, true)
+		span.LogFields(log.Error(err))
+	}
+
+	span.Finish()
+}
+
+// tags returns the tags for a repository operation, the empty values are
+// omitted.
+func tags(id borges.RepositoryID, loc borges.LocationID, lib borges.LibraryID) opentracing.Tags {
+	t := opentracing.Tags{}
+	if id != "" {
+		t[RepositoryIDTag] = id.String()
+	}
+
+	if loc != "" {
+		t[LocationIDTag] = string(loc)
+	}
+
+	if lib != "" {
+		t[LibraryIDTag] = string(lib)
+	}
+
+	return t
+}
+
+// modeTags returns the tags for a repository operation with a Mode.
+func modeTags(
+	id borges.RepositoryID,
+	loc borges.LocationID,
+	lib borges.LibraryID,
+	mode borges.Mode,
+) opentracing.Tags {
+	t := tags(id, loc, lib)
+	t[ModeTag] = modeName(mode)
+	return t
+}
+
+func modeName(m borges.Mode) string {
+	if m == borges.RWMode {
+		return "rw"
+	}
+
+	return "read-only"
+}
+
+// Options contains configuration options for the tracing wrappers.
+type Options struct {
+	// Tracer starts the root spans when the context doesn't carry a span,
+	// otherwise the tracer of that span is used. By default
+	// opentracing.GlobalTracer.
+	Tracer opentracing.Tracer
+}
+
+// Validate validates the fields and sets the default values.
+func (o *Options) Validate() error {
+	if o.Tracer == nil {
+		o.Tracer = opentracing.GlobalTracer()
+	}
+
+	return nil
+}
+
+func validate(opts *Options) *Options {
+	if opts == nil {
+		opts = &Options{}
+	}
+
+	opts.Validate()
+	return opts
+}
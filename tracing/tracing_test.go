@@ -0,0 +1,44 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/opentracing/opentracing-go/mocktracer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartSpan(t *testing.T) {
+	require := require.New(t)
+
+	tracer := mocktracer.New()
+	span := startSpan(context.Background(), tracer, "foo", tags("bar", "baz", ""))
+	require.Equal(tracer, span.Tracer())
+	span.Finish()
+
+	root := tracer.StartSpan("root")
+	ctx := opentracing.ContextWithSpan(context.Background(), root)
+
+	span = startSpan(ctx, opentracing.NoopTracer{}, "foo", modeTags("bar", "baz", "qux", 0))
+	require.Equal(tracer, span.Tracer())
+	finish(span, errors.New("failed"))
+	root.Finish()
+
+	spans := tracer.FinishedSpans()
+	require.Len(spans, 3)
+	require.Equal(0, spans[0].ParentID)
+
+	foo := spans[1]
+	require.Equal("foo", foo.OperationName)
+	require.Equal(root.(*mocktracer.MockSpan).SpanContext.SpanID, foo.ParentID)
+	require.Equal(map[string]interface{}{
+		RepositoryIDTag: "bar",
+		LocationIDTag:   "baz",
+		LibraryIDTag:    "qux",
+		ModeTag:         "rw",
+		"error":         true,
+	}, foo.Tags())
+	require.Len(foo.Logs(), 1)
+}
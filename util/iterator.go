@@ -190,3 +190,173 @@ func ForEachLibraryIterator(iter borges.LibraryIterator, cb func(borges.Library)
 		}
 	}
 }
+
+// MapRepositoryIterator returns the repositories of another iterator
+// transformed by a function, like wrapping them.
+type MapRepositoryIterator struct {
+	iter borges.RepositoryIterator
+	fn   func(borges.Repository) (borges.Repository, error)
+}
+
+// NewMapRepositoryIterator returns a new MapRepositoryIterator applying the
+// given function to every repository returned by iter.
+func NewMapRepositoryIterator(
+	iter borges.RepositoryIterator,
+	fn func(borges.Repository) (borges.Repository, error),
+) *MapRepositoryIterator {
+	return &MapRepositoryIterator{iter: iter, fn: fn}
+}
+
+// Next returns the next repository from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *MapRepositoryIterator) Next() (borges.Repository, error) {
+	r, err := iter.iter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return iter.fn(r)
+}
+
+// ForEach call the function for each object contained on this iter until
+// an error happens or the end of the iter is reached. If ErrStop is sent
+// the iteration is stop but no error is returned. The iterator is closed.
+func (iter *MapRepositoryIterator) ForEach(cb func(borges.Repository) error) error {
+	return ForEachRepositoryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *MapRepositoryIterator) Close() {
+	iter.iter.Close()
+}
+
+// FilterRepositoryIterator returns only the repositories of another
+// iterator accepted by a function, the rejected ones are closed.
+type FilterRepositoryIterator struct {
+	iter borges.RepositoryIterator
+	fn   func(borges.Repository) (bool, error)
+}
+
+// NewFilterRepositoryIterator returns a new FilterRepositoryIterator
+// returning the repositories of iter for which the given function returns
+// true.
+func NewFilterRepositoryIterator(
+	iter borges.RepositoryIterator,
+	fn func(borges.Repository) (bool, error),
+) *FilterRepositoryIterator {
+	return &FilterRepositoryIterator{iter: iter, fn: fn}
+}
+
+// Next returns the next repository from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *FilterRepositoryIterator) Next() (borges.Repository, error) {
+	for {
+		r, err := iter.iter.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		ok, err := iter.fn(r)
+		if ok && err == nil {
+			return r, nil
+		}
+
+		if cerr := r.Close(); err == nil {
+			err = cerr
+		}
+
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ForEach call the function for each object contained on this iter until
+// an error happens or the end of the iter is reached. If ErrStop is sent
+// the iteration is stop but no error is returned. The iterator is closed.
+func (iter *FilterRepositoryIterator) ForEach(cb func(borges.Repository) error) error {
+	return ForEachRepositoryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *FilterRepositoryIterator) Close() {
+	iter.iter.Close()
+}
+
+// MapLocationIterator returns the locations of another iterator transformed
+// by a function, like wrapping them.
+type MapLocationIterator struct {
+	iter borges.LocationIterator
+	fn   func(borges.Location) (borges.Location, error)
+}
+
+// NewMapLocationIterator returns a new MapLocationIterator applying the
+// given function to every location returned by iter.
+func NewMapLocationIterator(
+	iter borges.LocationIterator,
+	fn func(borges.Location) (borges.Location, error),
+) *MapLocationIterator {
+	return &MapLocationIterator{iter: iter, fn: fn}
+}
+
+// Next returns the next location from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *MapLocationIterator) Next() (borges.Location, error) {
+	l, err := iter.iter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return iter.fn(l)
+}
+
+// ForEach call the function for each object contained on this iter until
+// an error happens or the end of the iter is reached. If ErrStop is sent
+// the iteration is stop but no error is returned. The iterator is closed.
+func (iter *MapLocationIterator) ForEach(cb func(borges.Location) error) error {
+	return ForEachLocatorIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *MapLocationIterator) Close() {
+	iter.iter.Close()
+}
+
+// MapLibraryIterator returns the libraries of another iterator transformed
+// by a function, like wrapping them.
+type MapLibraryIterator struct {
+	iter borges.LibraryIterator
+	fn   func(borges.Library) (borges.Library, error)
+}
+
+// NewMapLibraryIterator returns a new MapLibraryIterator applying the given
+// function to every library returned by iter.
+func NewMapLibraryIterator(
+	iter borges.LibraryIterator,
+	fn func(borges.Library) (borges.Library, error),
+) *MapLibraryIterator {
+	return &MapLibraryIterator{iter: iter, fn: fn}
+}
+
+// Next returns the next library from the iterator. If the iterator has
+// reached the end it will return io.EOF as an error.
+func (iter *MapLibraryIterator) Next() (borges.Library, error) {
+	l, err := iter.iter.Next()
+	if err != nil {
+		return nil, err
+	}
+
+	return iter.fn(l)
+}
+
+// ForEach call the function for each object contained on this iter until
+// an error happens or the end of the iter is reached. If ErrStop is sent
+// the iteration is stop but no error is returned. The iterator is closed.
+func (iter *MapLibraryIterator) ForEach(cb func(borges.Library) error) error {
+	return ForEachLibraryIterator(iter, cb)
+}
+
+// Close releases any resources used by the iterator.
+func (iter *MapLibraryIterator) Close() {
+	iter.iter.Close()
+}
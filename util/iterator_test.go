@@ -1,10 +1,13 @@
 package util_test
 
 import (
+	"errors"
 	"io"
 	"testing"
 
 	"github.com/src-d/go-borges"
+	"github.com/src-d/go-borges/memory"
+	"github.com/src-d/go-borges/plain"
 	"github.com/src-d/go-borges/util"
 	"github.com/stretchr/testify/require"
 )
@@ -17,3 +20,127 @@ func TestNewLocationRepositoryIteratorNext(t *testing.T) {
 	require.Equal(err, io.EOF)
 	require.Nil(r)
 }
+
+func newMemoryLocation(require *require.Assertions, ids ...borges.RepositoryID) borges.Location {
+	l, err := memory.NewLocation("foo", nil)
+	require.NoError(err)
+
+	for _, id := range ids {
+		_, err := l.Init(id)
+		require.NoError(err)
+	}
+
+	return l
+}
+
+type wrappedRepository struct {
+	borges.Repository
+}
+
+func TestMapRepositoryIterator(t *testing.T) {
+	require := require.New(t)
+
+	l := newMemoryLocation(require, "bar", "foo")
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = util.NewMapRepositoryIterator(iter, func(r borges.Repository) (borges.Repository, error) {
+		return &wrappedRepository{r}, nil
+	}).ForEach(func(r borges.Repository) error {
+		require.IsType(&wrappedRepository{}, r)
+		ids = append(ids, r.ID())
+		return nil
+	})
+
+	require.NoError(err)
+	require.Equal([]borges.RepositoryID{"bar", "foo"}, ids)
+
+	iter, err = l.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	expected := errors.New("foo")
+	mapped := util.NewMapRepositoryIterator(iter, func(r borges.Repository) (borges.Repository, error) {
+		return nil, expected
+	})
+
+	_, err = mapped.Next()
+	require.Equal(expected, err)
+}
+
+func TestFilterRepositoryIterator(t *testing.T) {
+	require := require.New(t)
+
+	l := newMemoryLocation(require, "bar", "baz", "foo")
+	iter, err := l.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	var ids []borges.RepositoryID
+	err = util.NewFilterRepositoryIterator(iter, func(r borges.Repository) (bool, error) {
+		return r.ID() != "baz", nil
+	}).ForEach(func(r borges.Repository) error {
+		ids = append(ids, r.ID())
+		return nil
+	})
+
+	require.NoError(err)
+	require.Equal([]borges.RepositoryID{"bar", "foo"}, ids)
+
+	iter, err = l.Repositories(borges.ReadOnlyMode)
+	require.NoError(err)
+
+	expected := errors.New("foo")
+	filtered := util.NewFilterRepositoryIterator(iter, func(r borges.Repository) (bool, error) {
+		return true, expected
+	})
+
+	_, err = filtered.Next()
+	require.Equal(expected, err)
+}
+
+type wrappedLocation struct {
+	borges.Location
+}
+
+func TestMapLocationIterator(t *testing.T) {
+	require := require.New(t)
+
+	locs := []borges.Location{newMemoryLocation(require), newMemoryLocation(require)}
+	iter := util.NewMapLocationIterator(
+		util.NewLocationIterator(locs),
+		func(l borges.Location) (borges.Location, error) {
+			return &wrappedLocation{l}, nil
+		},
+	)
+
+	var count int
+	err := iter.ForEach(func(l borges.Location) error {
+		require.IsType(&wrappedLocation{}, l)
+		count++
+		return nil
+	})
+
+	require.NoError(err)
+	require.Equal(2, count)
+}
+
+func TestMapLibraryIterator(t *testing.T) {
+	require := require.New(t)
+
+	libs := []borges.Library{plain.NewLibrary("foo"), plain.NewLibrary("bar")}
+	iter := util.NewMapLibraryIterator(
+		util.NewLibraryIterator(libs),
+		func(l borges.Library) (borges.Library, error) {
+			return plain.NewLibrary(l.ID() + "-mapped"), nil
+		},
+	)
+
+	var ids []borges.LibraryID
+	err := iter.ForEach(func(l borges.Library) error {
+		ids = append(ids, l.ID())
+		return borges.ErrStop
+	})
+
+	require.NoError(err)
+	require.Equal([]borges.LibraryID{"foo-mapped"}, ids)
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"sort"
+
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
+)
+
+// RefChange is a change of a reference between two snapshots. Old is
+// plumbing.ZeroHash for created references and New for deleted ones.
+type RefChange struct {
+	Name plumbing.ReferenceName
+	Old  plumbing.Hash
+	New  plumbing.Hash
+}
+
+// References returns a snapshot with the hash of every hash reference of the
+// storer, symbolic references are ignored.
+func References(s storer.ReferenceStorer) (map[plumbing.ReferenceName]plumbing.Hash, error) {
+	iter, err := s.IterReferences()
+	if err != nil {
+		return nil, err
+	}
+
+	refs := make(map[plumbing.ReferenceName]plumbing.Hash)
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		if ref.Type() == plumbing.HashReference {
+			refs[ref.Name()] = ref.Hash()
+		}
+
+		return nil
+	})
+
+	return refs, err
+}
+
+// DiffReferences returns the changes between two reference snapshots sorted
+// by reference name.
+func DiffReferences(old, new map[plumbing.ReferenceName]plumbing.Hash) []RefChange {
+	var changes []RefChange
+	for name, h := range new {
+		if o, ok := old[name]; !ok || o != h {
+			changes = append(changes, RefChange{Name: name, Old: o, New: h})
+		}
+	}
+
+	for name, h := range old {
+		if _, ok := new[name]; !ok {
+			changes = append(changes, RefChange{Name: name, Old: h, New: plumbing.ZeroHash})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Name < changes[j].Name })
+	return changes
+}
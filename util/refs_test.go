@@ -0,0 +1,43 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/src-d/go-borges/util"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+func TestDiffReferences(t *testing.T) {
+	require := require.New(t)
+
+	s := memory.NewStorage()
+	h1 := plumbing.NewHash("6ecf0ef2c2dffb796033e5a02219af86ec6584e5")
+	h2 := plumbing.NewHash("918c48b83bd081e863dbe1b80f8998f058cd8294")
+
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", h1)))
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/old", h1)))
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/same", h1)))
+	require.NoError(s.SetReference(plumbing.NewSymbolicReference("HEAD", "refs/heads/master")))
+
+	old, err := util.References(s)
+	require.NoError(err)
+	require.Len(old, 3)
+
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/master", h2)))
+	require.NoError(s.SetReference(plumbing.NewHashReference("refs/heads/new", h2)))
+	require.NoError(s.RemoveReference("refs/heads/old"))
+
+	new, err := util.References(s)
+	require.NoError(err)
+
+	require.Equal([]util.RefChange{
+		{Name: "refs/heads/master", Old: h1, New: h2},
+		{Name: "refs/heads/new", Old: plumbing.ZeroHash, New: h2},
+		{Name: "refs/heads/old", Old: h1, New: plumbing.ZeroHash},
+	}, util.DiffReferences(old, new))
+
+	require.Empty(util.DiffReferences(new, new))
+}
@@ -1,11 +1,16 @@
 package util
 
 import (
+	"bytes"
+
 	"gopkg.in/src-d/go-errors.v1"
 	"gopkg.in/src-d/go-git.v4/config"
 	"gopkg.in/src-d/go-git.v4/plumbing"
 	"gopkg.in/src-d/go-git.v4/plumbing/format/index"
+	"gopkg.in/src-d/go-git.v4/plumbing/format/packfile"
+	"gopkg.in/src-d/go-git.v4/plumbing/storer"
 	"gopkg.in/src-d/go-git.v4/storage"
+	"gopkg.in/src-d/go-git.v4/utils/ioutil"
 )
 
 // ErrReadOnlyStorer error returns when a write method is used in a ReadOnlyStorer.
@@ -35,6 +40,18 @@ func (s *ReadOnlyStorer) SetReference(*plumbing.Reference) error {
 	return ErrReadOnlyStorer.New()
 }
 
+// RemoveReference honors the storage.Storer interface. It fails with a
+// ErrReadOnlyStorer when is called.
+func (s *ReadOnlyStorer) RemoveReference(plumbing.ReferenceName) error {
+	return ErrReadOnlyStorer.New()
+}
+
+// PackRefs honors the storage.Storer interface. It fails with a
+// ErrReadOnlyStorer when is called.
+func (s *ReadOnlyStorer) PackRefs() error {
+	return ErrReadOnlyStorer.New()
+}
+
 // SetShallow honors the storage.Storer interface. It fails with a
 // ErrReadOnlyStorer when is called.
 func (s *ReadOnlyStorer) SetShallow([]plumbing.Hash) error {
@@ -55,3 +72,137 @@ func (s *ReadOnlyStorer) SetConfig(*config.Config) error {
 	return ErrReadOnlyStorer.New()
 
 }
+
+// Module honors the storage.Storer interface. The storer of the submodule
+// is wrapped in a ReadOnlyStorer too.
+func (s *ReadOnlyStorer) Module(name string) (storage.Storer, error) {
+	m, err := s.Storer.Module(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ReadOnlyStorer{Storer: m}, nil
+}
+
+// packWindow is the number of objects considered to find deltas when the
+// objects are copied as a packfile, the default of git.
+const packWindow = 10
+
+// CopyStorer copies all the objects, references, config, index and shallow
+// commits from src into dst. If dst implements storer.PackfileWriter the
+// objects are written as a single packfile instead of one by one.
+func CopyStorer(dst, src storage.Storer) error {
+	if err := copyObjects(dst, src); err != nil {
+		return err
+	}
+
+	refs, err := src.IterReferences()
+	if err != nil {
+		return err
+	}
+
+	err = refs.ForEach(func(ref *plumbing.Reference) error {
+		return dst.SetReference(ref)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err := CopyConfig(dst, src); err != nil {
+		return err
+	}
+
+	if err := copyIndex(dst, src); err != nil {
+		return err
+	}
+
+	shallow, err := src.Shallow()
+	if err != nil || len(shallow) == 0 {
+		return err
+	}
+
+	return dst.SetShallow(shallow)
+}
+
+func copyObjects(dst, src storage.Storer) error {
+	objects, err := src.IterEncodedObjects(plumbing.AnyObject)
+	if err != nil {
+		return err
+	}
+
+	pw, ok := dst.(storer.PackfileWriter)
+	if !ok {
+		return objects.ForEach(func(obj plumbing.EncodedObject) error {
+			_, err := dst.SetEncodedObject(obj)
+			return err
+		})
+	}
+
+	var hashes []plumbing.Hash
+	err = objects.ForEach(func(obj plumbing.EncodedObject) error {
+		hashes = append(hashes, obj.Hash())
+		return nil
+	})
+
+	if err != nil || len(hashes) == 0 {
+		return err
+	}
+
+	return copyPackfile(pw, src, hashes)
+}
+
+// copyPackfile writes the objects with the given hashes from src into a
+// new packfile of dst.
+func copyPackfile(dst storer.PackfileWriter, src storage.Storer, hashes []plumbing.Hash) (err error) {
+	w, err := dst.PackfileWriter()
+	if err != nil {
+		return err
+	}
+
+	defer ioutil.CheckClose(w, &err)
+
+	_, err = packfile.NewEncoder(w, src, false).Encode(hashes, packWindow)
+	return err
+}
+
+// CopyConfig copies the config from src into dst through its encoded form,
+// some storers return the stored instance, so it can't be shared between
+// storers.
+func CopyConfig(dst, src storage.Storer) error {
+	cfg, err := src.Config()
+	if err != nil {
+		return err
+	}
+
+	data, err := cfg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	copied := config.NewConfig()
+	if err := copied.Unmarshal(data); err != nil {
+		return err
+	}
+
+	return dst.SetConfig(copied)
+}
+
+func copyIndex(dst, src storage.Storer) error {
+	idx, err := src.Index()
+	if err != nil || len(idx.Entries) == 0 {
+		return err
+	}
+
+	buf := bytes.NewBuffer(nil)
+	if err := index.NewEncoder(buf).Encode(idx); err != nil {
+		return err
+	}
+
+	copied := &index.Index{}
+	if err := index.NewDecoder(buf).Decode(copied); err != nil {
+		return err
+	}
+
+	return dst.SetIndex(copied)
+}
@@ -0,0 +1,150 @@
+package util_test
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/src-d/go-borges/util"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/src-d/go-billy.v4/osfs"
+	"gopkg.in/src-d/go-git.v4/config"
+	"gopkg.in/src-d/go-git.v4/plumbing"
+	"gopkg.in/src-d/go-git.v4/plumbing/cache"
+	"gopkg.in/src-d/go-git.v4/storage/filesystem"
+	"gopkg.in/src-d/go-git.v4/storage/memory"
+)
+
+func TestReadOnlyStorer(t *testing.T) {
+	require := require.New(t)
+
+	s := &util.ReadOnlyStorer{Storer: memory.NewStorage()}
+
+	err := s.SetReference(plumbing.NewHashReference("foo", plumbing.ZeroHash))
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	_, err = s.SetEncodedObject(s.NewEncodedObject())
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	err = s.SetConfig(config.NewConfig())
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	err = s.RemoveReference("foo")
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	err = s.PackRefs()
+	require.True(util.ErrReadOnlyStorer.Is(err))
+
+	m, err := s.Module("foo")
+	require.NoError(err)
+	require.IsType(&util.ReadOnlyStorer{}, m)
+
+	_, err = m.SetEncodedObject(m.NewEncodedObject())
+	require.True(util.ErrReadOnlyStorer.Is(err))
+}
+
+func TestCopyStorer(t *testing.T) {
+	require := require.New(t)
+
+	src := memory.NewStorage()
+
+	obj := src.NewEncodedObject()
+	obj.SetType(plumbing.BlobObject)
+	w, err := obj.Writer()
+	require.NoError(err)
+	_, err = w.Write([]byte("foo"))
+	require.NoError(err)
+	require.NoError(w.Close())
+
+	h, err := src.SetEncodedObject(obj)
+	require.NoError(err)
+
+	err = src.SetReference(plumbing.NewHashReference("refs/heads/foo", h))
+	require.NoError(err)
+
+	cfg := config.NewConfig()
+	cfg.Remotes["origin"] = &config.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"github.com/foo/bar"},
+	}
+	require.NoError(src.SetConfig(cfg))
+	require.NoError(src.SetShallow([]plumbing.Hash{h}))
+
+	dst := memory.NewStorage()
+	require.NoError(util.CopyStorer(dst, src))
+
+	require.NoError(dst.HasEncodedObject(h))
+
+	ref, err := dst.Reference("refs/heads/foo")
+	require.NoError(err)
+	require.Equal(h, ref.Hash())
+
+	cfg, err = dst.Config()
+	require.NoError(err)
+	require.Contains(cfg.Remotes, "origin")
+
+	shallow, err := dst.Shallow()
+	require.NoError(err)
+	require.Equal([]plumbing.Hash{h}, shallow)
+}
+
+func TestCopyStorer_Packfile(t *testing.T) {
+	require := require.New(t)
+
+	src := memory.NewStorage()
+	var hashes []plumbing.Hash
+	for _, content := range []string{"foo", "bar", "baz"} {
+		obj := src.NewEncodedObject()
+		obj.SetType(plumbing.BlobObject)
+		w, err := obj.Writer()
+		require.NoError(err)
+		_, err = w.Write([]byte(content))
+		require.NoError(err)
+		require.NoError(w.Close())
+
+		h, err := src.SetEncodedObject(obj)
+		require.NoError(err)
+		hashes = append(hashes, h)
+	}
+
+	// memfs isn't safe for the concurrent read done by the packfile writer.
+	dir, err := ioutil.TempDir("", "util")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	fs := osfs.New(dir)
+	dst := filesystem.NewStorage(fs, cache.NewObjectLRUDefault())
+	require.NoError(util.CopyStorer(dst, src))
+
+	for _, h := range hashes {
+		require.NoError(dst.HasEncodedObject(h))
+	}
+
+	entries, err := fs.ReadDir("objects")
+	require.NoError(err)
+	require.Len(entries, 1)
+	require.Equal("pack", entries[0].Name())
+
+	packs, err := dst.ObjectPacks()
+	require.NoError(err)
+	require.Len(packs, 1)
+}
+
+func TestCopyStorer_ConfigNotShared(t *testing.T) {
+	require := require.New(t)
+
+	src := memory.NewStorage()
+	require.NoError(src.SetConfig(config.NewConfig()))
+
+	dst := memory.NewStorage()
+	require.NoError(util.CopyStorer(dst, src))
+
+	cfg, err := dst.Config()
+	require.NoError(err)
+	cfg.Remotes["origin"] = &config.RemoteConfig{Name: "origin"}
+
+	cfg, err = src.Config()
+	require.NoError(err)
+	require.Len(cfg.Remotes, 0)
+}